@@ -0,0 +1,432 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/cache"
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/host"
+	"github.com/jlym/dbbenchmark/go/internal/httpapi"
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	"github.com/jlym/dbbenchmark/go/internal/metrics"
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	"github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/jlym/dbbenchmark/go/internal/results"
+	"github.com/jlym/dbbenchmark/go/internal/scenario"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/tracing"
+)
+
+const (
+	// backendPostgres runs against Postgres directly.
+	backendPostgres = "postgres"
+	// backendRedis runs against Postgres behind an in-process Redis cache.
+	backendRedis = "redis"
+	// backendHTTP runs against a running cmd/server.
+	backendHTTP = "http"
+)
+
+// benchmarkOptions holds the flags that run, search and matrix share.
+type benchmarkOptions struct {
+	config   driver.Config
+	scenario *scenario.Scenario
+	// middleware wraps the backend the driver calls.
+	middleware middleware.Config
+	logger     *slog.Logger
+	// tracing is set when spans are exported.
+	tracing             bool
+	backend             string
+	serverURL           string
+	isoLevel            string
+	execMode            string
+	feedStrategy        string
+	maxConns            int
+	hostStats           bool
+	pgStats             bool
+	pgStatStatements    bool
+	explain             bool
+	reset               bool
+	metricsAddr         string
+	storePath           string
+	slowQueryThreshold  time.Duration
+	slowQuerySampleRate float64
+	// runs counts the runs so far, across benchmarks.
+	runs int
+}
+
+// parseBenchmarkFlags registers the shared flags on flags and parses args,
+// then sets up logging and tracing. Callers register their own flags first,
+// and call shutdown once done. reset is the default of -reset, which modes
+// comparing several runs turn on so that every run starts from the same data.
+func parseBenchmarkFlags(
+	ctx context.Context, flags *flag.FlagSet, args []string, reset bool) (_ *benchmarkOptions, shutdown func(), _ error) {
+
+	o := &benchmarkOptions{config: *driver.DefaultConfig, middleware: *middleware.DefaultConfig}
+	config := &o.config
+	traceConfig := &tracing.Config{
+		ServiceName: "dbbenchmark-bench",
+		FilePath:    "bench-traces.json",
+	}
+
+	scenarioName := flags.String("scenario", "", "built-in scenario or scenario file to run; flags given explicitly override it, except -workers, and -duration when it has phases")
+	flags.IntVar(&config.Workers, "workers", config.Workers, "number of concurrent virtual users")
+	flags.DurationVar(&config.Duration, "duration", config.Duration, "how long to run the workload for")
+	flags.Int64Var(&config.Seed, "seed", config.Seed, "seed for the dataset and the workload")
+	flags.DurationVar(&config.SampleInterval, "sample-interval", config.SampleInterval, "how often to sample Postgres and host statistics")
+	flags.Var((*sloFlag)(&config.SLOs), "slo", "SLO of an operation, e.g. GetPost:p99=20ms,error_rate=0.001; may be repeated")
+	flags.Var(distributionFlag{&config.UserTargets}, "user-targets", "distribution of the users GetUser, FollowUser and GetUserFeed target: uniform, zipfian:<exponent>, latest:<exponent> or hotspot:<fraction>,<share>")
+	flags.Var(distributionFlag{&config.PostTargets}, "post-targets", "distribution of the posts GetPost and LikePost target, as for -user-targets")
+	flags.StringVar(&config.Profile.Dir, "profile-dir", "", "directory to write Go profiles of the driver to, in a directory per run; empty disables profiling")
+	flags.DurationVar(&config.Profile.Delay, "profile-delay", 5*time.Second, "time into the workload at which profiling starts")
+	flags.DurationVar(&config.Profile.Window, "profile-window", 10*time.Second, "how long to profile for")
+	flags.StringVar(&o.backend, "backend", backendPostgres, "what to run against: postgres, redis for Postgres behind an in-process Redis cache, or http")
+	flags.StringVar(&o.serverURL, "server-url", "", "URL of a running cmd/server, for the http backend; setting it selects that backend")
+	flags.StringVar(&o.isoLevel, "iso-level", "", "isolation level of write transactions, e.g. serializable")
+	flags.StringVar(&o.execMode, "exec-mode", "", "pgx query exec mode, e.g. cache_statement, exec or simple_protocol")
+	flags.StringVar(&o.feedStrategy, "feed-strategy", "", "how followed feeds are queried: join or lateral")
+	flags.IntVar(&o.maxConns, "pool-size", 0, "size of the Postgres connection pool; 0 keeps pgx's default")
+	flags.BoolVar(&o.hostStats, "host-stats", runtime.GOOS == "linux", "sample /proc for the host, the driver and local Postgres processes")
+	flags.BoolVar(&o.pgStats, "pg-stats", true, "sample Postgres statistics views during the run")
+	flags.BoolVar(&o.pgStatStatements, "pg-stat-statements", true, "report pg_stat_statements for the run, when the extension is available")
+	flags.BoolVar(&o.explain, "explain", true, "explain every query after the run and keep the plans in the result; needs local Postgres")
+	flags.BoolVar(&o.reset, "reset", reset, "truncate the tables before seeding the dataset of every run; without it, every run seeds its own users alongside the earlier ones")
+	flags.StringVar(&o.metricsAddr, "metrics-addr", "", "address to serve Prometheus /metrics on during the run")
+	flags.StringVar(&o.storePath, "store", defaultStorePath, "JSON-lines file to keep results in; empty disables it")
+	flags.DurationVar(&o.slowQueryThreshold, "slow-query-threshold", 0, "log statements slower than this; 0 disables the slow query log")
+	flags.Float64Var(&o.slowQuerySampleRate, "slow-query-sample-rate", 1, "fraction of slow statements logged")
+	o.middleware.RegisterFlags(flags)
+	logConfig := *logging.DefaultConfig
+	logConfig.RegisterFlags(flags)
+	traceConfig.RegisterFlags(flags)
+	flags.Parse(args)
+
+	if *scenarioName != "" {
+		var err error
+		o.scenario, err = scenario.Load(*scenarioName)
+		if err != nil {
+			return nil, nil, err
+		}
+		explicit := *config
+		o.scenario.Apply(config)
+		// Flags given explicitly win over the scenario. -slo adds to the SLOs
+		// of the scenario rather than replacing them. -workers and -duration
+		// cannot win over its groups and phases, so they are rejected.
+		var conflict error
+		flags.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "workers":
+				conflict = fmt.Errorf("-workers cannot be used with scenario \"%s\", whose groups set the users", o.scenario.Name)
+			case "duration":
+				if len(config.Phases) > 0 {
+					conflict = fmt.Errorf("-duration cannot be used with scenario \"%s\", whose phases set the duration", o.scenario.Name)
+				}
+			case "seed":
+				config.Seed = explicit.Seed
+			case "sample-interval":
+				config.SampleInterval = explicit.SampleInterval
+			case "user-targets":
+				config.UserTargets = explicit.UserTargets
+			case "post-targets":
+				config.PostTargets = explicit.PostTargets
+			case "slo":
+				if config.SLOs == nil {
+					config.SLOs = map[s.Operation]*driver.SLO{}
+				}
+				for op, slo := range explicit.SLOs {
+					config.SLOs[op] = slo
+				}
+			}
+		})
+		if conflict != nil {
+			return nil, nil, conflict
+		}
+	}
+	if o.serverURL != "" {
+		o.backend = backendHTTP
+	}
+
+	logger, err := logging.New(os.Stderr, &logConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	slog.SetDefault(logger)
+	o.logger = logger
+
+	shutdownTracing, err := tracing.Setup(ctx, traceConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	o.tracing = traceConfig.Exporter != tracing.ExporterNone
+	return o, func() { shutdownTracing(context.Background()) }, nil
+}
+
+// benchmark is a driver set up from benchmarkOptions.
+type benchmark struct {
+	options *benchmarkOptions
+	driver  *driver.Driver
+	// reset empties the backend, when -reset is set and the backend can be
+	// emptied.
+	reset   func(ctx context.Context) error
+	closers []func()
+}
+
+// errHTTPReset is returned for runs of the http backend set to -reset, since
+// only the server it calls can empty its database.
+var errHTTPReset = errors.New("the http backend cannot be reset; pass -reset=false to seed every run alongside the earlier ones")
+
+// newBenchmark sets up the backend and the driver described by o.
+func newBenchmark(ctx context.Context, o *benchmarkOptions) (_ *benchmark, err error) {
+	b := &benchmark{options: o}
+	defer func() {
+		if err != nil {
+			b.Close()
+		}
+	}()
+
+	m := metrics.NewMetrics()
+	tracingInterceptor := &tracing.Interceptor{Name: "driver"}
+	var d *driver.Driver
+
+	switch o.backend {
+	case backendHTTP:
+		if o.reset {
+			return nil, errHTTPReset
+		}
+		d, err = b.newDriver(httpapi.NewClient(o.serverURL), nil, m, tracingInterceptor)
+		if err != nil {
+			return nil, err
+		}
+		d.MetadataSources = append(d.MetadataSources, staticMetadata{
+			"driver.backend":    backendHTTP,
+			"driver.server_url": o.serverURL,
+		})
+
+	case backendPostgres, backendRedis:
+		serverOptions := *postgres.DefaultPGServerOptions
+		serverOptions.IsoLevel, err = postgres.ParseIsoLevel(o.isoLevel)
+		if err != nil {
+			return nil, err
+		}
+		serverOptions.ExecMode, err = postgres.ParseExecMode(o.execMode)
+		if err != nil {
+			return nil, err
+		}
+		serverOptions.FeedStrategy, err = postgres.ParseFeedStrategy(o.feedStrategy)
+		if err != nil {
+			return nil, err
+		}
+		serverOptions.MaxConns = int32(o.maxConns)
+
+		if o.tracing {
+			serverOptions.QueryTracers = append(serverOptions.QueryTracers, &tracing.QueryTracer{})
+		}
+		if o.slowQueryThreshold > 0 {
+			serverOptions.QueryTracers = append(serverOptions.QueryTracers, &postgres.SlowQueryLog{
+				Logger:     o.logger,
+				Threshold:  o.slowQueryThreshold,
+				SampleRate: o.slowQuerySampleRate,
+			})
+		}
+		queryCapture := postgres.NewQueryCapture()
+		if o.explain {
+			serverOptions.QueryTracers = append(serverOptions.QueryTracers, queryCapture)
+		}
+
+		dbManager := postgres.NewDBManager(postgres.DevConnStringOptions)
+		err = dbManager.InitDB(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if o.reset {
+			b.reset = dbManager.TruncateTables
+		}
+
+		pgServer, err := postgres.NewPGServer(ctx, postgres.DevConnStringOptions, &serverOptions)
+		if err != nil {
+			return nil, err
+		}
+		b.closers = append(b.closers, pgServer.Close)
+
+		m.ClassifyError = func(err error) string {
+			return string(postgres.ClassifyError(err))
+		}
+		m.RegisterPool(pgServer.DBPool.Stat)
+
+		var server s.Server = pgServer
+		var redisServer *cache.RedisServer
+		if o.backend == backendRedis {
+			localRedis, err := cache.StartLocalRedis()
+			if err != nil {
+				return nil, err
+			}
+			b.closers = append(b.closers, localRedis.Close)
+			redisServer = cache.NewRedisServer(pgServer, localRedis.Client, nil)
+			server = redisServer
+
+			if o.reset {
+				b.reset = func(ctx context.Context) error {
+					localRedis.Server.FlushAll()
+					return dbManager.TruncateTables(ctx)
+				}
+			}
+		}
+
+		d, err = b.newDriver(server, postgres.IsRetryable, m, tracingInterceptor)
+		if err != nil {
+			return nil, err
+		}
+		d.MetadataSources = append(d.MetadataSources, pgServer, staticMetadata{"driver.backend": o.backend})
+		d.CounterSources = append(d.CounterSources, pgServer)
+		if redisServer != nil {
+			d.MetadataSources = append(d.MetadataSources, redisServer)
+			d.CounterSources = append(d.CounterSources, redisServer)
+		}
+		d.StatementRecorders = append(d.StatementRecorders, pgServer.Statements)
+		d.Samplers = append(d.Samplers, postgres.NewPoolSampler(pgServer.DBPool.Stat))
+
+		if o.explain {
+			explainer, err := postgres.NewExplainer(ctx, postgres.DevConnStringOptions, queryCapture)
+			if err != nil {
+				return nil, err
+			}
+			b.closers = append(b.closers, func() { explainer.Close(context.Background()) })
+			d.PlanSources = append(d.PlanSources, explainer)
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported backend: \"%s\"", o.backend)
+	}
+	b.driver = d
+
+	d.MetadataSources = append(d.MetadataSources, staticMetadata{
+		"git.sha":             gitSHA(),
+		"middleware.pipeline": strings.Join(o.middleware.Pipeline, ","),
+	})
+	if o.scenario != nil {
+		d.MetadataSources = append(d.MetadataSources, staticMetadata{"driver.scenario": o.scenario.Name})
+	}
+
+	if o.hostStats {
+		d.Samplers = append(d.Samplers, host.NewProcSampler())
+	}
+
+	if o.pgStats {
+		sampler, err := postgres.NewPGStatsSampler(ctx, postgres.DevConnStringOptions)
+		if err != nil {
+			return nil, err
+		}
+		b.closers = append(b.closers, func() { sampler.Close(context.Background()) })
+		d.Samplers = append(d.Samplers, sampler)
+	}
+
+	if o.pgStatStatements {
+		statStatements, err := postgres.NewStatStatements(ctx, postgres.DevConnStringOptions)
+		if err != nil {
+			return nil, err
+		}
+		b.closers = append(b.closers, func() { statStatements.Close(context.Background()) })
+
+		available, err := statStatements.Available(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if available {
+			d.QueryStatsSources = append(d.QueryStatsSources, statStatements)
+		} else {
+			o.logger.WarnContext(ctx, "pg_stat_statements is not available, leaving it out of the result")
+		}
+	}
+
+	if o.metricsAddr != "" {
+		metricsCtx, cancelMetrics := context.WithCancel(ctx)
+		served := make(chan struct{})
+		// Waits for the server to stop, so that the next benchmark can
+		// listen on the same address.
+		b.closers = append(b.closers, func() {
+			cancelMetrics()
+			<-served
+		})
+
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m.Handler())
+		go func() {
+			defer close(served)
+			err := httpapi.Serve(metricsCtx, o.metricsAddr, mux)
+			if err != nil {
+				o.logger.ErrorContext(ctx, "serving metrics failed", "error", err)
+			}
+		}()
+	}
+
+	return b, nil
+}
+
+// newDriver returns a driver that calls server through the middleware of the
+// options, then through interceptors, outermost first. The retry stage also
+// retries the errors retryable accepts, when it is set. It seeds through
+// interceptors only, so that injected faults cannot fail seeding.
+func (b *benchmark) newDriver(
+	server s.Server, retryable func(err error) bool, interceptors ...middleware.Interceptor) (*driver.Driver, error) {
+
+	wrapped, err := middleware.Build(server, &b.options.middleware, &middleware.Dependencies{
+		Logger:    b.options.logger,
+		Retryable: retryable,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d := driver.NewDriver(middleware.Chain(wrapped, interceptors...), &b.options.config)
+	d.SeedServer = middleware.Chain(server, interceptors...)
+	return d, nil
+}
+
+// Close closes what newBenchmark set up, last first.
+func (b *benchmark) Close() {
+	for i := len(b.closers) - 1; i >= 0; i-- {
+		b.closers[i]()
+	}
+}
+
+// run empties the backend, when set to, then runs config. Runs that cannot
+// empty the backend seed their dataset alongside the earlier ones, so every
+// run after the first gets a seed of its own, keeping user names unique.
+func (b *benchmark) run(ctx context.Context, config *driver.Config) (*driver.Result, error) {
+	if b.reset != nil {
+		err := b.reset(ctx)
+		if err != nil {
+			return nil, err
+		}
+	} else if b.options.runs > 0 {
+		reseeded := *config
+		reseeded.Seed += int64(b.options.runs)
+		config = &reseeded
+	}
+	b.options.runs++
+
+	b.driver.Config = config
+	return b.driver.Run(ctx)
+}
+
+// store keeps result in the results store, unless it is disabled.
+func (b *benchmark) store(ctx context.Context, result *driver.Result) error {
+	if b.options.storePath == "" {
+		return nil
+	}
+	err := results.NewStore(b.options.storePath).Append(result)
+	if err != nil {
+		return err
+	}
+	b.options.logger.InfoContext(ctx, "stored result", "run_id", result.RunID, "store", b.options.storePath)
+	return nil
+}
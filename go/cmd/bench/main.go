@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/report"
+	"github.com/jlym/dbbenchmark/go/internal/scenario"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+func main() {
+	action := ""
+	if len(os.Args) > 1 {
+		action = os.Args[1]
+	}
+
+	err := run(action, os.Args[min(len(os.Args), 2):])
+	if err != nil {
+		slog.Error("failed", "action", action, "error", fmt.Sprintf("%+v", err))
+		os.Exit(1)
+	}
+}
+
+func run(action string, args []string) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	switch action {
+	case "run":
+		return runBenchmark(ctx, args)
+	case "search":
+		return searchRate(ctx, args)
+	case "matrix":
+		return runMatrix(ctx, args)
+	case "runs":
+		return runs(args)
+	case "scenarios":
+		return listScenarios(os.Stdout)
+	}
+	return fmt.Errorf("unsupported action: \"%s\"", action)
+}
+
+func runBenchmark(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	out := flags.String("out", "", "file to write the result to as JSON")
+	reportPath := flags.String("report", "", "file to write an HTML report of the result to")
+	options, shutdown, err := parseBenchmarkFlags(ctx, flags, args, false)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	b, err := newBenchmark(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	result, err := b.run(ctx, &options.config)
+	if err != nil {
+		return err
+	}
+
+	err = result.WriteSummary(os.Stdout)
+	if err != nil {
+		return errors.Wrap(err, "writing summary failed")
+	}
+
+	if *out != "" {
+		data, err := json.MarshalIndent(result, "", "  ")
+		if err != nil {
+			return errors.Wrap(err, "encoding result failed")
+		}
+		err = os.WriteFile(*out, data, 0o644)
+		if err != nil {
+			return errors.Wrapf(err, "writing result failed, path=\"%s\"", *out)
+		}
+	}
+
+	if *reportPath != "" {
+		err = writeFile(*reportPath, func(w io.Writer) error {
+			return report.WriteRun(w, result)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.store(ctx, result)
+	if err != nil {
+		return err
+	}
+
+	if missed := result.MissedSLOs(); len(missed) > 0 {
+		return fmt.Errorf("run %s missed %d SLO objectives, first: %s", result.RunID, len(missed), missed[0])
+	}
+	return nil
+}
+
+// staticMetadata adds fixed values to run metadata.
+type staticMetadata map[string]string
+
+func (m staticMetadata) RunMetadata() map[string]string {
+	return m
+}
+
+// sloFlag sets the SLO of an operation from a flag such as
+// GetPost:p99=20ms,error_rate=0.001.
+type sloFlag map[s.Operation]*driver.SLO
+
+func (f *sloFlag) String() string {
+	if f == nil || len(*f) == 0 {
+		return ""
+	}
+	return fmt.Sprint(len(*f), " SLOs")
+}
+
+func (f *sloFlag) Set(value string) error {
+	op, objectives, ok := strings.Cut(value, ":")
+	if !ok || !slices.Contains(s.Operations, s.Operation(op)) {
+		return fmt.Errorf("SLO must start with an operation and a colon, was \"%s\"", value)
+	}
+
+	slo := &driver.SLO{}
+	for _, objective := range strings.Split(objectives, ",") {
+		name, target, _ := strings.Cut(objective, "=")
+		var err error
+		switch name {
+		case "p50":
+			slo.P50, err = time.ParseDuration(target)
+		case "p90":
+			slo.P90, err = time.ParseDuration(target)
+		case "p99":
+			slo.P99, err = time.ParseDuration(target)
+		case "error_rate":
+			var errorRate float64
+			errorRate, err = strconv.ParseFloat(target, 64)
+			slo.ErrorRate = &errorRate
+		default:
+			return fmt.Errorf("unsupported SLO objective: \"%s\"", name)
+		}
+		if err != nil {
+			return fmt.Errorf("SLO objective %s has invalid target \"%s\": %w", name, target, err)
+		}
+	}
+
+	if *f == nil {
+		*f = sloFlag{}
+	}
+	(*f)[s.Operation(op)] = slo
+	return nil
+}
+
+// distributionFlag sets a distribution of targets from a flag such as
+// zipfian:0.99, latest:1.2 or hotspot:0.01,0.9.
+type distributionFlag struct {
+	target **driver.Distribution
+}
+
+func (f distributionFlag) String() string {
+	if f.target == nil {
+		return ""
+	}
+	return (*f.target).String()
+}
+
+func (f distributionFlag) Set(value string) error {
+	kind, params, _ := strings.Cut(value, ":")
+	d := &driver.Distribution{Kind: driver.DistributionKind(kind)}
+
+	var err error
+	switch d.Kind {
+	case driver.DistributionUniform:
+		d = nil
+	case driver.DistributionZipfian, driver.DistributionLatest:
+		d.Exponent, err = strconv.ParseFloat(params, 64)
+	case driver.DistributionHotspot:
+		fraction, share, _ := strings.Cut(params, ",")
+		d.HotFraction, err = strconv.ParseFloat(fraction, 64)
+		if err == nil {
+			d.HotShare, err = strconv.ParseFloat(share, 64)
+		}
+	default:
+		return fmt.Errorf("unsupported distribution: \"%s\"", kind)
+	}
+	if err != nil {
+		return fmt.Errorf("distribution %s has invalid parameters \"%s\": %w", kind, params, err)
+	}
+
+	*f.target = d
+	return nil
+}
+
+// listScenarios writes the built-in scenarios with their descriptions.
+func listScenarios(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "scenario\tdescription")
+	for _, name := range scenario.Builtin() {
+		sc, err := scenario.Load(name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", name, strings.Join(strings.Fields(sc.Description), " "))
+	}
+	return tw.Flush()
+}
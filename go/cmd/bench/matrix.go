@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/jlym/dbbenchmark/go/internal/report"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// matrixAxis is a setting swept by a matrix, with the values it takes.
+type matrixAxis struct {
+	name   string
+	values []string
+	// apply sets value on the options of a cell.
+	apply func(o *benchmarkOptions, value string) error
+}
+
+// matrixCell is one combination of the values of the axes.
+type matrixCell struct {
+	axes   []*matrixAxis
+	values []string
+}
+
+func (c *matrixCell) String() string {
+	parts := make([]string, len(c.axes))
+	for i, axis := range c.axes {
+		parts[i] = axis.name + "=" + c.values[i]
+	}
+	return strings.Join(parts, " ")
+}
+
+// runMatrix runs the workload once for every combination of the swept
+// settings, keeping every run in the results store, and writes a report
+// comparing them.
+func runMatrix(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("matrix", flag.ExitOnError)
+	backends := flags.String("backends", "", "comma-separated backends to sweep: postgres, redis or http")
+	poolSizes := flags.String("pool-sizes", "", "comma-separated Postgres connection pool sizes to sweep")
+	execModes := flags.String("exec-modes", "", "comma-separated pgx query exec modes to sweep")
+	isoLevels := flags.String("iso-levels", "", "comma-separated isolation levels of write transactions to sweep")
+	feedStrategies := flags.String("feed-strategies", "", "comma-separated feed strategies to sweep: join or lateral")
+	concurrency := flags.String("concurrency", "", "comma-separated numbers of virtual users to sweep; groups are scaled to each in proportion")
+	reportPath := flags.String("report", "", "file to write the HTML report of the matrix to; defaults to matrix-<id>.html")
+	options, shutdown, err := parseBenchmarkFlags(ctx, flags, args, true)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	axes := []*matrixAxis{
+		{name: "backend", values: splitList(*backends), apply: func(o *benchmarkOptions, value string) error {
+			switch value {
+			case backendPostgres, backendRedis:
+			case backendHTTP:
+				if o.serverURL == "" {
+					return fmt.Errorf("the http backend needs -server-url")
+				} else if o.reset {
+					return errHTTPReset
+				}
+			default:
+				return fmt.Errorf("unsupported backend: \"%s\"", value)
+			}
+			o.backend = value
+			return nil
+		}},
+		{name: "pool-size", values: splitList(*poolSizes), apply: func(o *benchmarkOptions, value string) error {
+			size, err := strconv.Atoi(value)
+			if err != nil || size <= 0 {
+				return fmt.Errorf("pool size must be a positive integer, was \"%s\"", value)
+			}
+			o.maxConns = size
+			return nil
+		}},
+		{name: "exec-mode", values: splitList(*execModes), apply: func(o *benchmarkOptions, value string) error {
+			_, err := postgres.ParseExecMode(value)
+			o.execMode = value
+			return err
+		}},
+		{name: "iso-level", values: splitList(*isoLevels), apply: func(o *benchmarkOptions, value string) error {
+			_, err := postgres.ParseIsoLevel(value)
+			o.isoLevel = value
+			return err
+		}},
+		{name: "feed-strategy", values: splitList(*feedStrategies), apply: func(o *benchmarkOptions, value string) error {
+			_, err := postgres.ParseFeedStrategy(value)
+			o.feedStrategy = value
+			return err
+		}},
+		{name: "concurrency", values: splitList(*concurrency), apply: func(o *benchmarkOptions, value string) error {
+			users, err := strconv.Atoi(value)
+			if err != nil || users <= 0 {
+				return fmt.Errorf("concurrency must be a positive integer, was \"%s\"", value)
+			}
+			setConcurrency(&o.config, users)
+			return nil
+		}},
+	}
+	cells := matrixCells(axes)
+	if len(cells) < 2 {
+		return fmt.Errorf("matrix needs at least two cells, set more values on -backends, -pool-sizes, -exec-modes, -iso-levels, -feed-strategies or -concurrency")
+	}
+
+	// Check every cell before running any, so that a typo fails fast.
+	for _, cell := range cells {
+		_, err := cell.options(options)
+		if err != nil {
+			return errors.Wrapf(err, "matrix cell is invalid, cell=\"%s\"", cell)
+		}
+	}
+
+	id := time.Now().UTC().Format("20060102-150405")
+	labels := make([]string, len(cells))
+	all := make([]*driver.Result, len(cells))
+	for i, cell := range cells {
+		labels[i] = cell.String()
+		options.logger.InfoContext(ctx, "running matrix cell", "matrix_id", id, "cell", labels[i], "number", i+1, "cells", len(cells))
+
+		all[i], err = runCell(ctx, options, cell, staticMetadata{
+			"matrix.id":   id,
+			"matrix.cell": labels[i],
+		})
+		if err != nil {
+			return errors.Wrapf(err, "matrix cell failed, cell=\"%s\"", labels[i])
+		}
+	}
+
+	err = writeMatrixSummary(os.Stdout, labels, all)
+	if err != nil {
+		return errors.Wrap(err, "writing summary failed")
+	}
+
+	if *reportPath == "" {
+		*reportPath = "matrix-" + id + ".html"
+	}
+	err = writeFile(*reportPath, func(w io.Writer) error {
+		return report.WriteMatrix(w, "Matrix "+id, labels, all)
+	})
+	if err != nil {
+		return err
+	}
+	options.logger.InfoContext(ctx, "wrote matrix report", "matrix_id", id, "report", *reportPath)
+	return nil
+}
+
+// runCell sets up a benchmark with the settings of cell, runs it and stores
+// the result.
+func runCell(ctx context.Context, options *benchmarkOptions, cell *matrixCell, metadata staticMetadata) (*driver.Result, error) {
+	cellOptions, err := cell.options(options)
+	if err != nil {
+		return nil, err
+	}
+	// Runs without a reset count across cells, so that every cell seeds
+	// users of its own.
+	defer func() { options.runs = cellOptions.runs }()
+
+	b, err := newBenchmark(ctx, cellOptions)
+	if err != nil {
+		return nil, err
+	}
+	defer b.Close()
+	b.driver.MetadataSources = append(b.driver.MetadataSources, metadata)
+
+	result, err := b.run(ctx, &cellOptions.config)
+	if err != nil {
+		return nil, err
+	}
+	return result, b.store(ctx, result)
+}
+
+// options returns a copy of base with the settings of the cell applied.
+func (c *matrixCell) options(base *benchmarkOptions) (*benchmarkOptions, error) {
+	o := *base
+	o.config.Groups = slices.Clone(base.config.Groups)
+	for i, axis := range c.axes {
+		err := axis.apply(&o, c.values[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &o, nil
+}
+
+// matrixCells returns the cartesian product of the values of the axes that
+// have any, varying the last axis fastest.
+func matrixCells(axes []*matrixAxis) []*matrixCell {
+	swept := []*matrixAxis{}
+	for _, axis := range axes {
+		if len(axis.values) > 0 {
+			swept = append(swept, axis)
+		}
+	}
+	if len(swept) == 0 {
+		return nil
+	}
+
+	cells := []*matrixCell{{axes: swept}}
+	for _, axis := range swept {
+		next := make([]*matrixCell, 0, len(cells)*len(axis.values))
+		for _, cell := range cells {
+			for _, value := range axis.values {
+				next = append(next, &matrixCell{
+					axes:   swept,
+					values: append(append([]string{}, cell.values...), value),
+				})
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+// setConcurrency runs config with users virtual users. Groups are scaled in
+// proportion, keeping at least one user in each.
+func setConcurrency(config *driver.Config, users int) {
+	config.Workers = users
+	total := 0
+	for _, group := range config.Groups {
+		total += group.Users
+	}
+	for i := range config.Groups {
+		scaled := float64(config.Groups[i].Users) * float64(users) / float64(total)
+		config.Groups[i].Users = max(1, int(scaled+0.5))
+	}
+}
+
+func splitList(value string) []string {
+	values := []string{}
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			values = append(values, part)
+		}
+	}
+	return values
+}
+
+// writeMatrixSummary writes a line per cell with the throughput and latency
+// of its measured calls.
+func writeMatrixSummary(w io.Writer, labels []string, all []*driver.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "cell\trun\tops/s\terrors\tp50\tp99\tSLOs")
+	for i, result := range all {
+		throughput := 0.0
+		errorCount := uint64(0)
+		total := stats.NewHistogram()
+		for _, opResult := range result.Operations {
+			throughput += opResult.Throughput
+			errorCount += opResult.Errors
+			if opResult.Latency != nil {
+				total.Merge(opResult.Latency)
+			}
+		}
+
+		slos := "-"
+		if len(result.SLOs) > 0 {
+			slos = "met"
+			if missed := result.MissedSLOs(); len(missed) > 0 {
+				slos = fmt.Sprintf("missed %d", len(missed))
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%.1f\t%d\t%s\t%s\t%s\n",
+			labels[i],
+			result.RunID,
+			throughput,
+			errorCount,
+			total.Quantile(0.5).Round(time.Microsecond),
+			total.Quantile(0.99).Round(time.Microsecond),
+			slos,
+		)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime/debug"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/report"
+	"github.com/jlym/dbbenchmark/go/internal/results"
+)
+
+const defaultStorePath = "results/runs.jsonl"
+
+// runs lists, shows, reports on, exports and compares the results kept in the results store.
+func runs(args []string) error {
+	action := ""
+	if len(args) > 0 {
+		action = args[0]
+	}
+	args = args[min(len(args), 1):]
+
+	flags := flag.NewFlagSet("runs "+action, flag.ExitOnError)
+	storePath := flags.String("store", defaultStorePath, "JSON-lines file results are kept in")
+
+	switch action {
+	case "list":
+		flags.Parse(args)
+		all, err := results.NewStore(*storePath).List()
+		if err != nil {
+			return err
+		}
+		return results.WriteList(os.Stdout, all)
+
+	case "show":
+		asJSON := flags.Bool("json", false, "print the whole result as JSON")
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			return errors.New("usage: bench runs show [-store path] [-json] <run ID>")
+		}
+
+		result, err := results.NewStore(*storePath).Get(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		if *asJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			return errors.Wrap(encoder.Encode(result), "encoding result failed")
+		}
+		return result.WriteSummary(os.Stdout)
+
+	case "report":
+		out := flags.String("out", "", "file to write the report to; defaults to <run ID>.html")
+		flags.Parse(args)
+		if flags.NArg() != 1 {
+			return errors.New("usage: bench runs report [-store path] [-out path] <run ID>")
+		}
+
+		result, err := results.NewStore(*storePath).Get(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		path := *out
+		if path == "" {
+			path = result.RunID + ".html"
+		}
+		return writeFile(path, func(w io.Writer) error {
+			return report.WriteRun(w, result)
+		})
+
+	case "export":
+		format := flags.String("format", "benchstat", "format to export in: benchstat, csv, json or influx")
+		out := flags.String("out", "", "file to write to; defaults to stdout")
+		flags.Parse(args)
+		if flags.NArg() < 1 {
+			return errors.New("usage: bench runs export [-store path] [-format benchstat|csv|json|influx] [-out path] <run ID>...")
+		}
+
+		store := results.NewStore(*storePath)
+		all := []*driver.Result{}
+		for _, runID := range flags.Args() {
+			result, err := store.Get(runID)
+			if err != nil {
+				return err
+			}
+			all = append(all, result)
+		}
+
+		var write func(w io.Writer, all []*driver.Result) error
+		switch *format {
+		case "benchstat":
+			write = results.WriteBenchstat
+		case "csv":
+			write = results.WriteCSV
+		case "json":
+			write = results.WriteJSON
+		case "influx":
+			write = results.WriteInflux
+		default:
+			return fmt.Errorf("unsupported export format: \"%s\"", *format)
+		}
+		export := func(w io.Writer) error {
+			return write(w, all)
+		}
+		if *out == "" {
+			return export(os.Stdout)
+		}
+		return writeFile(*out, export)
+
+	case "compare":
+		options := *results.DefaultCompareOptions
+		flags.Float64Var(&options.Threshold, "threshold", options.Threshold, "smallest relative change of the median latency or throughput that counts as a regression")
+		flags.Float64Var(&options.Alpha, "alpha", options.Alpha, "significance level of the Mann-Whitney tests")
+		flags.Float64Var(&options.TailThreshold, "tail-threshold", options.TailThreshold, "smallest relative change of the p99 latency that counts as a regression")
+		flags.Uint64Var(&options.MinTailCount, "min-tail-count", options.MinTailCount, "fewest calls of an operation in each run for its p99 to be judged")
+		htmlPath := flags.String("html", "", "file to write an HTML report of the comparison to")
+		flags.Parse(args)
+		if flags.NArg() != 2 {
+			return errors.New("usage: bench runs compare [-store path] [-threshold 0.05] [-alpha 0.01] [-html path] <run ID A> <run ID B>")
+		}
+
+		store := results.NewStore(*storePath)
+		a, err := store.Get(flags.Arg(0))
+		if err != nil {
+			return err
+		}
+		b, err := store.Get(flags.Arg(1))
+		if err != nil {
+			return err
+		}
+
+		comparison := results.Compare(a, b, &options)
+		err = comparison.WriteSummary(os.Stdout)
+		if err != nil {
+			return errors.Wrap(err, "writing comparison failed")
+		}
+		if *htmlPath != "" {
+			err = writeFile(*htmlPath, func(w io.Writer) error {
+				return report.WriteComparison(w, comparison, a, b)
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if regressions := comparison.Regressions(); len(regressions) > 0 {
+			return fmt.Errorf("%d operations regressed: %v", len(regressions), regressions)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported runs action: \"%s\"", action)
+}
+
+// writeFile creates path and writes it with write.
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "creating file failed, path=\"%s\"", path)
+	}
+	defer f.Close()
+
+	err = write(f)
+	if err != nil {
+		return err
+	}
+	return errors.Wrapf(f.Close(), "writing file failed, path=\"%s\"", path)
+}
+
+// gitSHA returns the commit the binary was built from, with a "-dirty"
+// suffix when it had local changes. Binaries from go run are not stamped, so
+// it falls back to asking git about the working directory. It returns "" when
+// neither works.
+func gitSHA() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		revision, modified := "", false
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				revision = setting.Value
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+		if revision != "" {
+			if modified {
+				revision += "-dirty"
+			}
+			return revision
+		}
+	}
+
+	output, err := exec.Command("git", "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	revision := strings.TrimSpace(string(output))
+	if exec.Command("git", "diff", "--quiet", "HEAD").Run() != nil {
+		revision += "-dirty"
+	}
+	return revision
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/search"
+)
+
+// searchRate searches for the highest arrival rate the workload sustains
+// within its SLOs, keeping every trial in the results store.
+func searchRate(ctx context.Context, args []string) error {
+	config := *search.DefaultConfig
+	flags := flag.NewFlagSet("search", flag.ExitOnError)
+	flags.Float64Var(&config.MinRate, "min-rate", config.MinRate, "arrival rate of the first trial, in calls per second")
+	flags.Float64Var(&config.MaxRate, "max-rate", config.MaxRate, "highest arrival rate to try, in calls per second")
+	flags.DurationVar(&config.Warmup, "trial-warmup", config.Warmup, "unmeasured warmup at the start of every trial")
+	flags.DurationVar(&config.TrialDuration, "trial-duration", config.TrialDuration, "measured part of every trial")
+	flags.Float64Var(&config.Precision, "precision", config.Precision, "fraction of the rate to narrow the search down to")
+	flags.IntVar(&config.MaxTrials, "max-trials", config.MaxTrials, "most trials to run")
+	flags.Float64Var(&config.MinAchieved, "min-achieved", config.MinAchieved, "lowest fraction of the rate a trial must sustain to pass")
+	options, shutdown, err := parseBenchmarkFlags(ctx, flags, args, true)
+	if err != nil {
+		return err
+	}
+	defer shutdown()
+
+	b, err := newBenchmark(ctx, options)
+	if err != nil {
+		return err
+	}
+	defer b.Close()
+
+	result, err := search.Search(ctx, &options.config, &config, b.run, func(trial *search.Trial) error {
+		return b.store(ctx, trial.Result)
+	})
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(result.WriteSummary(os.Stdout), "writing summary failed")
+}
@@ -2,12 +2,20 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
 	"time"
 
+	"github.com/jlym/dbbenchmark/go/internal/httpapi"
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	"github.com/jlym/dbbenchmark/go/internal/metrics"
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
 	"github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/jlym/dbbenchmark/go/internal/tracing"
 )
 
 func main() {
@@ -16,13 +24,18 @@ func main() {
 		action = os.Args[1]
 	}
 
-	err := run(action)
+	err := run(action, os.Args[min(len(os.Args), 2):])
 	if err != nil {
-		log.Fatalf("%+v\n", err)
+		slog.Error("failed", "action", action, "error", fmt.Sprintf("%+v", err))
+		os.Exit(1)
 	}
 }
 
-func run(action string) error {
+func run(action string, args []string) error {
+	if action == "serve" {
+		return serve(args)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
@@ -46,3 +59,96 @@ func run(action string) error {
 
 	return nil
 }
+
+// serve serves the feed API backed by Postgres, with Prometheus metrics on
+// /metrics and, when enabled, OpenTelemetry spans for every request, call and
+// statement. Calls go through the stages of -middleware, which by default log
+// failed calls. Slow statements are logged.
+func serve(args []string) error {
+	serverOptions := *postgres.DefaultPGServerOptions
+	traceConfig := &tracing.Config{
+		ServiceName: "dbbenchmark-server",
+		FilePath:    "server-traces.json",
+	}
+
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to serve the API and /metrics on")
+	isoLevel := flags.String("iso-level", "", "isolation level of write transactions, e.g. serializable")
+	execMode := flags.String("exec-mode", "", "pgx query exec mode, e.g. cache_statement, exec or simple_protocol")
+	feedStrategy := flags.String("feed-strategy", "", "how followed feeds are queried: join or lateral")
+	poolSize := flags.Int("pool-size", 0, "size of the Postgres connection pool; 0 keeps pgx's default")
+	slowQueryThreshold := flags.Duration("slow-query-threshold", 0, "log statements slower than this; 0 disables the slow query log")
+	slowQuerySampleRate := flags.Float64("slow-query-sample-rate", 1, "fraction of slow statements logged")
+	middlewareConfig := *middleware.DefaultConfig
+	middlewareConfig.RegisterFlags(flags)
+	logConfig := *logging.DefaultConfig
+	logConfig.RegisterFlags(flags)
+	traceConfig.RegisterFlags(flags)
+	flags.Parse(args)
+
+	logger, err := logging.New(os.Stderr, &logConfig)
+	if err != nil {
+		return err
+	}
+	slog.SetDefault(logger)
+
+	serverOptions.IsoLevel, err = postgres.ParseIsoLevel(*isoLevel)
+	if err != nil {
+		return err
+	}
+	serverOptions.ExecMode, err = postgres.ParseExecMode(*execMode)
+	if err != nil {
+		return err
+	}
+	serverOptions.FeedStrategy, err = postgres.ParseFeedStrategy(*feedStrategy)
+	if err != nil {
+		return err
+	}
+	serverOptions.MaxConns = int32(*poolSize)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	shutdownTracing, err := tracing.Setup(ctx, traceConfig)
+	if err != nil {
+		return err
+	}
+	defer shutdownTracing(context.Background())
+	if traceConfig.Exporter != tracing.ExporterNone {
+		serverOptions.QueryTracers = append(serverOptions.QueryTracers, &tracing.QueryTracer{})
+	}
+	if *slowQueryThreshold > 0 {
+		serverOptions.QueryTracers = append(serverOptions.QueryTracers, &postgres.SlowQueryLog{
+			Logger:     logger,
+			Threshold:  *slowQueryThreshold,
+			SampleRate: *slowQuerySampleRate,
+		})
+	}
+
+	pgServer, err := postgres.NewPGServer(ctx, postgres.DevConnStringOptions, &serverOptions)
+	if err != nil {
+		return err
+	}
+	defer pgServer.Close()
+
+	server, err := middleware.Build(pgServer, &middlewareConfig, &middleware.Dependencies{
+		Logger:    logger,
+		Retryable: postgres.IsRetryable,
+	})
+	if err != nil {
+		return err
+	}
+
+	m := metrics.NewMetrics()
+	m.ClassifyError = func(err error) string {
+		return string(postgres.ClassifyError(err))
+	}
+	m.RegisterPool(pgServer.DBPool.Stat)
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	mux.Handle("/", httpapi.NewHandler(middleware.Chain(server, m, &tracing.Interceptor{Name: "server"})))
+
+	logger.InfoContext(ctx, "serving", "addr", *addr)
+	return httpapi.Serve(ctx, *addr, mux)
+}
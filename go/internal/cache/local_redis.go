@@ -0,0 +1,31 @@
+package cache
+
+import (
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// LocalRedis is an in-process RESP-compatible store, used in place of a real
+// Redis by tests and local benchmarks.
+type LocalRedis struct {
+	Server *miniredis.Miniredis
+	Client *redis.Client
+}
+
+func StartLocalRedis() (*LocalRedis, error) {
+	server, err := miniredis.Run()
+	if err != nil {
+		return nil, errors.Wrap(err, "starting local redis failed")
+	}
+
+	return &LocalRedis{
+		Server: server,
+		Client: redis.NewClient(&redis.Options{Addr: server.Addr()}),
+	}, nil
+}
+
+func (l *LocalRedis) Close() {
+	l.Client.Close()
+	l.Server.Close()
+}
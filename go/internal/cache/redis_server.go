@@ -0,0 +1,492 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+var DefaultRedisOptions = &RedisOptions{
+	KeyPrefix:   "feed:",
+	UserTTL:     10 * time.Minute,
+	PostTTL:     10 * time.Minute,
+	TimelineTTL: 30 * time.Second,
+}
+
+type RedisOptions struct {
+	// KeyPrefix is prepended to every key, so several runs can share a Redis.
+	KeyPrefix string
+	UserTTL   time.Duration
+	PostTTL   time.Duration
+	// TimelineTTL bounds how stale a cached timeline head can get. Timelines
+	// are invalidated when their owner posts or their caller follows someone,
+	// but not when a followed user posts or a post is liked.
+	TimelineTTL time.Duration
+}
+
+// RedisStats counts cache hits and misses per kind of cached record.
+type RedisStats struct {
+	UserHits       atomic.Int64
+	UserMisses     atomic.Int64
+	PostHits       atomic.Int64
+	PostMisses     atomic.Int64
+	TimelineHits   atomic.Int64
+	TimelineMisses atomic.Int64
+}
+
+// RedisServer is an s.Server decorator that caches user records, posts with
+// their like counts, and the first page of timelines in a Redis-protocol
+// store. Everything it cannot answer from the cache is sent to Inner, and
+// writes go to Inner before the cache is updated. When an update fails, the
+// affected keys are deleted and the error is logged rather than returned.
+type RedisServer struct {
+	Inner   s.Server
+	Client  redis.UniversalClient
+	Options *RedisOptions
+	Stats   *RedisStats
+}
+
+// Enforce that RedisServer implements s.Server interface.
+var _ s.Server = &RedisServer{}
+
+// RunMetadata describes how the cache is configured, for run results.
+func (r *RedisServer) RunMetadata() map[string]string {
+	return map[string]string{
+		"redis.user_ttl":     r.Options.UserTTL.String(),
+		"redis.post_ttl":     r.Options.PostTTL.String(),
+		"redis.timeline_ttl": r.Options.TimelineTTL.String(),
+	}
+}
+
+// RunCounters returns the cache hits and misses so far, for run results.
+func (r *RedisServer) RunCounters() map[string]int64 {
+	return map[string]int64{
+		"redis.user_hits":       r.Stats.UserHits.Load(),
+		"redis.user_misses":     r.Stats.UserMisses.Load(),
+		"redis.post_hits":       r.Stats.PostHits.Load(),
+		"redis.post_misses":     r.Stats.PostMisses.Load(),
+		"redis.timeline_hits":   r.Stats.TimelineHits.Load(),
+		"redis.timeline_misses": r.Stats.TimelineMisses.Load(),
+	}
+}
+
+func NewRedisServer(inner s.Server, client redis.UniversalClient, options *RedisOptions) *RedisServer {
+	if options == nil {
+		options = DefaultRedisOptions
+	}
+
+	return &RedisServer{
+		Inner:   inner,
+		Client:  client,
+		Options: options,
+		Stats:   &RedisStats{},
+	}
+}
+
+func (r *RedisServer) CreateUser(
+	ctx context.Context, request *s.CreateUserRequest) (*s.CreateUserResponse, error) {
+
+	resp, err := r.Inner.CreateUser(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setUser(ctx, pipe, resp.User)
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching created user failed"), r.userKey(resp.User.UserID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) GetUser(ctx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	callerID, userID := request.CallerID, request.UserID
+	if callerID == "" || userID == "" {
+		return r.Inner.GetUser(ctx, request)
+	}
+
+	pipe := r.Client.Pipeline()
+	userCmd := pipe.HGetAll(ctx, r.userKey(userID))
+	var followedCmd *redis.StringCmd
+	if callerID != userID {
+		followedCmd = pipe.HGet(ctx, r.followsKey(callerID), userID)
+	}
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, "reading cached user failed")
+	}
+
+	user, err := parseUser(userID, userCmd.Val())
+	if err != nil {
+		return nil, err
+	}
+	if user != nil && followedCmd != nil {
+		if followedCmd.Err() == redis.Nil {
+			user = nil
+		} else {
+			user.FollowedByCaller = followedCmd.Val() == "1"
+		}
+	}
+	if user != nil {
+		r.Stats.UserHits.Add(1)
+		return &s.GetUserResponse{User: user}, nil
+	}
+	r.Stats.UserMisses.Add(1)
+
+	resp, err := r.Inner.GetUser(ctx, request)
+	if err != nil {
+		return nil, err
+	} else if resp.User == nil {
+		return resp, nil
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setUser(ctx, pipe, resp.User)
+		if callerID != userID {
+			r.setFollowed(ctx, pipe, callerID, userID, resp.User.FollowedByCaller)
+		}
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching user failed"),
+			r.userKey(userID), r.followsKey(callerID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) FollowUser(
+	ctx context.Context, request *s.FollowUserRequest) (*s.FollowUserResponse, error) {
+
+	resp, err := r.Inner.FollowUser(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setFollowed(ctx, pipe, request.CallerID, request.TargetUserID, true)
+		pipe.Del(ctx, r.followedTimelineKey(request.CallerID))
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching follow failed"),
+			r.followsKey(request.CallerID), r.followedTimelineKey(request.CallerID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) GetUserFeed(
+	ctx context.Context, request *s.GetUserFeedRequest) (*s.GetUserFeedResponse, error) {
+
+	// Only timeline heads are cached; later pages go straight to Inner.
+	if request.Cursor != "" || request.CallerID == "" || request.OwnerID == "" {
+		return r.Inner.GetUserFeed(ctx, request)
+	}
+
+	key := r.userTimelineKey(request.OwnerID)
+	field := fmt.Sprintf("%s:%d", request.CallerID, request.Limit)
+
+	cached := &s.GetUserFeedResponse{}
+	hit, err := r.getTimeline(ctx, key, field, cached)
+	if err != nil {
+		return nil, err
+	} else if hit {
+		return cached, nil
+	}
+
+	resp, err := r.Inner.GetUserFeed(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	r.setTimeline(ctx, key, field, resp)
+
+	return resp, nil
+}
+
+func (r *RedisServer) GetFollowedFeed(
+	ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+
+	if request.Cursor != "" || request.CallerID == "" {
+		return r.Inner.GetFollowedFeed(ctx, request)
+	}
+
+	key := r.followedTimelineKey(request.CallerID)
+	field := strconv.Itoa(request.Limit)
+
+	cached := &s.GetFollowedFeedResponse{}
+	hit, err := r.getTimeline(ctx, key, field, cached)
+	if err != nil {
+		return nil, err
+	} else if hit {
+		return cached, nil
+	}
+
+	resp, err := r.Inner.GetFollowedFeed(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	r.setTimeline(ctx, key, field, resp)
+
+	return resp, nil
+}
+
+func (r *RedisServer) GetFollowed(
+	ctx context.Context, request *s.GetFollowedRequest) (*s.GetFollowedResponse, error) {
+
+	return r.Inner.GetFollowed(ctx, request)
+}
+
+func (r *RedisServer) CreatePost(
+	ctx context.Context, request *s.CreatePostRequest) (*s.CreatePostResponse, error) {
+
+	resp, err := r.Inner.CreatePost(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setPost(ctx, pipe, resp.Post, request.CallerID)
+		pipe.Del(ctx, r.userTimelineKey(resp.Post.OwnerID))
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching created post failed"),
+			r.postKey(resp.Post.PostID), r.likesKey(resp.Post.PostID), r.userTimelineKey(resp.Post.OwnerID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) GetPost(ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
+	callerID, postID := request.CallerID, request.PostID
+	if callerID == "" || postID == "" {
+		return r.Inner.GetPost(ctx, request)
+	}
+
+	pipe := r.Client.Pipeline()
+	postCmd := pipe.HGetAll(ctx, r.postKey(postID))
+	likedCmd := pipe.HGet(ctx, r.likesKey(postID), callerID)
+	_, err := pipe.Exec(ctx)
+	if err != nil && err != redis.Nil {
+		return nil, errors.Wrap(err, "reading cached post failed")
+	}
+
+	post, err := parsePost(postID, postCmd.Val())
+	if err != nil {
+		return nil, err
+	}
+	if post != nil && likedCmd.Err() != redis.Nil {
+		r.Stats.PostHits.Add(1)
+		post.LikedByCaller = likedCmd.Val() == "1"
+		return &s.GetPostResponse{Post: post}, nil
+	}
+	r.Stats.PostMisses.Add(1)
+
+	resp, err := r.Inner.GetPost(ctx, request)
+	if err != nil {
+		return nil, err
+	} else if resp.Post == nil {
+		return resp, nil
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setPost(ctx, pipe, resp.Post, callerID)
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching post failed"), r.postKey(postID), r.likesKey(postID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) LikePost(ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+	resp, err := r.Inner.LikePost(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response carries the like count after the like, so it replaces
+	// whatever count was cached.
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.setPost(ctx, pipe, resp.Post, request.CallerID)
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "caching liked post failed"),
+			r.postKey(resp.Post.PostID), r.likesKey(resp.Post.PostID))
+	}
+
+	return resp, nil
+}
+
+func (r *RedisServer) getTimeline(ctx context.Context, key string, field string, resp any) (bool, error) {
+	data, err := r.Client.HGet(ctx, key, field).Bytes()
+	if err == redis.Nil {
+		r.Stats.TimelineMisses.Add(1)
+		return false, nil
+	} else if err != nil {
+		return false, errors.Wrapf(err, "reading cached timeline failed, key=\"%s\"", key)
+	}
+
+	err = json.Unmarshal(data, resp)
+	if err != nil {
+		return false, errors.Wrapf(err, "decoding cached timeline failed, key=\"%s\"", key)
+	}
+
+	r.Stats.TimelineHits.Add(1)
+	return true, nil
+}
+
+func (r *RedisServer) setTimeline(ctx context.Context, key string, field string, resp any) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		r.invalidate(ctx, errors.Wrap(err, "encoding timeline failed"), key)
+		return
+	}
+
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, data)
+		pipe.Expire(ctx, key, r.Options.TimelineTTL)
+		return nil
+	})
+	if err != nil {
+		r.invalidate(ctx, errors.Wrapf(err, "caching timeline failed, key=\"%s\"", key), key)
+	}
+}
+
+// invalidate logs err, a failed cache update, and deletes keys so that reads
+// go to Inner instead of entries the update left stale. The error is not
+// returned: by then Inner has answered, and for writes it has committed.
+func (r *RedisServer) invalidate(ctx context.Context, err error, keys ...string) {
+	slog.WarnContext(ctx, "updating cache failed", "error", err)
+
+	// The keys go even if the caller's context is done.
+	err = r.Client.Del(context.WithoutCancel(ctx), keys...).Err()
+	if err != nil {
+		slog.WarnContext(ctx, "invalidating cache failed", "keys", keys, "error", err)
+	}
+}
+
+func (r *RedisServer) setUser(ctx context.Context, pipe redis.Pipeliner, user *s.User) {
+	key := r.userKey(user.UserID)
+	pipe.HSet(ctx, key,
+		"user_name", user.UserName,
+		"role", string(user.Role),
+		"created_at", user.CreatedAt.UTC().Format(time.RFC3339Nano),
+	)
+	pipe.Expire(ctx, key, r.Options.UserTTL)
+}
+
+func (r *RedisServer) setFollowed(
+	ctx context.Context, pipe redis.Pipeliner, callerID string, userID string, followed bool) {
+
+	key := r.followsKey(callerID)
+	pipe.HSet(ctx, key, userID, formatFlag(followed))
+	pipe.Expire(ctx, key, r.Options.UserTTL)
+}
+
+// setPost caches the post and whether callerID likes it.
+func (r *RedisServer) setPost(ctx context.Context, pipe redis.Pipeliner, post *s.Post, callerID string) {
+	key := r.postKey(post.PostID)
+	pipe.HSet(ctx, key,
+		"owner_id", post.OwnerID,
+		"content", post.Content,
+		"created_at", post.CreatedAt.UTC().Format(time.RFC3339Nano),
+		"like_count", post.LikeCount,
+	)
+	pipe.Expire(ctx, key, r.Options.PostTTL)
+
+	likesKey := r.likesKey(post.PostID)
+	pipe.HSet(ctx, likesKey, callerID, formatFlag(post.LikedByCaller))
+	pipe.Expire(ctx, likesKey, r.Options.PostTTL)
+}
+
+func (r *RedisServer) userKey(userID string) string {
+	return r.Options.KeyPrefix + "user:" + userID
+}
+
+func (r *RedisServer) followsKey(callerID string) string {
+	return r.Options.KeyPrefix + "follows:" + callerID
+}
+
+func (r *RedisServer) postKey(postID string) string {
+	return r.Options.KeyPrefix + "post:" + postID
+}
+
+func (r *RedisServer) likesKey(postID string) string {
+	return r.Options.KeyPrefix + "likes:" + postID
+}
+
+func (r *RedisServer) userTimelineKey(ownerID string) string {
+	return r.Options.KeyPrefix + "timeline:user:" + ownerID
+}
+
+func (r *RedisServer) followedTimelineKey(callerID string) string {
+	return r.Options.KeyPrefix + "timeline:followed:" + callerID
+}
+
+// parseUser returns nil if fields is empty, which is how a missing hash reads.
+func parseUser(userID string, fields map[string]string) (*s.User, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, fields["created_at"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing cached user failed, userID=\"%s\"", userID)
+	}
+
+	return &s.User{
+		UserID:    userID,
+		UserName:  fields["user_name"],
+		Role:      s.Role(fields["role"]),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// parsePost returns nil if fields is empty, which is how a missing hash reads.
+func parsePost(postID string, fields map[string]string) (*s.Post, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, fields["created_at"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing cached post failed, postID=\"%s\"", postID)
+	}
+	likeCount, err := strconv.Atoi(fields["like_count"])
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing cached like count failed, postID=\"%s\"", postID)
+	}
+
+	return &s.Post{
+		PostID:    postID,
+		OwnerID:   fields["owner_id"],
+		Content:   fields["content"],
+		CreatedAt: createdAt,
+		LikeCount: likeCount,
+	}, nil
+}
+
+func formatFlag(value bool) string {
+	if value {
+		return "1"
+	}
+	return "0"
+}
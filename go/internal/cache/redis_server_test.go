@@ -0,0 +1,196 @@
+package cache_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jlym/dbbenchmark/go/internal/cache"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEnv(t *testing.T) (*cache.LocalRedis, *servertest.FakeServer, *cache.RedisServer) {
+	localRedis, err := cache.StartLocalRedis()
+	require.NoError(t, err)
+	t.Cleanup(localRedis.Close)
+
+	inner := servertest.NewFakeServer()
+	server := cache.NewRedisServer(inner, localRedis.Client, cache.DefaultRedisOptions)
+	return localRedis, inner, server
+}
+
+func getTestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func createUser(ctx context.Context, t *testing.T, server s.Server, role s.Role) *s.User {
+	resp, err := server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     role,
+	})
+	require.NoError(t, err)
+	return resp.User
+}
+
+func TestGetUser(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	_, inner, server := newTestEnv(t)
+
+	viewer := createUser(ctx, t, server, s.RoleViewer)
+	creator := createUser(ctx, t, server, s.RoleLargeCreator)
+
+	// Act: The viewer gets the creator twice. Only the first call misses.
+	for i := 0; i < 2; i++ {
+		resp, err := server.GetUser(ctx, &s.GetUserRequest{
+			CallerID: viewer.UserID,
+			UserID:   creator.UserID,
+		})
+		require.NoError(t, err)
+		require.Equal(t, creator, resp.User)
+	}
+	require.Equal(t, 1, inner.Calls(s.OpGetUser))
+	require.Equal(t, int64(1), server.Stats.UserHits.Load())
+
+	// Act: The viewer follows the creator, which updates the cached flag.
+	_, err := server.FollowUser(ctx, &s.FollowUserRequest{
+		CallerID:     viewer.UserID,
+		TargetUserID: creator.UserID,
+	})
+	require.NoError(t, err)
+
+	resp, err := server.GetUser(ctx, &s.GetUserRequest{
+		CallerID: viewer.UserID,
+		UserID:   creator.UserID,
+	})
+	require.NoError(t, err)
+	require.True(t, resp.User.FollowedByCaller)
+	require.Equal(t, 1, inner.Calls(s.OpGetUser))
+
+	// Assert: Results count the hits and misses.
+	counters := server.RunCounters()
+	require.Equal(t, int64(2), counters["redis.user_hits"])
+	require.Equal(t, int64(1), counters["redis.user_misses"])
+}
+
+func TestPostLikeCount(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	_, inner, server := newTestEnv(t)
+
+	viewer1 := createUser(ctx, t, server, s.RoleViewer)
+	viewer2 := createUser(ctx, t, server, s.RoleViewer)
+	creator := createUser(ctx, t, server, s.RoleSmallCreator)
+
+	createPostResp, err := server.CreatePost(ctx, &s.CreatePostRequest{
+		CallerID: creator.UserID,
+		Content:  gofakeit.Sentence(8),
+	})
+	require.NoError(t, err)
+	post := createPostResp.Post
+
+	// Act: Viewer 1 likes the post.
+	_, err = server.LikePost(ctx, &s.LikePostRequest{
+		CallerID: viewer1.UserID,
+		PostID:   post.PostID,
+	})
+	require.NoError(t, err)
+
+	// Assert: Viewer 1 and the creator read the new count from the cache.
+	getPostResp, err := server.GetPost(ctx, &s.GetPostRequest{
+		CallerID: viewer1.UserID,
+		PostID:   post.PostID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, getPostResp.Post.LikeCount)
+	require.True(t, getPostResp.Post.LikedByCaller)
+
+	getPostResp, err = server.GetPost(ctx, &s.GetPostRequest{
+		CallerID: creator.UserID,
+		PostID:   post.PostID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, getPostResp.Post.LikeCount)
+	require.False(t, getPostResp.Post.LikedByCaller)
+	require.Equal(t, 0, inner.Calls(s.OpGetPost))
+
+	// Assert: Viewer 2 has never seen the post, so they miss.
+	getPostResp, err = server.GetPost(ctx, &s.GetPostRequest{
+		CallerID: viewer2.UserID,
+		PostID:   post.PostID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, getPostResp.Post.LikeCount)
+	require.False(t, getPostResp.Post.LikedByCaller)
+	require.Equal(t, 1, inner.Calls(s.OpGetPost))
+}
+
+func TestTimelineHead(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	localRedis, inner, server := newTestEnv(t)
+
+	viewer := createUser(ctx, t, server, s.RoleViewer)
+	creator := createUser(ctx, t, server, s.RoleSmallCreator)
+
+	getUserFeed := func() *s.GetUserFeedResponse {
+		resp, err := server.GetUserFeed(ctx, &s.GetUserFeedRequest{
+			CallerID: viewer.UserID,
+			OwnerID:  creator.UserID,
+			Limit:    10,
+		})
+		require.NoError(t, err)
+		return resp
+	}
+
+	// Act: Read the empty timeline twice.
+	require.Empty(t, getUserFeed().Posts)
+	require.Empty(t, getUserFeed().Posts)
+	require.Equal(t, 1, inner.Calls(s.OpGetUserFeed))
+
+	// Act: The creator posts, which invalidates their timeline.
+	_, err := server.CreatePost(ctx, &s.CreatePostRequest{
+		CallerID: creator.UserID,
+		Content:  gofakeit.Sentence(8),
+	})
+	require.NoError(t, err)
+	require.Len(t, getUserFeed().Posts, 1)
+	require.Equal(t, 2, inner.Calls(s.OpGetUserFeed))
+
+	// Act: Let the cached head expire.
+	localRedis.Server.FastForward(cache.DefaultRedisOptions.TimelineTTL + time.Second)
+	require.Len(t, getUserFeed().Posts, 1)
+	require.Equal(t, 3, inner.Calls(s.OpGetUserFeed))
+}
+
+func TestCacheDown(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	localRedis, inner, server := newTestEnv(t)
+
+	creator := createUser(ctx, t, server, s.RoleSmallCreator)
+
+	// Act: Writes reach Inner while every cache command fails.
+	localRedis.Server.SetError("cache down")
+	_, err := server.CreatePost(ctx, &s.CreatePostRequest{
+		CallerID: creator.UserID,
+		Content:  gofakeit.Sentence(8),
+	})
+
+	// Assert: The write succeeds, since Inner committed it.
+	require.NoError(t, err)
+	require.Equal(t, 1, inner.Calls(s.OpCreatePost))
+
+	// Assert: Once the cache is back, reads see the post.
+	localRedis.Server.SetError("")
+	resp, err := server.GetUserFeed(ctx, &s.GetUserFeedRequest{
+		CallerID: creator.UserID,
+		OwnerID:  creator.UserID,
+		Limit:    10,
+	})
+	require.NoError(t, err)
+	require.Len(t, resp.Posts, 1)
+}
@@ -0,0 +1,205 @@
+package driver
+
+import (
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/profiling"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+var DefaultConfig = &Config{
+	Workers:  8,
+	Duration: 30 * time.Second,
+	Seed:     1,
+	// SampleInterval is short enough to line samples up with latency
+	// spikes, and long enough that sampling Postgres does not load it.
+	SampleInterval: time.Second,
+	Dataset: DatasetConfig{
+		LargeCreators:    2,
+		SmallCreators:    20,
+		Viewers:          200,
+		PostsPerCreator:  10,
+		FollowsPerViewer: 10,
+	},
+	Mix: map[s.Operation]int{
+		s.OpGetFollowedFeed: 30,
+		s.OpGetPost:         25,
+		s.OpGetUserFeed:     15,
+		s.OpGetUser:         10,
+		s.OpLikePost:        10,
+		s.OpFollowUser:      4,
+		s.OpCreatePost:      4,
+		s.OpGetFollowed:     2,
+	},
+}
+
+type Config struct {
+	// Workers is the number of concurrent virtual users. Each one sends its
+	// next request as soon as the previous one returns. It is ignored when
+	// Groups is set.
+	Workers int
+	// Duration is how long the workload runs for. It is ignored when Phases
+	// is set.
+	Duration time.Duration
+	// Seed makes the choice of operations and their targets repeatable.
+	Seed int64
+	// SampleInterval is how often Driver.Samplers are sampled during the run.
+	SampleInterval time.Duration
+	Dataset        DatasetConfig
+	// Profile captures Go profiles of the driver during the workload.
+	Profile profiling.Config
+	// Mix gives the relative weight of each operation. It is ignored when
+	// Groups is set.
+	Mix map[s.Operation]int
+	// Groups splits the virtual users into groups with their own role, mix
+	// and think time. When empty, Workers users share Mix.
+	Groups []UserGroup `json:",omitempty"`
+	// Phases splits the run into parts with their own load. When empty, the
+	// run is a single steady phase of Duration.
+	Phases []Phase `json:",omitempty"`
+	// SLOs are checked against the measured calls of each operation.
+	SLOs map[s.Operation]*SLO `json:",omitempty"`
+	// UserTargets skews which users GetUser, FollowUser and GetUserFeed
+	// target. Callers are always picked uniformly. Nil is uniform.
+	UserTargets *Distribution `json:",omitempty"`
+	// PostTargets skews which posts GetPost and LikePost target. Nil is
+	// uniform.
+	PostTargets *Distribution `json:",omitempty"`
+}
+
+// UserGroup is a set of virtual users that act alike.
+type UserGroup struct {
+	Name string
+	// Role makes each virtual user call as one user of the role, for every
+	// operation. When empty, callers are picked per call from the users that
+	// can make it.
+	Role  s.Role
+	Users int
+	// Mix gives the relative weight of each operation. It is ignored when
+	// Session is set.
+	Mix map[s.Operation]int
+	// ThinkTime is the pause between the calls of a virtual user in
+	// closed-loop phases.
+	ThinkTime ThinkTime
+	// Session makes the virtual users act in sessions, which needs Role.
+	Session *Session `json:",omitempty"`
+}
+
+// ThinkTime is a pause drawn uniformly from [Min, Max].
+type ThinkTime struct {
+	Min time.Duration
+	Max time.Duration
+}
+
+type PhaseKind string
+
+const (
+	// PhaseWarmup fills caches and connection pools. Its calls are left out
+	// of the results. With Phase.UntilStable, it ends as soon as the load is
+	// stable.
+	PhaseWarmup PhaseKind = "warmup"
+	// PhaseRamp moves the load from that of the previous phase to its own,
+	// smoothly or in Phase.Steps. Its calls are left out of the results.
+	PhaseRamp PhaseKind = "ramp"
+	// PhaseSteady holds its load. Its calls are measured.
+	PhaseSteady PhaseKind = "steady"
+	// PhaseCooldown lets the load drain. Its calls are left out of the
+	// results.
+	PhaseCooldown PhaseKind = "cooldown"
+)
+
+// Measured reports whether the calls of phases of kind k count towards the
+// results.
+func (k PhaseKind) Measured() bool {
+	return k == PhaseSteady
+}
+
+// Phase is a part of a run with its own load.
+type Phase struct {
+	// Name defaults to Kind.
+	Name     string
+	Kind     PhaseKind
+	Duration time.Duration
+	// Users is the number of active virtual users, spread over the groups in
+	// proportion to their UserGroup.Users. 0 makes every virtual user
+	// active.
+	Users int
+	// Rate makes the phase open-loop when above 0: calls arrive at Rate per
+	// second, as a Poisson process, whether or not earlier calls returned.
+	// Active virtual users then only bound how many calls are in flight, and
+	// latency counts from when a call arrived, so that time spent queued
+	// for a virtual user is not hidden.
+	Rate float64
+	// Steps makes a ramp move its load in that many equal steps rather than
+	// smoothly. Intervals are cut at every step, so each holds one load.
+	Steps int `json:",omitempty"`
+	// UntilStable ends a warmup as soon as its load is stable, with Duration
+	// as its longest. The phases after it start earlier to match.
+	UntilStable *Stability `json:",omitempty"`
+}
+
+// Stability tells when throughput and latency are stable: when their
+// coefficient of variation, the standard deviation over the mean, is at
+// most MaxCV over the last Window intervals of Config.SampleInterval.
+// Latency is the mean latency of all calls in an interval.
+type Stability struct {
+	Window int
+	MaxCV  float64
+}
+
+// SLO bounds the latency and errors of an operation. Zero latencies and a
+// nil ErrorRate are not checked.
+type SLO struct {
+	P50 time.Duration `json:",omitempty"`
+	P90 time.Duration `json:",omitempty"`
+	P99 time.Duration `json:",omitempty"`
+	// ErrorRate is the highest fraction of calls that may fail, so that 0
+	// allows none.
+	ErrorRate *float64 `json:",omitempty"`
+}
+
+// groups returns Groups, or a group of Workers users sharing Mix when it is
+// empty.
+func (c *Config) groups() []UserGroup {
+	if len(c.Groups) > 0 {
+		return c.Groups
+	}
+	return []UserGroup{{Name: "default", Users: c.Workers, Mix: c.Mix}}
+}
+
+// phases returns Phases, or a steady phase of Duration when it is empty.
+func (c *Config) phases() []Phase {
+	if len(c.Phases) > 0 {
+		return c.Phases
+	}
+	return []Phase{{Name: string(PhaseSteady), Kind: PhaseSteady, Duration: c.Duration}}
+}
+
+// TotalUsers returns the number of virtual users across groups.
+func (c *Config) TotalUsers() int {
+	total := 0
+	for _, group := range c.groups() {
+		total += group.Users
+	}
+	return total
+}
+
+// TotalDuration returns how long the workload runs for across phases, at
+// the longest, since warmups with Phase.UntilStable may end early.
+func (c *Config) TotalDuration() time.Duration {
+	var total time.Duration
+	for _, phase := range c.phases() {
+		total += phase.Duration
+	}
+	return total
+}
+
+// DatasetConfig describes the users, posts and follows created before a run.
+type DatasetConfig struct {
+	LargeCreators   int
+	SmallCreators   int
+	Viewers         int
+	PostsPerCreator int
+	// FollowsPerViewer is how many creators each viewer follows.
+	FollowsPerViewer int
+}
@@ -0,0 +1,91 @@
+package driver
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Dataset holds the IDs of the users and posts a run picks targets from.
+type Dataset struct {
+	Users    map[s.Role][]string
+	Creators []string
+	Posts    []string
+}
+
+// AllUsers returns every user ID, creators first.
+func (d *Dataset) AllUsers() []string {
+	users := []string{}
+	for _, role := range []s.Role{s.RoleLargeCreator, s.RoleSmallCreator, s.RoleViewer} {
+		users = append(users, d.Users[role]...)
+	}
+	return users
+}
+
+// SeedDataset creates the users, posts and follows described by config
+// through server.
+func SeedDataset(ctx context.Context, server s.Server, config *DatasetConfig, seed int64) (*Dataset, error) {
+	random := rand.New(rand.NewPCG(uint64(seed), 0))
+	faker := gofakeit.New(uint64(seed))
+
+	dataset := &Dataset{
+		Users: map[s.Role][]string{},
+	}
+
+	roleCounts := []struct {
+		role  s.Role
+		count int
+	}{
+		{s.RoleLargeCreator, config.LargeCreators},
+		{s.RoleSmallCreator, config.SmallCreators},
+		{s.RoleViewer, config.Viewers},
+	}
+	for _, roleCount := range roleCounts {
+		for i := 0; i < roleCount.count; i++ {
+			resp, err := server.CreateUser(ctx, &s.CreateUserRequest{
+				UserName: fmt.Sprintf("%s-%d", faker.Username(), random.Uint32()),
+				Role:     roleCount.role,
+			})
+			if err != nil {
+				return nil, errors.Wrapf(err, "creating %s user failed", roleCount.role)
+			}
+			dataset.Users[roleCount.role] = append(dataset.Users[roleCount.role], resp.User.UserID)
+		}
+	}
+	dataset.Creators = append(dataset.Creators, dataset.Users[s.RoleLargeCreator]...)
+	dataset.Creators = append(dataset.Creators, dataset.Users[s.RoleSmallCreator]...)
+
+	for _, creatorID := range dataset.Creators {
+		for i := 0; i < config.PostsPerCreator; i++ {
+			resp, err := server.CreatePost(ctx, &s.CreatePostRequest{
+				CallerID: creatorID,
+				Content:  faker.Sentence(12),
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "creating post failed")
+			}
+			dataset.Posts = append(dataset.Posts, resp.Post.PostID)
+		}
+	}
+
+	if len(dataset.Creators) > 0 {
+		for _, viewerID := range dataset.Users[s.RoleViewer] {
+			for i := 0; i < config.FollowsPerViewer; i++ {
+				_, err := server.FollowUser(ctx, &s.FollowUserRequest{
+					CallerID:     viewerID,
+					TargetUserID: dataset.Creators[random.IntN(len(dataset.Creators))],
+				})
+				if err != nil {
+					return nil, errors.Wrap(err, "following creator failed")
+				}
+			}
+		}
+	}
+
+	return dataset, nil
+}
@@ -0,0 +1,198 @@
+package driver
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+type DistributionKind string
+
+const (
+	// DistributionUniform picks every target equally often.
+	DistributionUniform DistributionKind = "uniform"
+	// DistributionZipfian picks the target of rank k in proportion to
+	// 1/k^Exponent, so that the first targets are the hottest.
+	DistributionZipfian DistributionKind = "zipfian"
+	// DistributionHotspot picks among the first HotFraction of the targets
+	// HotShare of the time, and among the others the rest of the time, each
+	// uniformly.
+	DistributionHotspot DistributionKind = "hotspot"
+	// DistributionLatest is zipfian with the ranks reversed, so that the
+	// last targets, the newest, are the hottest. Posts created during the
+	// run become the newest as they are created.
+	DistributionLatest DistributionKind = "latest"
+)
+
+// Distribution skews which targets calls pick. Targets are ranked in the
+// order they were seeded in: creators before viewers and large creators
+// before small ones, and posts by their creator in that order, oldest first,
+// then posts created during the run in the order they were created.
+type Distribution struct {
+	// Kind defaults to DistributionUniform.
+	Kind DistributionKind
+	// Exponent is the skew of zipfian and latest distributions. 0.99 is a
+	// common choice; higher is more skewed.
+	Exponent float64 `json:",omitempty"`
+	// HotFraction and HotShare shape hotspot distributions. At least one
+	// target is hot.
+	HotFraction float64 `json:",omitempty"`
+	HotShare    float64 `json:",omitempty"`
+}
+
+func (d *Distribution) String() string {
+	if d == nil {
+		return string(DistributionUniform)
+	}
+	switch d.Kind {
+	case DistributionZipfian, DistributionLatest:
+		return fmt.Sprintf("%s(%g)", d.Kind, d.Exponent)
+	case DistributionHotspot:
+		return fmt.Sprintf("%s(%g, %g)", d.Kind, d.HotFraction, d.HotShare)
+	}
+	return string(DistributionUniform)
+}
+
+func (d *Distribution) validate() error {
+	if d == nil {
+		return nil
+	}
+	switch d.Kind {
+	case "", DistributionUniform:
+	case DistributionZipfian, DistributionLatest:
+		if d.Exponent <= 0 {
+			return errors.Errorf("%s distribution must have a positive exponent, had %g", d.Kind, d.Exponent)
+		}
+	case DistributionHotspot:
+		if d.HotFraction <= 0 || d.HotFraction > 1 || d.HotShare < 0 || d.HotShare > 1 {
+			return errors.Errorf("hotspot distribution must have a hot fraction in (0, 1] and a hot share in [0, 1], had %g and %g",
+				d.HotFraction, d.HotShare)
+		}
+	default:
+		return errors.Errorf("distribution has unsupported kind \"%s\"", d.Kind)
+	}
+	return nil
+}
+
+// picker picks among n targets by a Distribution. pick is safe for
+// concurrent use, but not with grow.
+type picker struct {
+	kind DistributionKind
+	n    int
+	// cumulative holds the cumulative weights of the ranks of zipfian and
+	// latest distributions.
+	cumulative  []float64
+	exponent    float64
+	hot         int
+	hotFraction float64
+	hotShare    float64
+}
+
+func newPicker(d *Distribution, n int) *picker {
+	p := &picker{kind: DistributionUniform}
+	if d != nil {
+		switch d.Kind {
+		case DistributionZipfian, DistributionLatest:
+			p.kind = d.Kind
+			p.exponent = d.Exponent
+		case DistributionHotspot:
+			p.kind = d.Kind
+			p.hotFraction = d.HotFraction
+			p.hotShare = d.HotShare
+		}
+	}
+	p.grow(n)
+	return p
+}
+
+// grow adds targets up to n. They rank after the existing targets, so that
+// with latest distributions they are the hottest.
+func (p *picker) grow(n int) {
+	switch p.kind {
+	case DistributionZipfian, DistributionLatest:
+		total := 0.0
+		if p.n > 0 {
+			total = p.cumulative[p.n-1]
+		}
+		for k := p.n + 1; k <= n; k++ {
+			total += 1 / math.Pow(float64(k), p.exponent)
+			p.cumulative = append(p.cumulative, total)
+		}
+	case DistributionHotspot:
+		p.hot = max(1, int(p.hotFraction*float64(n)))
+	}
+	p.n = n
+}
+
+// pick returns the index of a target, or -1 when there are none.
+func (p *picker) pick(random *rand.Rand) int {
+	if p.n == 0 {
+		return -1
+	}
+
+	switch p.kind {
+	case DistributionZipfian, DistributionLatest:
+		x := random.Float64() * p.cumulative[p.n-1]
+		i := min(sort.SearchFloat64s(p.cumulative, x), p.n-1)
+		if p.kind == DistributionLatest {
+			return p.n - 1 - i
+		}
+		return i
+	case DistributionHotspot:
+		if p.hot == p.n || random.Float64() < p.hotShare {
+			return random.IntN(p.hot)
+		}
+		return p.hot + random.IntN(p.n-p.hot)
+	}
+	return random.IntN(p.n)
+}
+
+// targets picks the users and posts that calls target.
+type targets struct {
+	// users picks among every user, creators first.
+	users *picker
+	// creators picks among the creators.
+	creators *picker
+	posts    *postTargets
+}
+
+func newTargets(config *Config, dataset *Dataset) *targets {
+	return &targets{
+		users:    newPicker(config.UserTargets, len(dataset.Creators)+len(dataset.Users[s.RoleViewer])),
+		creators: newPicker(config.UserTargets, len(dataset.Creators)),
+		posts: &postTargets{
+			ids:    slices.Clone(dataset.Posts),
+			picker: newPicker(config.PostTargets, len(dataset.Posts)),
+		},
+	}
+}
+
+// postTargets picks among the seeded posts and those created during the run,
+// oldest first. It is safe for concurrent use.
+type postTargets struct {
+	lock   sync.RWMutex
+	ids    []string
+	picker *picker
+}
+
+// pick returns a post, or "" when there are none.
+func (t *postTargets) pick(random *rand.Rand) string {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+	return pickAt(t.ids, t.picker.pick(random))
+}
+
+// add adds a post created during the run.
+func (t *postTargets) add(postID string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.ids = append(t.ids, postID)
+	t.picker.grow(len(t.ids))
+}
@@ -0,0 +1,639 @@
+// Package driver runs benchmark workloads against an s.Server and collects
+// their results.
+package driver
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	"github.com/jlym/dbbenchmark/go/internal/profiling"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// MetadataSource is implemented by components that describe their
+// configuration in run results, such as postgres.PGServer.
+type MetadataSource interface {
+	RunMetadata() map[string]string
+}
+
+// CounterSource is implemented by components that keep cumulative counters,
+// such as postgres.PGServer. Results hold how much each counter grew during
+// the measured phases.
+type CounterSource interface {
+	RunCounters() map[string]int64
+}
+
+// Sampler is implemented by components that watch something outside the
+// driver during a run, such as postgres.PGStatsSampler. Results hold a sample
+// for every Config.SampleInterval of the run.
+type Sampler interface {
+	// SamplerName keys the sampler's samples in results, e.g. "pg".
+	SamplerName() string
+	// Sample returns the current values. Cumulative counters are returned as
+	// how much they grew since the previous call, so the first call only sets
+	// their baseline.
+	Sample(ctx context.Context) (map[string]float64, error)
+}
+
+// QueryStatsSource is implemented by components that read the statistics a
+// database keeps per query, such as postgres.StatStatements. Results hold how
+// much they grew during the measured phases.
+type QueryStatsSource interface {
+	// ResetQueryStats clears the statistics so that QueryStats.MaxTime only
+	// covers the measured phases. It may fail for lack of privileges, which
+	// only leaves MaxTime covering more.
+	ResetQueryStats(ctx context.Context) error
+	QueryStats(ctx context.Context) ([]*stats.QueryStats, error)
+}
+
+// PlanSource is implemented by components that explain the queries run
+// during the run, such as postgres.Explainer. It is called once the workload
+// is done, so plans reflect the dataset it left.
+type PlanSource interface {
+	QueryPlans(ctx context.Context) ([]*stats.QueryPlan, error)
+}
+
+type Driver struct {
+	Server s.Server
+	// SeedServer seeds the dataset, or Server when nil, so that seeding can
+	// bypass middleware meant for the workload, such as fault injection.
+	SeedServer      s.Server
+	Config          *Config
+	MetadataSources []MetadataSource
+	CounterSources  []CounterSource
+	// StatementRecorders, such as postgres.PGServer.Statements, are reset
+	// when the first measured phase starts, and read when the last ends.
+	StatementRecorders []*stats.StatementRecorder
+	Samplers           []Sampler
+	QueryStatsSources  []QueryStatsSource
+	PlanSources        []PlanSource
+	// Logger logs the progress of runs. Records carry the run ID when it
+	// comes from logging.New.
+	Logger *slog.Logger
+}
+
+func NewDriver(server s.Server, config *Config) *Driver {
+	if config == nil {
+		config = DefaultConfig
+	}
+
+	return &Driver{
+		Server: server,
+		Config: config,
+		Logger: slog.Default(),
+	}
+}
+
+// Run seeds the dataset, then runs the workload through Config's phases.
+func (d *Driver) Run(ctx context.Context) (*Result, error) {
+	if len(d.Config.Groups) == 0 && d.Config.Workers < 1 {
+		return nil, errors.Errorf("config.Workers must be at least 1, was %d", d.Config.Workers)
+	}
+	sched, err := newSchedule(d.Config)
+	if err != nil {
+		return nil, err
+	}
+	groups := d.Config.groups()
+	mixes := make([]*operationMix, len(groups))
+	sessions := make([]*sessionModel, len(groups))
+	for i, group := range groups {
+		if group.Session != nil {
+			if group.Role == "" {
+				return nil, errors.Errorf("group %d (%s) acts in sessions, so it needs a role", i, group.Name)
+			}
+			sessions[i], err = newSessionModel(group.Session)
+		} else {
+			mixes[i], err = newOperationMix(group.Mix)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "group %d (%s) is invalid", i, group.Name)
+		}
+	}
+
+	err = d.Config.UserTargets.validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "user targets are invalid")
+	}
+	err = d.Config.PostTargets.validate()
+	if err != nil {
+		return nil, errors.Wrap(err, "post targets are invalid")
+	}
+
+	runID := newRunID(time.Now())
+	ctx = logging.WithAttrs(ctx, slog.String("run_id", runID))
+
+	d.Logger.InfoContext(ctx, "seeding dataset")
+	seedServer := d.SeedServer
+	if seedServer == nil {
+		seedServer = d.Server
+	}
+	dataset, err := SeedDataset(ctx, seedServer, &d.Config.Dataset, d.Config.Seed)
+	if err != nil {
+		return nil, errors.Wrap(err, "seeding dataset failed")
+	}
+	for i, group := range groups {
+		if group.Role != "" && len(dataset.Users[group.Role]) == 0 {
+			return nil, errors.Errorf("group %d (%s) calls as %s users, but the dataset has none", i, group.Name, group.Role)
+		}
+	}
+
+	targets := newTargets(d.Config, dataset)
+
+	window := &measuredWindow{driver: d, sched: sched}
+	// A run measured from its start begins the window before any call.
+	window.advance(ctx, 0)
+	if window.err != nil {
+		return nil, window.err
+	}
+	recorders := make([]*stats.Recorder, len(sched.phases))
+	for i := range recorders {
+		recorders[i] = stats.NewRecorder()
+	}
+
+	d.Logger.InfoContext(ctx, "starting workload",
+		slog.Int("users", sched.totalUsers()),
+		slog.Int("phases", len(sched.phases)),
+		slog.Duration("duration", sched.duration()))
+	startedAt := time.Now()
+	runCtx, cancel := context.WithDeadline(ctx, startedAt.Add(sched.duration()))
+	defer cancel()
+
+	samples := make([][]*Sample, len(d.Samplers))
+	var samplersWG sync.WaitGroup
+	for i, sampler := range d.Samplers {
+		// Sets the baseline of the sampler's counters.
+		_, _ = sampler.Sample(ctx)
+
+		samplersWG.Add(1)
+		go func() {
+			defer samplersWG.Done()
+			samples[i] = d.runSampler(ctx, runCtx, sampler)
+		}()
+	}
+
+	intervals := &intervalCollector{
+		recorder: stats.NewRecorder(),
+		sched:    sched,
+		start:    startedAt,
+		cutAt:    startedAt,
+	}
+	samplersWG.Add(1)
+	go func() {
+		defer samplersWG.Done()
+		d.runIntervals(ctx, runCtx, intervals, window)
+	}()
+
+	var profiles []string
+	var profilingWG sync.WaitGroup
+	if d.Config.Profile.Dir != "" {
+		profilingWG.Add(1)
+		go func() {
+			defer profilingWG.Done()
+			var err error
+			dir := filepath.Join(d.Config.Profile.Dir, runID)
+			profiles, err = profiling.Capture(runCtx, &d.Config.Profile, dir)
+			if err != nil {
+				d.Logger.WarnContext(ctx, "profiling failed", slog.String("error", err.Error()))
+			}
+		}()
+	}
+
+	arrivals := make([]chan arrival, len(groups))
+	for i := range arrivals {
+		arrivals[i] = make(chan arrival, arrivalBacklog)
+	}
+	var dropped uint64
+	var dispatchWG sync.WaitGroup
+	dispatchWG.Add(1)
+	go func() {
+		defer dispatchWG.Done()
+		random := rand.New(rand.NewPCG(uint64(d.Config.Seed), uint64(sched.totalUsers())))
+		dropped = dispatch(runCtx, sched, startedAt, arrivals, random)
+	}()
+
+	var wg sync.WaitGroup
+	for g, group := range groups {
+		for i := 0; i < group.Users; i++ {
+			seed := uint64(sched.cumulativeUsers[g] + i)
+			w := &worker{
+				server:    d.Server,
+				dataset:   dataset,
+				targets:   targets,
+				sched:     sched,
+				start:     startedAt,
+				group:     g,
+				index:     i,
+				mix:       mixes[g],
+				session:   sessions[g],
+				thinkTime: group.ThinkTime,
+				random:    rand.New(rand.NewPCG(uint64(d.Config.Seed), seed)),
+				faker:     gofakeit.New(uint64(d.Config.Seed) + seed),
+				arrivals:  arrivals[g],
+				recorders: recorders,
+				intervals: intervals.recorder,
+			}
+			if group.Role != "" {
+				users := dataset.Users[group.Role]
+				w.userID = users[i%len(users)]
+			}
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.run(runCtx)
+			}()
+		}
+	}
+	wg.Wait()
+	dispatchWG.Wait()
+	endedAt := time.Now()
+	// The workload ends before runCtx's deadline when a warmup ended once
+	// stable.
+	cancel()
+	samplersWG.Wait()
+	profilingWG.Wait()
+	intervals.cut(endedAt)
+
+	if ctx.Err() != nil {
+		return nil, errors.Wrap(ctx.Err(), "run was cancelled")
+	}
+	window.end(ctx)
+	if window.err != nil {
+		return nil, window.err
+	}
+	d.Logger.InfoContext(ctx, "workload finished", slog.Duration("elapsed", endedAt.Sub(startedAt)))
+
+	result := &Result{
+		RunID:      runID,
+		StartedAt:  startedAt.UTC(),
+		EndedAt:    endedAt.UTC(),
+		Config:     d.Config,
+		Metadata:   d.collectMetadata(dataset),
+		Operations: map[s.Operation]*OperationResult{},
+		Counters:   diffCounters(window.countersBefore, window.countersAfter),
+		Intervals:  intervals.intervals,
+		Profiles:   profiles,
+	}
+	if dropped > 0 {
+		result.Counters["driver.dropped_arrivals"] = int64(dropped)
+	}
+	measured := map[s.Operation]*stats.OperationStats{}
+	for i, phase := range sched.phases {
+		start, end := sched.bounds(i)
+		phaseResult := &PhaseResult{
+			Name:       sched.phaseName(i),
+			Kind:       phase.Kind,
+			StartedAt:  startedAt.Add(start).UTC(),
+			EndedAt:    startedAt.Add(end).UTC(),
+			Stable:     sched.isStable(i),
+			Operations: map[s.Operation]*OperationResult{},
+		}
+		operations := recorders[i].Snapshot()
+		for op, opStats := range operations {
+			phaseResult.Operations[op] = newOperationResult(opStats, end-start)
+		}
+		result.Phases = append(result.Phases, phaseResult)
+
+		if phase.Kind.Measured() {
+			mergeOperations(measured, operations)
+		}
+	}
+	for op, opStats := range measured {
+		result.Operations[op] = newOperationResult(opStats, sched.measuredDuration())
+	}
+	result.SLOs = checkSLOs(d.Config.SLOs, result.Operations)
+	for _, statementStats := range window.statements {
+		result.Statements = append(result.Statements, newStatementResult(statementStats))
+	}
+	for i := range d.QueryStatsSources {
+		result.Queries = append(result.Queries, stats.DiffQueryStats(window.queriesBefore[i], window.queriesAfter[i])...)
+	}
+	for _, source := range d.PlanSources {
+		plans, err := source.QueryPlans(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result.Plans = append(result.Plans, plans...)
+	}
+	if len(d.Samplers) > 0 {
+		result.Samples = map[string][]*Sample{}
+		for i, sampler := range d.Samplers {
+			for _, sample := range samples[i] {
+				phase, _, _ := sched.at(sample.Time.Sub(startedAt))
+				sample.Phase = sched.phaseName(min(phase, len(sched.phases)-1))
+			}
+			result.Samples[sampler.SamplerName()] = samples[i]
+		}
+	}
+
+	return result, nil
+}
+
+// arrivalBacklog is how many calls of open-loop phases may wait for a
+// virtual user of a group. Calls arriving beyond it are dropped and counted
+// as driver.dropped_arrivals, since the system is then far past saturation.
+const arrivalBacklog = 10000
+
+// dispatch sends the calls of open-loop phases to the groups until runCtx is
+// done, and returns how many it dropped. Ramps change the rate as they go,
+// so arrivals are drawn at the peak rate of the phase and kept in proportion
+// to the rate at their time. Arrivals that fall behind are sent at once, so
+// that a slow driver does not lower the rate.
+func dispatch(runCtx context.Context, sched *schedule, start time.Time, arrivals []chan arrival, random *rand.Rand) uint64 {
+	var dropped uint64
+	next := start
+	for {
+		phase, progress, ok := sched.at(next.Sub(start))
+		if !ok {
+			return dropped
+		}
+		peak := sched.peakRate(phase)
+		if peak <= 0 {
+			// Closed-loop phases have no arrivals; wait for the next phase,
+			// polling since a warmup may end early once stable.
+			_, end := sched.bounds(phase)
+			sleep(runCtx, min(idlePoll, time.Until(start.Add(end))))
+			if runCtx.Err() != nil {
+				return dropped
+			}
+			next = time.Now()
+			continue
+		}
+
+		if random.Float64()*peak < sched.rate(phase, progress) {
+			if wait := time.Until(next); wait > 0 {
+				sleep(runCtx, wait)
+			}
+			if runCtx.Err() != nil {
+				return dropped
+			}
+
+			group := sched.pickGroup(random.IntN(sched.totalUsers()))
+			select {
+			case arrivals[group] <- arrival{at: next, phase: phase}:
+			default:
+				dropped++
+			}
+		}
+		next = next.Add(time.Duration(random.ExpFloat64() / peak * float64(time.Second)))
+	}
+}
+
+// intervalCollector cuts the calls recorded in recorder into intervals, and
+// ends warmups with Phase.UntilStable once their intervals are stable.
+type intervalCollector struct {
+	recorder  *stats.Recorder
+	sched     *schedule
+	start     time.Time
+	cutAt     time.Time
+	intervals []*Interval
+	// loads holds the load of each interval of loadPhase so far.
+	loads     []load
+	loadPhase int
+}
+
+// cut ends the current interval at end, and reports whether that ended its
+// phase because the load was stable. An interval without calls that lasted
+// under a millisecond, such as one cut right at the end of the run, is left
+// out.
+func (c *intervalCollector) cut(end time.Time) bool {
+	operations := c.recorder.Drain()
+	if len(operations) == 0 && end.Sub(c.cutAt) < time.Millisecond {
+		return false
+	}
+
+	interval := newInterval(operations, c.cutAt, end)
+	phase, _, _ := c.sched.at(c.cutAt.Sub(c.start))
+	phase = min(phase, len(c.sched.phases)-1)
+	interval.Phase = c.sched.phaseName(phase)
+	c.intervals = append(c.intervals, interval)
+
+	stable := false
+	if stability := c.sched.phases[phase].UntilStable; stability != nil && !c.sched.isStable(phase) {
+		if phase != c.loadPhase {
+			c.loads, c.loadPhase = nil, phase
+		}
+		c.loads = append(c.loads, newLoad(operations, end.Sub(c.cutAt)))
+		if isStable(c.loads, stability) {
+			c.sched.endStable(phase, end.Sub(c.start))
+			stable = true
+		}
+	}
+	c.cutAt = end
+	return stable
+}
+
+// measuredWindow snapshots the counters and statement and query statistics
+// of a run at the start of its first measured phase and at the end of its
+// last, so that like Result.Operations they leave out warmups and cooldowns.
+// A run without measured phases has an empty window at its end. It is not
+// safe for concurrent use.
+type measuredWindow struct {
+	driver *Driver
+	sched  *schedule
+	begun  bool
+	ended  bool
+
+	countersBefore map[string]int64
+	countersAfter  map[string]int64
+	queriesBefore  [][]*stats.QueryStats
+	queriesAfter   [][]*stats.QueryStats
+	statements     []*stats.StatementStats
+	// err is the first error reading query statistics.
+	err error
+}
+
+// advance begins or ends the window when elapsed, the time into the
+// workload, reached the start or the end of the measured phases.
+func (w *measuredWindow) advance(ctx context.Context, elapsed time.Duration) {
+	start, end, ok := w.sched.measuredSpan()
+	if !ok {
+		return
+	}
+	if elapsed >= start {
+		w.begin(ctx)
+	}
+	if elapsed >= end {
+		w.end(ctx)
+	}
+}
+
+func (w *measuredWindow) begin(ctx context.Context) {
+	if w.begun {
+		return
+	}
+	w.begun = true
+
+	d := w.driver
+	w.countersBefore = d.collectCounters()
+	for _, statementRecorder := range d.StatementRecorders {
+		statementRecorder.Reset()
+	}
+	w.queriesBefore = make([][]*stats.QueryStats, len(d.QueryStatsSources))
+	for i, source := range d.QueryStatsSources {
+		_ = source.ResetQueryStats(ctx)
+		queries, err := source.QueryStats(ctx)
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		w.queriesBefore[i] = queries
+	}
+}
+
+// end ends the window, beginning it first if the run never reached it.
+func (w *measuredWindow) end(ctx context.Context) {
+	if w.ended {
+		return
+	}
+	w.begin(ctx)
+	w.ended = true
+
+	d := w.driver
+	w.countersAfter = d.collectCounters()
+	for _, statementRecorder := range d.StatementRecorders {
+		w.statements = append(w.statements, statementRecorder.Snapshot()...)
+	}
+	w.queriesAfter = make([][]*stats.QueryStats, len(d.QueryStatsSources))
+	for i, source := range d.QueryStatsSources {
+		queries, err := source.QueryStats(ctx)
+		if err != nil && w.err == nil {
+			w.err = err
+		}
+		w.queriesAfter[i] = queries
+	}
+}
+
+// runIntervals cuts an interval at every Config.SampleInterval, and at the
+// end of every phase and ramp step, until runCtx is done, advancing window
+// at every cut. The run cuts the last one once its workers are done.
+func (d *Driver) runIntervals(
+	ctx context.Context, runCtx context.Context, collector *intervalCollector, window *measuredWindow) {
+
+	sched, start := collector.sched, collector.start
+	nextTick := start.Add(d.sampleInterval())
+	for {
+		elapsed := time.Since(start)
+		phase, _, ok := sched.at(elapsed)
+		if !ok {
+			return
+		}
+		deadline := nextTick
+		if boundary := start.Add(sched.nextBoundary(phase, elapsed)); boundary.Before(deadline) {
+			deadline = boundary
+		}
+
+		sleep(runCtx, time.Until(deadline))
+		if runCtx.Err() != nil {
+			return
+		}
+
+		now := time.Now()
+		if collector.cut(now) {
+			d.Logger.InfoContext(ctx, "load is stable, ending phase early",
+				slog.String("phase", sched.phaseName(phase)),
+				slog.Duration("elapsed", now.Sub(start)))
+		}
+		window.advance(ctx, now.Sub(start))
+		for !nextTick.After(now) {
+			nextTick = nextTick.Add(d.sampleInterval())
+		}
+	}
+}
+
+// runSampler samples sampler at every interval until runCtx is done, then
+// once more to cover the end of the run. A failed sample is kept with its
+// error rather than ending the run.
+func (d *Driver) runSampler(ctx context.Context, runCtx context.Context, sampler Sampler) []*Sample {
+	ticker := time.NewTicker(d.sampleInterval())
+	defer ticker.Stop()
+
+	samples := []*Sample{}
+	for {
+		done := false
+		select {
+		case <-ticker.C:
+		case <-runCtx.Done():
+			done = true
+		}
+		if ctx.Err() != nil {
+			return samples
+		}
+
+		values, err := sampler.Sample(ctx)
+		sample := &Sample{Time: time.Now().UTC(), Values: values}
+		if err != nil {
+			sample.Values = nil
+			sample.Error = err.Error()
+			d.Logger.WarnContext(ctx, "sampling failed",
+				slog.String("sampler", sampler.SamplerName()),
+				slog.String("error", err.Error()))
+		}
+		samples = append(samples, sample)
+
+		if done {
+			return samples
+		}
+	}
+}
+
+func (d *Driver) sampleInterval() time.Duration {
+	if d.Config.SampleInterval <= 0 {
+		return DefaultConfig.SampleInterval
+	}
+	return d.Config.SampleInterval
+}
+
+func (d *Driver) collectMetadata(dataset *Dataset) map[string]string {
+	metadata := map[string]string{
+		"driver.workers":      strconv.Itoa(d.Config.TotalUsers()),
+		"driver.duration":     d.Config.TotalDuration().String(),
+		"driver.seed":         strconv.FormatInt(d.Config.Seed, 10),
+		"driver.user_targets": d.Config.UserTargets.String(),
+		"driver.post_targets": d.Config.PostTargets.String(),
+		"dataset.users":       strconv.Itoa(len(dataset.AllUsers())),
+		"dataset.posts":       strconv.Itoa(len(dataset.Posts)),
+	}
+	for _, source := range d.MetadataSources {
+		for key, value := range source.RunMetadata() {
+			metadata[key] = value
+		}
+	}
+	return metadata
+}
+
+func (d *Driver) collectCounters() map[string]int64 {
+	counters := map[string]int64{}
+	for _, source := range d.CounterSources {
+		for key, value := range source.RunCounters() {
+			counters[key] += value
+		}
+	}
+	return counters
+}
+
+// diffCounters returns how much each counter grew, leaving out the ones that
+// did not.
+func diffCounters(before map[string]int64, after map[string]int64) map[string]int64 {
+	diff := map[string]int64{}
+	for key, value := range after {
+		if delta := value - before[key]; delta != 0 {
+			diff[key] = delta
+		}
+	}
+	return diff
+}
+
+func newRunID(now time.Time) string {
+	return fmt.Sprintf("%s-%04x", now.UTC().Format("20060102-150405"), rand.N(0x10000))
+}
@@ -0,0 +1,569 @@
+package driver_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+type stubSource struct {
+	retries int64
+	calls   int64
+}
+
+func (f *stubSource) RunMetadata() map[string]string {
+	return map[string]string{"stub.name": "stub"}
+}
+
+func (f *stubSource) RunCounters() map[string]int64 {
+	f.retries += 3
+	return map[string]int64{"stub.retries": f.retries, "stub.unchanged": 7}
+}
+
+func (f *stubSource) ResetQueryStats(ctx context.Context) error {
+	return nil
+}
+
+// QueryStats returns a query called 5 more times on every call, and one never
+// called again.
+func (f *stubSource) QueryStats(ctx context.Context) ([]*stats.QueryStats, error) {
+	f.calls += 5
+	return []*stats.QueryStats{
+		{QueryID: 1, Method: "GetPost", Calls: f.calls, TotalTime: time.Duration(f.calls) * time.Millisecond},
+		{QueryID: 2, Method: "GetUser", Calls: 10, TotalTime: time.Second},
+	}, nil
+}
+
+// callCounter counts the GetPost calls a fake server got.
+type callCounter struct {
+	server *servertest.FakeServer
+}
+
+func (c callCounter) RunCounters() map[string]int64 {
+	return map[string]int64{"fake.get_post": int64(c.server.Calls(s.OpGetPost))}
+}
+
+type stubSampler struct {
+	total float64
+}
+
+func (f *stubSampler) SamplerName() string {
+	return "stub"
+}
+
+func (f *stubSampler) Sample(ctx context.Context) (map[string]float64, error) {
+	f.total += 2
+	return map[string]float64{"stub.delta": 2, "stub.total": f.total}, nil
+}
+
+// recordingServer records the feed, post, like and user calls of every
+// caller.
+type recordingServer struct {
+	*servertest.FakeServer
+
+	lock  sync.Mutex
+	calls map[string][]recordedCall
+}
+
+// recordedCall is a call of op with the cursor it sent or the post it
+// targeted, and the page it got back.
+type recordedCall struct {
+	op     s.Operation
+	target string
+	posts  []string
+	cursor string
+}
+
+func newRecordingServer() *recordingServer {
+	return &recordingServer{FakeServer: servertest.NewFakeServer(), calls: map[string][]recordedCall{}}
+}
+
+func (f *recordingServer) record(callerID string, call recordedCall) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[callerID] = append(f.calls[callerID], call)
+}
+
+func (f *recordingServer) GetFollowedFeed(ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+	resp, err := f.FakeServer.GetFollowedFeed(ctx, request)
+	call := recordedCall{op: s.OpGetFollowedFeed, target: request.Cursor, cursor: resp.Cursor}
+	for _, post := range resp.Posts {
+		call.posts = append(call.posts, post.PostID)
+	}
+	f.record(request.CallerID, call)
+	return resp, err
+}
+
+func (f *recordingServer) GetPost(ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
+	f.record(request.CallerID, recordedCall{op: s.OpGetPost, target: request.PostID})
+	return f.FakeServer.GetPost(ctx, request)
+}
+
+func (f *recordingServer) GetUser(ctx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	f.record(request.CallerID, recordedCall{op: s.OpGetUser, target: request.UserID})
+	return f.FakeServer.GetUser(ctx, request)
+}
+
+// targets counts the calls of op by their target.
+func (f *recordingServer) targets(op s.Operation) map[string]int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	counts := map[string]int{}
+	for _, calls := range f.calls {
+		for _, call := range calls {
+			if call.op == op {
+				counts[call.target]++
+			}
+		}
+	}
+	return counts
+}
+
+func (f *recordingServer) LikePost(ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+	f.record(request.CallerID, recordedCall{op: s.OpLikePost, target: request.PostID})
+	return f.FakeServer.LikePost(ctx, request)
+}
+
+func getTestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+func TestRun(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.Workers = 4
+	config.Duration = 200 * time.Millisecond
+
+	source := &stubSource{}
+	d := driver.NewDriver(servertest.NewFakeServer(), &config)
+	d.MetadataSources = append(d.MetadataSources, source)
+	d.CounterSources = append(d.CounterSources, source)
+	d.QueryStatsSources = append(d.QueryStatsSources, source)
+	sampler := &stubSampler{}
+	d.Samplers = append(d.Samplers, sampler)
+	config.SampleInterval = 50 * time.Millisecond
+
+	result, err := d.Run(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, result.RunID)
+	require.GreaterOrEqual(t, result.Elapsed(), config.Duration)
+	require.Equal(t, "4", result.Metadata["driver.workers"])
+	require.Equal(t, "stub", result.Metadata["stub.name"])
+	require.Equal(t, map[string]int64{"stub.retries": 3}, result.Counters)
+
+	// Assert: Queries hold what grew between the snapshots before and after
+	// the workload.
+	require.Len(t, result.Queries, 1)
+	require.Equal(t, int64(1), result.Queries[0].QueryID)
+	require.Equal(t, int64(5), result.Queries[0].Calls)
+	require.Equal(t, 5*time.Millisecond, result.Queries[0].TotalTime)
+
+	// Assert: The sampler was sampled every interval and once at the end,
+	// after a baseline sample that is left out.
+	samples := result.Samples["stub"]
+	require.GreaterOrEqual(t, len(samples), 3)
+	for i, sample := range samples {
+		require.Empty(t, sample.Error)
+		require.Equal(t, 2.0, sample.Values["stub.delta"])
+		require.Equal(t, float64(2*(i+2)), sample.Values["stub.total"])
+	}
+
+	for op := range config.Mix {
+		opResult, ok := result.Operations[op]
+		require.True(t, ok, "op=%s", op)
+		require.Greater(t, opResult.Count, uint64(0), "op=%s", op)
+		require.Equal(t, uint64(0), opResult.Errors, "op=%s", op)
+		require.LessOrEqual(t, opResult.P50, opResult.Max, "op=%s", op)
+	}
+	require.NotContains(t, result.Operations, s.OpCreateUser)
+
+	// Assert: Every call falls in exactly one interval.
+	require.GreaterOrEqual(t, len(result.Intervals), 3)
+	counts := map[s.Operation]uint64{}
+	for _, interval := range result.Intervals {
+		for op, intervalOp := range interval.Operations {
+			counts[op] += intervalOp.Count
+		}
+	}
+	for op, opResult := range result.Operations {
+		require.Equal(t, opResult.Count, counts[op], "op=%s", op)
+	}
+}
+
+func TestRunPhases(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.SampleInterval = 50 * time.Millisecond
+	config.Groups = []driver.UserGroup{
+		{Name: "readers", Role: s.RoleViewer, Users: 3, Mix: map[s.Operation]int{s.OpGetPost: 1}},
+		{
+			Name:      "posters",
+			Role:      s.RoleSmallCreator,
+			Users:     1,
+			Mix:       map[s.Operation]int{s.OpCreatePost: 1},
+			ThinkTime: driver.ThinkTime{Min: 5 * time.Millisecond, Max: 10 * time.Millisecond},
+		},
+	}
+	config.Phases = []driver.Phase{
+		{Kind: driver.PhaseWarmup, Duration: 100 * time.Millisecond, Users: 2},
+		{Name: "open", Kind: driver.PhaseSteady, Duration: 200 * time.Millisecond, Rate: 200},
+		{Kind: driver.PhaseCooldown, Duration: 100 * time.Millisecond},
+	}
+	// The fake server never fails, so even an error rate of 0 is met.
+	noErrors := 0.0
+	config.SLOs = map[s.Operation]*driver.SLO{
+		s.OpGetPost: {P99: time.Second, ErrorRate: &noErrors},
+	}
+
+	d := driver.NewDriver(servertest.NewFakeServer(), &config)
+	d.Samplers = append(d.Samplers, &stubSampler{})
+
+	// Act:
+	result, err := d.Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: Only the steady phase is measured, at about its rate.
+	require.Equal(t, "4", result.Metadata["driver.workers"])
+	require.GreaterOrEqual(t, result.Elapsed(), 400*time.Millisecond)
+	var measured uint64
+	for _, opResult := range result.Operations {
+		measured += opResult.Count
+	}
+	require.InDelta(t, 40, measured, 25)
+
+	phases := map[string]bool{}
+	for _, interval := range result.Intervals {
+		phases[interval.Phase] = true
+	}
+	require.Equal(t, map[string]bool{"warmup": true, "open": true, "cooldown": true}, phases)
+
+	// Assert: Every phase has its own results.
+	require.Len(t, result.Phases, 3)
+	require.Equal(t, "open", result.Phases[1].Name)
+	require.InDelta(t, 200*time.Millisecond, result.Phases[1].Elapsed(), float64(time.Millisecond))
+	require.Greater(t, result.Phases[0].Total().Count, uint64(0))
+	require.Equal(t, measured, result.Phases[1].Total().Count)
+	require.Greater(t, result.Phases[2].Total().Count, uint64(0))
+
+	// Assert: Samples carry the phase they were taken in.
+	sampled := map[string]bool{}
+	for _, sample := range result.Samples["stub"] {
+		sampled[sample.Phase] = true
+	}
+	require.True(t, sampled["open"])
+
+	// Assert: The SLOs of GetPost were checked.
+	require.Len(t, result.SLOs, 2)
+	require.Empty(t, result.MissedSLOs())
+}
+
+func TestRunMeasuredCounters(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.SampleInterval = time.Second
+	// Think time keeps the workers from starving the goroutine that ends the
+	// measured phases.
+	config.Groups = []driver.UserGroup{{
+		Users:     2,
+		Mix:       map[s.Operation]int{s.OpGetPost: 1},
+		ThinkTime: driver.ThinkTime{Min: time.Millisecond, Max: time.Millisecond},
+	}}
+	config.Phases = []driver.Phase{
+		{Kind: driver.PhaseWarmup, Duration: 100 * time.Millisecond},
+		{Kind: driver.PhaseSteady, Duration: 100 * time.Millisecond},
+		{Kind: driver.PhaseCooldown, Duration: 100 * time.Millisecond},
+	}
+
+	server := servertest.NewFakeServer()
+	d := driver.NewDriver(server, &config)
+	d.CounterSources = append(d.CounterSources, callCounter{server})
+
+	// Act:
+	result, err := d.Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: Counters leave out the warmup and the cooldown, as the measured
+	// calls do, but for calls in flight at the ends of the steady phase.
+	measured := result.Operations[s.OpGetPost].Count
+	require.Greater(t, measured, uint64(0))
+	require.InEpsilon(t, measured, result.Counters["fake.get_post"], 0.2)
+	require.Less(t, result.Counters["fake.get_post"], int64(server.Calls(s.OpGetPost))*2/3)
+}
+
+func TestRunStableWarmup(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.SampleInterval = 20 * time.Millisecond
+	config.Groups = []driver.UserGroup{{
+		Users:     2,
+		Mix:       map[s.Operation]int{s.OpGetUser: 1},
+		ThinkTime: driver.ThinkTime{Min: time.Millisecond, Max: time.Millisecond},
+	}}
+	config.Phases = []driver.Phase{
+		{Kind: driver.PhaseWarmup, Duration: 10 * time.Second, UntilStable: &driver.Stability{Window: 3, MaxCV: 1}},
+		{Kind: driver.PhaseSteady, Duration: 100 * time.Millisecond},
+	}
+
+	// Act:
+	result, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: The warmup ended once stable, and the steady phase kept its
+	// duration.
+	require.Less(t, result.Elapsed(), 5*time.Second)
+	require.Len(t, result.Phases, 2)
+	warmup, steady := result.Phases[0], result.Phases[1]
+	require.True(t, warmup.Stable)
+	require.GreaterOrEqual(t, warmup.Elapsed(), 3*config.SampleInterval)
+	require.Equal(t, warmup.EndedAt, steady.StartedAt)
+	require.Equal(t, 100*time.Millisecond, steady.Elapsed())
+	require.Greater(t, result.Operations[s.OpGetUser].Count, uint64(0))
+}
+
+func TestRunSteppedRamp(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.SampleInterval = time.Second
+	// Think time keeps the workers from starving the goroutine that cuts
+	// intervals.
+	config.Groups = []driver.UserGroup{{
+		Users:     4,
+		Mix:       map[s.Operation]int{s.OpGetUser: 1},
+		ThinkTime: driver.ThinkTime{Min: time.Millisecond, Max: time.Millisecond},
+	}}
+	config.Phases = []driver.Phase{
+		{Kind: driver.PhaseRamp, Duration: 200 * time.Millisecond, Steps: 4},
+		{Kind: driver.PhaseSteady, Duration: 50 * time.Millisecond},
+	}
+
+	// Act:
+	result, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: An interval was cut at every step, though they are shorter
+	// than SampleInterval.
+	steps := 0
+	for _, interval := range result.Intervals {
+		if interval.Phase == "ramp" {
+			steps++
+		}
+	}
+	require.Equal(t, 4, steps)
+}
+
+func TestRunInvalidPhase(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.Phases = []driver.Phase{{Kind: "sprint", Duration: time.Second}}
+
+	// Act:
+	_, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+
+	// Assert:
+	require.ErrorContains(t, err, "unsupported kind \"sprint\"")
+}
+
+func TestRunSessions(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.Groups = []driver.UserGroup{{
+		Name:      "browsers",
+		Role:      s.RoleViewer,
+		Users:     4,
+		ThinkTime: driver.ThinkTime{Min: time.Millisecond, Max: time.Millisecond},
+		Session: &driver.Session{
+			Start: map[s.Operation]int{s.OpGetFollowedFeed: 1},
+			Transitions: map[s.Operation]map[s.Operation]int{
+				s.OpGetFollowedFeed: {s.OpGetPost: 1},
+				s.OpGetPost:         {s.OpLikePost: 1},
+				s.OpLikePost:        {s.OpGetFollowedFeed: 1},
+			},
+			Length: driver.SessionLength{Min: 6, Max: 6},
+			Pause:  driver.ThinkTime{Min: 2 * time.Millisecond, Max: 2 * time.Millisecond},
+		},
+	}}
+	config.Duration = 200 * time.Millisecond
+	server := newRecordingServer()
+
+	// Act:
+	result, err := driver.NewDriver(server, &config).Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: Every user went through sessions of two feed pages, opening a
+	// post of each page and liking the post it opened.
+	require.Len(t, server.calls, 4)
+	for callerID, calls := range server.calls {
+		for i, call := range calls {
+			switch i % 6 {
+			case 0:
+				require.Equal(t, s.OpGetFollowedFeed, call.op, "caller=%s call=%d", callerID, i)
+				require.Empty(t, call.target, "caller=%s call=%d", callerID, i)
+			case 3:
+				require.Equal(t, s.OpGetFollowedFeed, call.op, "caller=%s call=%d", callerID, i)
+				require.Equal(t, calls[i-3].cursor, call.target, "caller=%s call=%d", callerID, i)
+				require.NotEmpty(t, call.target, "caller=%s call=%d", callerID, i)
+			case 1, 4:
+				require.Equal(t, s.OpGetPost, call.op, "caller=%s call=%d", callerID, i)
+				require.Contains(t, calls[i-1].posts, call.target, "caller=%s call=%d", callerID, i)
+			case 2, 5:
+				require.Equal(t, s.OpLikePost, call.op, "caller=%s call=%d", callerID, i)
+				require.Equal(t, calls[i-1].target, call.target, "caller=%s call=%d", callerID, i)
+			}
+		}
+	}
+	require.Equal(t, uint64(0), result.Operations[s.OpLikePost].Errors)
+}
+
+func TestRunSessionWithoutRole(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.Groups = []driver.UserGroup{{
+		Users:   1,
+		Session: &driver.Session{Start: map[s.Operation]int{s.OpGetPost: 1}, Length: driver.SessionLength{Min: 1, Max: 1}},
+	}}
+
+	// Act:
+	_, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+
+	// Assert:
+	require.ErrorContains(t, err, "acts in sessions, so it needs a role")
+}
+
+// hottest returns the most common target and its share of the calls.
+func hottest(counts map[string]int) (string, float64) {
+	target, top, total := "", 0, 0
+	for candidate, count := range counts {
+		total += count
+		if count > top {
+			target, top = candidate, count
+		}
+	}
+	return target, float64(top) / float64(total)
+}
+
+func TestRunSkewedTargets(t *testing.T) {
+	runTargets := func(users *driver.Distribution, posts *driver.Distribution) (*driver.Result, *recordingServer) {
+		ctx, cancel := getTestContext()
+		defer cancel()
+
+		config := *driver.DefaultConfig
+		config.Workers = 4
+		config.Duration = 100 * time.Millisecond
+		config.Mix = map[s.Operation]int{s.OpGetUser: 1, s.OpGetPost: 1, s.OpLikePost: 1}
+		config.UserTargets = users
+		config.PostTargets = posts
+		server := newRecordingServer()
+
+		result, err := driver.NewDriver(server, &config).Run(ctx)
+		require.NoError(t, err)
+		return result, server
+	}
+
+	// Act:
+	uniform, uniformServer := runTargets(nil, nil)
+	hotspot, hotspotServer := runTargets(nil, &driver.Distribution{Kind: driver.DistributionHotspot, HotFraction: 0.001, HotShare: 1})
+	zipfian, zipfianServer := runTargets(
+		&driver.Distribution{Kind: driver.DistributionZipfian, Exponent: 2},
+		&driver.Distribution{Kind: driver.DistributionZipfian, Exponent: 2})
+	_, latestServer := runTargets(nil, &driver.Distribution{Kind: driver.DistributionLatest, Exponent: 2})
+
+	// Assert: Uniform targets are spread out.
+	require.Equal(t, "uniform", uniform.Metadata["driver.post_targets"])
+	_, share := hottest(uniformServer.targets(s.OpGetPost))
+	require.Less(t, share, 0.1)
+
+	// Assert: A hotspot of one post takes every read and like.
+	require.Equal(t, "hotspot(0.001, 1)", hotspot.Metadata["driver.post_targets"])
+	require.Len(t, hotspotServer.targets(s.OpGetPost), 1)
+	require.Len(t, hotspotServer.targets(s.OpLikePost), 1)
+	require.Equal(t, uint64(0), hotspot.Operations[s.OpLikePost].Errors)
+
+	// Assert: With an exponent of 2, the first rank takes about 61% of the
+	// calls. It is the first post of the first large creator, which is also
+	// the hotspot.
+	require.Equal(t, "zipfian(2)", zipfian.Metadata["driver.user_targets"])
+	_, share = hottest(zipfianServer.targets(s.OpGetUser))
+	require.Greater(t, share, 0.45)
+	firstPost, share := hottest(zipfianServer.targets(s.OpGetPost))
+	require.Greater(t, share, 0.45)
+	require.Contains(t, hotspotServer.targets(s.OpGetPost), firstPost)
+
+	// Assert: Latest is as skewed, towards the newest post instead.
+	latestPost, share := hottest(latestServer.targets(s.OpGetPost))
+	require.Greater(t, share, 0.45)
+	require.NotEqual(t, firstPost, latestPost)
+}
+
+func TestRunLatestCreatedPosts(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.Workers = 4
+	config.Duration = 100 * time.Millisecond
+	config.Mix = map[s.Operation]int{s.OpCreatePost: 1, s.OpGetPost: 3}
+	config.PostTargets = &driver.Distribution{Kind: driver.DistributionLatest, Exponent: 2}
+	server := newRecordingServer()
+
+	// Act:
+	_, err := driver.NewDriver(server, &config).Run(ctx)
+	require.NoError(t, err)
+
+	// Assert: The fake server numbers users and posts in the order created,
+	// and seeding creates every user and post before the run starts, so
+	// posts numbered after them were created during the run. Most reads go
+	// to those.
+	dataset := config.Dataset
+	creators := dataset.LargeCreators + dataset.SmallCreators
+	seeded := creators + dataset.Viewers + creators*dataset.PostsPerCreator
+	created, total := 0, 0
+	for postID, count := range server.targets(s.OpGetPost) {
+		var n int
+		_, err := fmt.Sscanf(postID, "post-%d", &n)
+		require.NoError(t, err)
+		if n > seeded {
+			created += count
+		}
+		total += count
+	}
+	require.Greater(t, total, 0)
+	require.Greater(t, float64(created)/float64(total), 0.5)
+}
+
+func TestRunInvalidTargets(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	config := *driver.DefaultConfig
+	config.PostTargets = &driver.Distribution{Kind: driver.DistributionZipfian}
+
+	// Act:
+	_, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+
+	// Assert:
+	require.ErrorContains(t, err, "zipfian distribution must have a positive exponent")
+}
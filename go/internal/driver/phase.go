@@ -0,0 +1,109 @@
+package driver
+
+import (
+	"math"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// PhaseResult holds the calls made during one phase of a run, measured or
+// not. Calls count towards the phase they started in, or arrived in when it
+// was open-loop.
+type PhaseResult struct {
+	Name      string
+	Kind      PhaseKind
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Stable is set when a warmup with Phase.UntilStable ended because its
+	// load was stable, rather than at its Duration.
+	Stable     bool `json:",omitempty"`
+	Operations map[s.Operation]*OperationResult
+}
+
+func (p *PhaseResult) Elapsed() time.Duration {
+	return p.EndedAt.Sub(p.StartedAt)
+}
+
+// Total sums the calls of every operation of the phase.
+func (p *PhaseResult) Total() *OperationResult {
+	total := &stats.OperationStats{Latency: stats.NewHistogram()}
+	for _, opResult := range p.Operations {
+		total.Latency.Merge(opResult.Latency)
+		total.Errors += opResult.Errors
+	}
+	return newOperationResult(total, p.Elapsed())
+}
+
+// mergeOperations adds the calls of from to into.
+func mergeOperations(into map[s.Operation]*stats.OperationStats, from map[s.Operation]*stats.OperationStats) {
+	for op, opStats := range from {
+		merged, ok := into[op]
+		if !ok {
+			merged = &stats.OperationStats{Latency: stats.NewHistogram()}
+			into[op] = merged
+		}
+		merged.Latency.Merge(opStats.Latency)
+		merged.Errors += opStats.Errors
+	}
+}
+
+// load is the throughput and mean latency of the calls of an interval.
+type load struct {
+	throughput float64
+	latency    float64
+}
+
+func newLoad(operations map[s.Operation]*stats.OperationStats, elapsed time.Duration) load {
+	var count uint64
+	var sum time.Duration
+	for _, opStats := range operations {
+		count += opStats.Latency.Total
+		sum += opStats.Latency.Sum
+	}
+	if count == 0 {
+		return load{}
+	}
+	return load{
+		throughput: perSecond(count, elapsed),
+		latency:    float64(sum) / float64(count),
+	}
+}
+
+// isStable reports whether the last Window of loads vary by at most MaxCV,
+// in both throughput and latency.
+func isStable(loads []load, stability *Stability) bool {
+	if len(loads) < stability.Window {
+		return false
+	}
+
+	window := loads[len(loads)-stability.Window:]
+	throughputs := make([]float64, len(window))
+	latencies := make([]float64, len(window))
+	for i, l := range window {
+		throughputs[i], latencies[i] = l.throughput, l.latency
+	}
+	return coefficientOfVariation(throughputs) <= stability.MaxCV &&
+		coefficientOfVariation(latencies) <= stability.MaxCV
+}
+
+// coefficientOfVariation returns the population standard deviation of
+// values over their mean, or +Inf when the mean is 0, as for intervals
+// without calls.
+func coefficientOfVariation(values []float64) float64 {
+	var sum float64
+	for _, value := range values {
+		sum += value
+	}
+	mean := sum / float64(len(values))
+	if mean == 0 {
+		return math.Inf(1)
+	}
+
+	var squares float64
+	for _, value := range values {
+		squares += (value - mean) * (value - mean)
+	}
+	return math.Sqrt(squares/float64(len(values))) / mean
+}
@@ -0,0 +1,419 @@
+package driver
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// Result is the outcome of one run.
+type Result struct {
+	RunID     string
+	StartedAt time.Time
+	EndedAt   time.Time
+	// Config is the configuration of the driver.
+	Config *Config
+	// Metadata describes the configuration of the run and of the components
+	// it ran against.
+	Metadata map[string]string
+	// Operations holds the measured calls of each operation, those made in
+	// phases whose PhaseKind.Measured.
+	Operations map[s.Operation]*OperationResult
+	// Phases holds the calls of each phase, in order.
+	Phases []*PhaseResult `json:",omitempty"`
+	// SLOs holds the outcome of checking Config.SLOs.
+	SLOs []*SLOResult `json:",omitempty"`
+	// Statements breaks the latency of operations down by the SQL
+	// statements they ran, from the start of the first measured phase to the
+	// end of the last.
+	Statements []*StatementResult
+	// Queries holds the statistics the database kept for the queries it ran
+	// from the start of the first measured phase to the end of the last.
+	Queries []*stats.QueryStats `json:",omitempty"`
+	// Plans holds the plan of each query, explained after the run.
+	Plans []*stats.QueryPlan `json:",omitempty"`
+	// Counters are the changes of the counters reported by CounterSources,
+	// such as transaction retries by error class, from the start of the
+	// first measured phase to the end of the last.
+	Counters map[string]int64
+	// Samples holds the samples taken by each of Driver.Samplers during the
+	// whole run, keyed by SamplerName.
+	Samples map[string][]*Sample `json:",omitempty"`
+	// Intervals breaks the calls of the run down by Config.SampleInterval,
+	// oldest first.
+	Intervals []*Interval `json:",omitempty"`
+	// Profiles holds the paths of the Go profiles of the driver captured
+	// during the run.
+	Profiles []string `json:",omitempty"`
+}
+
+// Sample is what a Sampler returned at Time, in Phase.
+type Sample struct {
+	Time   time.Time
+	Phase  string             `json:",omitempty"`
+	Values map[string]float64 `json:",omitempty"`
+	Error  string             `json:",omitempty"`
+}
+
+// Interval holds the calls that completed between the end of the previous
+// interval and End. Intervals are cut at the end of every phase, so each
+// belongs to one.
+type Interval struct {
+	End        time.Time
+	Elapsed    time.Duration
+	Phase      string `json:",omitempty"`
+	Operations map[s.Operation]*IntervalOperation
+}
+
+// IntervalOperation summarizes the calls of one operation in an Interval.
+type IntervalOperation struct {
+	Count  uint64
+	Errors uint64
+	P50    time.Duration
+	P90    time.Duration
+	P99    time.Duration
+}
+
+func newInterval(operations map[s.Operation]*stats.OperationStats, start time.Time, end time.Time) *Interval {
+	interval := &Interval{
+		End:        end.UTC(),
+		Elapsed:    end.Sub(start),
+		Operations: map[s.Operation]*IntervalOperation{},
+	}
+	for op, opStats := range operations {
+		interval.Operations[op] = &IntervalOperation{
+			Count:  opStats.Latency.Total,
+			Errors: opStats.Errors,
+			P50:    opStats.Latency.Quantile(0.5),
+			P90:    opStats.Latency.Quantile(0.9),
+			P99:    opStats.Latency.Quantile(0.99),
+		}
+	}
+	return interval
+}
+
+// Throughput returns the calls per second of op in the interval, or of all
+// operations when op is "".
+func (i *Interval) Throughput(op s.Operation) float64 {
+	count, _ := i.counts(op)
+	return perSecond(count, i.Elapsed)
+}
+
+// ErrorRate returns the failed calls per second of op in the interval, or
+// of all operations when op is "".
+func (i *Interval) ErrorRate(op s.Operation) float64 {
+	_, errors := i.counts(op)
+	return perSecond(errors, i.Elapsed)
+}
+
+func (i *Interval) counts(op s.Operation) (uint64, uint64) {
+	if op != "" {
+		if intervalOp, ok := i.Operations[op]; ok {
+			return intervalOp.Count, intervalOp.Errors
+		}
+		return 0, 0
+	}
+
+	var count, errors uint64
+	for _, intervalOp := range i.Operations {
+		count += intervalOp.Count
+		errors += intervalOp.Errors
+	}
+	return count, errors
+}
+
+func perSecond(count uint64, elapsed time.Duration) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(count) / elapsed.Seconds()
+}
+
+type OperationResult struct {
+	Count      uint64
+	Errors     uint64
+	Throughput float64
+	Mean       time.Duration
+	P50        time.Duration
+	P90        time.Duration
+	P99        time.Duration
+	Max        time.Duration
+	Latency    *stats.Histogram
+}
+
+func newOperationResult(opStats *stats.OperationStats, elapsed time.Duration) *OperationResult {
+	latency := opStats.Latency
+	return &OperationResult{
+		Count:      latency.Total,
+		Errors:     opStats.Errors,
+		Throughput: float64(latency.Total) / elapsed.Seconds(),
+		Mean:       latency.Mean(),
+		P50:        latency.Quantile(0.5),
+		P90:        latency.Quantile(0.9),
+		P99:        latency.Quantile(0.99),
+		Max:        latency.Max,
+		Latency:    latency,
+	}
+}
+
+type StatementResult struct {
+	Operation s.Operation
+	Statement string
+	Count     uint64
+	Errors    uint64
+	Rows      int64
+	Mean      time.Duration
+	P99       time.Duration
+	Latency   *stats.Histogram
+}
+
+func newStatementResult(statementStats *stats.StatementStats) *StatementResult {
+	latency := statementStats.Latency
+	return &StatementResult{
+		Operation: statementStats.Operation,
+		Statement: statementStats.Statement,
+		Count:     latency.Total,
+		Errors:    statementStats.Errors,
+		Rows:      statementStats.Rows,
+		Mean:      latency.Mean(),
+		P99:       latency.Quantile(0.99),
+		Latency:   latency,
+	}
+}
+
+func (r *Result) Elapsed() time.Duration {
+	return r.EndedAt.Sub(r.StartedAt)
+}
+
+// WriteSummary writes a human-readable summary of the result.
+func (r *Result) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "run\t%s\n", r.RunID)
+	fmt.Fprintf(tw, "elapsed\t%s\n", r.Elapsed().Round(time.Millisecond))
+	for _, key := range sortedKeys(r.Metadata) {
+		fmt.Fprintf(tw, "%s\t%s\n", key, r.Metadata[key])
+	}
+	if len(r.Profiles) > 0 {
+		fmt.Fprintf(tw, "profiles\t%s\n", filepath.Dir(r.Profiles[0]))
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "operation\tcount\terrors\tops/s\tmean\tp50\tp90\tp99\tmax")
+	for _, op := range s.Operations {
+		opResult, ok := r.Operations[op]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t%s\n",
+			op,
+			opResult.Count,
+			opResult.Errors,
+			opResult.Throughput,
+			formatLatency(opResult.Mean),
+			formatLatency(opResult.P50),
+			formatLatency(opResult.P90),
+			formatLatency(opResult.P99),
+			formatLatency(opResult.Max),
+		)
+	}
+
+	if len(r.Phases) > 1 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "phase\tkind\telapsed\tcount\terrors\tops/s\tp50\tp99\t")
+		for _, phase := range r.Phases {
+			total := phase.Total()
+			note := ""
+			if phase.Stable {
+				note = "ended once stable"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%d\t%.1f\t%s\t%s\t%s\n",
+				phase.Name,
+				phase.Kind,
+				phase.Elapsed().Round(time.Millisecond),
+				total.Count,
+				total.Errors,
+				total.Throughput,
+				formatLatency(total.P50),
+				formatLatency(total.P99),
+				note,
+			)
+		}
+	}
+
+	if len(r.SLOs) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "slo\tresult")
+		for _, slo := range r.SLOs {
+			outcome := "met"
+			if !slo.Met {
+				outcome = "MISSED"
+			}
+			fmt.Fprintf(tw, "%s\t%s\n", slo, outcome)
+		}
+	}
+
+	if len(r.Statements) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "operation\tstatement\tcount\terrors\trows\tmean\tp99\ttime/call")
+		for _, statement := range r.Statements {
+			// time/call spreads the statement's total time over the calls of
+			// its operation, so the column adds up to the operation's time in
+			// the database.
+			perCall := time.Duration(0)
+			if opResult, ok := r.Operations[statement.Operation]; ok && opResult.Count > 0 {
+				perCall = statement.Latency.Sum / time.Duration(opResult.Count)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%s\t%s\t%s\n",
+				statement.Operation,
+				truncate(statement.Statement, 60),
+				statement.Count,
+				statement.Errors,
+				statement.Rows,
+				formatLatency(statement.Mean),
+				formatLatency(statement.P99),
+				formatLatency(perCall),
+			)
+		}
+	}
+
+	if len(r.Queries) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "method\tquery\tcalls\tmean\tmax\trows\tblks hit\tblks read")
+		for _, query := range r.Queries {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%d\t%d\t%d\n",
+				query.Method,
+				truncate(query.Query, 60),
+				query.Calls,
+				formatLatency(query.MeanTime()),
+				formatLatency(query.MaxTime),
+				query.Rows,
+				query.SharedBlksHit,
+				query.SharedBlksRead,
+			)
+		}
+	}
+
+	if len(r.Plans) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "method\tstatement\texecution\tscans")
+		for _, plan := range r.Plans {
+			scans := strings.Join(plan.Scans(), ", ")
+			if plan.Error != "" {
+				scans = "error: " + plan.Error
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n",
+				plan.Method,
+				truncate(plan.Statement, 60),
+				formatLatency(plan.ExecutionTime),
+				scans,
+			)
+		}
+	}
+
+	if len(r.Counters) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "counter\tvalue")
+		for _, key := range sortedKeys(r.Counters) {
+			fmt.Fprintf(tw, "%s\t%d\n", key, r.Counters[key])
+		}
+	}
+
+	for _, name := range sortedKeys(r.Samples) {
+		summaries := summarizeSamples(r.measuredSamples(r.Samples[name]))
+		if len(summaries) == 0 {
+			continue
+		}
+		fmt.Fprintln(tw)
+		fmt.Fprintf(tw, "%s sample (measured)\tmean\tmax\n", name)
+		for _, key := range sortedKeys(summaries) {
+			fmt.Fprintf(tw, "%s\t%.1f\t%.1f\n", key, summaries[key].mean, summaries[key].max)
+		}
+	}
+
+	return tw.Flush()
+}
+
+// measuredSamples returns the samples taken in measured phases. Samples of
+// results without phases are all measured.
+func (r *Result) measuredSamples(samples []*Sample) []*Sample {
+	if len(r.Phases) == 0 {
+		return samples
+	}
+	measured := map[string]bool{}
+	for _, phase := range r.Phases {
+		measured[phase.Name] = phase.Kind.Measured()
+	}
+
+	kept := []*Sample{}
+	for _, sample := range samples {
+		if measured[sample.Phase] {
+			kept = append(kept, sample)
+		}
+	}
+	return kept
+}
+
+type sampleSummary struct {
+	mean float64
+	max  float64
+}
+
+// summarizeSamples returns the mean and max of every value that was ever
+// non-zero.
+func summarizeSamples(samples []*Sample) map[string]*sampleSummary {
+	sums := map[string]float64{}
+	summaries := map[string]*sampleSummary{}
+	count := 0
+	for _, sample := range samples {
+		if sample.Error != "" {
+			continue
+		}
+		count++
+		for key, value := range sample.Values {
+			sums[key] += value
+			summary, ok := summaries[key]
+			if !ok {
+				summary = &sampleSummary{max: value}
+				summaries[key] = summary
+			}
+			summary.max = max(summary.max, value)
+		}
+	}
+
+	for key, summary := range summaries {
+		if summary.max == 0 && sums[key] == 0 {
+			delete(summaries, key)
+			continue
+		}
+		summary.mean = sums[key] / float64(count)
+	}
+	return summaries
+}
+
+func formatLatency(latency time.Duration) string {
+	return latency.Round(time.Microsecond).String()
+}
+
+func truncate(value string, length int) string {
+	if len(value) <= length {
+		return value
+	}
+	return value[:length-3] + "..."
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
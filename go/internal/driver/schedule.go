@@ -0,0 +1,261 @@
+package driver
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// schedule tells what load a run should be under at each point of it. It is
+// safe for concurrent use.
+type schedule struct {
+	phases []Phase
+	// cumulativeUsers holds the users of the groups before each group, and
+	// of all of them last.
+	cumulativeUsers []int
+
+	// lock guards ends and stable, which change when a warmup ends once its
+	// load is stable.
+	lock sync.RWMutex
+	// ends holds the end of each phase, as an offset from the start of the
+	// workload.
+	ends []time.Duration
+	// stable holds whether each phase ended because its load was stable.
+	stable []bool
+}
+
+func newSchedule(config *Config) (*schedule, error) {
+	sched := &schedule{
+		phases:          config.phases(),
+		cumulativeUsers: []int{0},
+	}
+
+	for i, group := range config.groups() {
+		if group.Users < 1 {
+			return nil, errors.Errorf("group %d (%s) must have at least 1 user, had %d", i, group.Name, group.Users)
+		}
+		sched.cumulativeUsers = append(sched.cumulativeUsers, sched.cumulativeUsers[i]+group.Users)
+	}
+
+	var end time.Duration
+	for i, phase := range sched.phases {
+		switch phase.Kind {
+		case PhaseWarmup, PhaseRamp, PhaseSteady, PhaseCooldown:
+		default:
+			return nil, errors.Errorf("phase %d has unsupported kind \"%s\"", i, phase.Kind)
+		}
+		if phase.Duration <= 0 {
+			return nil, errors.Errorf("phase %d (%s) must have a positive duration, had %s", i, phase.Kind, phase.Duration)
+		}
+		if phase.Users < 0 || phase.Rate < 0 {
+			return nil, errors.Errorf("phase %d (%s) must not have negative users or rate", i, phase.Kind)
+		}
+		if phase.Steps < 0 || (phase.Steps > 0 && phase.Kind != PhaseRamp) {
+			return nil, errors.Errorf("phase %d (%s) may only have steps when it is a ramp, had %d", i, phase.Kind, phase.Steps)
+		}
+		if stability := phase.UntilStable; stability != nil {
+			if phase.Kind != PhaseWarmup {
+				return nil, errors.Errorf("phase %d (%s) may only run until stable when it is a warmup", i, phase.Kind)
+			}
+			if stability.Window < 2 || stability.MaxCV <= 0 {
+				return nil, errors.Errorf("phase %d (%s) must have a stability window of at least 2 and a positive max CV, had %d and %g",
+					i, phase.Kind, stability.Window, stability.MaxCV)
+			}
+		}
+		end += phase.Duration
+		sched.ends = append(sched.ends, end)
+	}
+	sched.stable = make([]bool, len(sched.phases))
+	return sched, nil
+}
+
+func (sc *schedule) duration() time.Duration {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.ends[len(sc.ends)-1]
+}
+
+func (sc *schedule) totalUsers() int {
+	return sc.cumulativeUsers[len(sc.cumulativeUsers)-1]
+}
+
+// at returns the phase at elapsed and how far into it elapsed is, from 0 to
+// 1. ok is false once the last phase is over.
+func (sc *schedule) at(elapsed time.Duration) (phase int, progress float64, ok bool) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+
+	var start time.Duration
+	for i, end := range sc.ends {
+		if elapsed < end {
+			return i, float64(elapsed-start) / float64(end-start), true
+		}
+		start = end
+	}
+	return len(sc.ends), 1, false
+}
+
+// bounds returns the start and end of phase.
+func (sc *schedule) bounds(phase int) (time.Duration, time.Duration) {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+
+	var start time.Duration
+	if phase > 0 {
+		start = sc.ends[phase-1]
+	}
+	return start, sc.ends[phase]
+}
+
+// nextBoundary returns the end of the step of a stepped ramp, or else of the
+// phase, that elapsed falls in.
+func (sc *schedule) nextBoundary(phase int, elapsed time.Duration) time.Duration {
+	start, end := sc.bounds(phase)
+	steps := sc.phases[phase].Steps
+	if steps == 0 {
+		return end
+	}
+
+	step := (end - start) / time.Duration(steps)
+	boundary := start + ((elapsed-start)/step+1)*step
+	return min(boundary, end)
+}
+
+// endStable ends phase at elapsed because its load is stable, and moves the
+// phases after it earlier to match.
+func (sc *schedule) endStable(phase int, elapsed time.Duration) {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	shift := sc.ends[phase] - elapsed
+	if shift <= 0 {
+		return
+	}
+	for i := phase; i < len(sc.ends); i++ {
+		sc.ends[i] -= shift
+	}
+	sc.stable[phase] = true
+}
+
+func (sc *schedule) isStable(phase int) bool {
+	sc.lock.RLock()
+	defer sc.lock.RUnlock()
+	return sc.stable[phase]
+}
+
+// users returns how many virtual users are active in phase at progress.
+// Ramps move from the users of the previous phase, or none for the first
+// phase, to their own.
+func (sc *schedule) users(phase int, progress float64) int {
+	target := sc.phaseUsers(phase)
+	if sc.phases[phase].Kind != PhaseRamp {
+		return target
+	}
+
+	from := 0
+	if phase > 0 {
+		from = sc.phaseUsers(phase - 1)
+	}
+	return int(math.Round(float64(from) + sc.step(phase, progress)*float64(target-from)))
+}
+
+func (sc *schedule) phaseUsers(phase int) int {
+	if users := sc.phases[phase].Users; users > 0 {
+		return min(users, sc.totalUsers())
+	}
+	return sc.totalUsers()
+}
+
+// rate returns the arrival rate of phase at progress, or 0 when it is
+// closed-loop. Ramps move from the rate of the previous phase, or 0 for the
+// first phase, to their own.
+func (sc *schedule) rate(phase int, progress float64) float64 {
+	target := sc.phases[phase].Rate
+	if sc.phases[phase].Kind != PhaseRamp || target == 0 {
+		return target
+	}
+
+	from := 0.0
+	if phase > 0 {
+		from = sc.phases[phase-1].Rate
+	}
+	return from + sc.step(phase, progress)*(target-from)
+}
+
+// step returns how far a ramp has moved its load at progress. Stepped ramps
+// reach the load of each step as it starts, so the last step holds the
+// ramp's own.
+func (sc *schedule) step(phase int, progress float64) float64 {
+	steps := float64(sc.phases[phase].Steps)
+	if steps == 0 {
+		return progress
+	}
+	return min(math.Floor(progress*steps)+1, steps) / steps
+}
+
+// peakRate returns the highest arrival rate of phase, or 0 when it is
+// closed-loop.
+func (sc *schedule) peakRate(phase int) float64 {
+	return max(sc.rate(phase, 0), sc.rate(phase, 1))
+}
+
+// groupUsers returns how many of users are in group, in proportion to the
+// group's UserGroup.Users.
+func (sc *schedule) groupUsers(users int, group int) int {
+	total := sc.totalUsers()
+	return users*sc.cumulativeUsers[group+1]/total - users*sc.cumulativeUsers[group]/total
+}
+
+// pickGroup picks a group in proportion to its UserGroup.Users, given n
+// drawn uniformly from [0, totalUsers).
+func (sc *schedule) pickGroup(n int) int {
+	for group := 0; group < len(sc.cumulativeUsers)-1; group++ {
+		if n < sc.cumulativeUsers[group+1] {
+			return group
+		}
+	}
+	return len(sc.cumulativeUsers) - 2
+}
+
+// measuredDuration returns the total duration of the phases whose calls are
+// measured.
+func (sc *schedule) measuredDuration() time.Duration {
+	var measured time.Duration
+	for i, phase := range sc.phases {
+		if phase.Kind.Measured() {
+			start, end := sc.bounds(i)
+			measured += end - start
+		}
+	}
+	return measured
+}
+
+// measuredSpan returns the start of the first measured phase and the end of
+// the last one. ok is false when no phase is measured.
+func (sc *schedule) measuredSpan() (start time.Duration, end time.Duration, ok bool) {
+	first, last := -1, -1
+	for i, phase := range sc.phases {
+		if phase.Kind.Measured() {
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return 0, 0, false
+	}
+
+	start, _ = sc.bounds(first)
+	_, end = sc.bounds(last)
+	return start, end, true
+}
+
+func (sc *schedule) phaseName(phase int) string {
+	if name := sc.phases[phase].Name; name != "" {
+		return name
+	}
+	return string(sc.phases[phase].Kind)
+}
@@ -0,0 +1,179 @@
+package driver
+
+import (
+	"math/rand/v2"
+
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Session makes the virtual users of a group act in sessions, as one user of
+// the group's role, rather than pick every call from a mix. A session starts
+// with an operation drawn from Start, then moves from each operation to the
+// next by the weights of Transitions, as a Markov chain. It ends once it
+// made Length calls, or after an operation with no transitions.
+//
+// Calls in a session follow on from each other: posts are opened from the
+// feed page read last, likes go to the post opened last, feeds are paged on
+// from the page read last, and users are those whose post or feed was read
+// last.
+type Session struct {
+	Start       map[s.Operation]int
+	Transitions map[s.Operation]map[s.Operation]int
+	Length      SessionLength
+	// Pause is the pause between sessions in closed-loop phases. Calls
+	// within a session are apart by the group's ThinkTime.
+	Pause ThinkTime
+}
+
+// SessionLength bounds the calls of a session, drawn uniformly from
+// [Min, Max].
+type SessionLength struct {
+	Min int
+	Max int
+}
+
+// sessionModel is a Session ready to draw from.
+type sessionModel struct {
+	start       *operationMix
+	transitions map[s.Operation]*operationMix
+	length      SessionLength
+	pause       ThinkTime
+}
+
+func newSessionModel(session *Session) (*sessionModel, error) {
+	if session.Length.Min < 1 || session.Length.Max < session.Length.Min {
+		return nil, errors.Errorf("session length must be at least 1 and in order, was %d to %d", session.Length.Min, session.Length.Max)
+	}
+
+	model := &sessionModel{
+		transitions: map[s.Operation]*operationMix{},
+		length:      session.Length,
+		pause:       session.Pause,
+	}
+	var err error
+	model.start, err = newOperationMix(session.Start)
+	if err != nil {
+		return nil, errors.Wrap(err, "session start is invalid")
+	}
+	for op, weights := range session.Transitions {
+		if len(weights) == 0 {
+			continue
+		}
+		model.transitions[op], err = newOperationMix(weights)
+		if err != nil {
+			return nil, errors.Wrapf(err, "session transitions from %s are invalid", op)
+		}
+	}
+	return model, nil
+}
+
+// sessionState is what a virtual user read in its current session, which its
+// next calls follow on from.
+type sessionState struct {
+	// remaining is the number of calls left in the session. It is 0 once the
+	// session ended.
+	remaining int
+	last      s.Operation
+	// feed holds the posts of the feed page read last.
+	feed []string
+	// cursor pages on from the feed page read last, when the next call is
+	// cursorOp again, for the feed of cursorUser.
+	cursor     string
+	cursorOp   s.Operation
+	cursorUser string
+	// post is the post opened or created last.
+	post string
+	// user is the owner of the post or feed read last.
+	user string
+}
+
+// next picks the next operation of the session, starting a new session when
+// the last one ended.
+func (st *sessionState) next(model *sessionModel, random *rand.Rand) s.Operation {
+	if st.remaining == 0 {
+		*st = sessionState{
+			remaining: model.length.Min + random.IntN(model.length.Max-model.length.Min+1),
+		}
+		st.last = model.start.pick(random)
+	} else {
+		st.last = model.transitions[st.last].pick(random)
+	}
+
+	st.remaining--
+	if _, ok := model.transitions[st.last]; !ok {
+		st.remaining = 0
+	}
+	return st.last
+}
+
+// cursor returns the cursor to page on with, when a call of op for the feed
+// of user follows on from an earlier page of that feed in the session.
+func (w *worker) cursor(op s.Operation, user string) string {
+	if w.session == nil || w.state.cursorOp != op || w.state.cursorUser != user {
+		return ""
+	}
+	return w.state.cursor
+}
+
+// userTarget returns the user read last in the session, or else one picked
+// by pick.
+func (w *worker) userTarget(pick func() string) string {
+	if w.session == nil || w.state.user == "" {
+		return pick()
+	}
+	return w.state.user
+}
+
+// postToOpen returns a post of the feed page read last in the session, or
+// else one picked at random.
+func (w *worker) postToOpen() string {
+	if w.session == nil || len(w.state.feed) == 0 {
+		return w.post()
+	}
+	return pick(w.random, w.state.feed)
+}
+
+// postToLike returns the post opened last in the session, or else one to
+// open.
+func (w *worker) postToLike() string {
+	if w.session == nil || w.state.post == "" {
+		return w.postToOpen()
+	}
+	return w.state.post
+}
+
+// readFeed keeps a feed page of user read by a call of op, for the calls
+// that follow on from it.
+func (w *worker) readFeed(op s.Operation, user string, posts []*s.Post, cursor string) {
+	if w.session == nil {
+		return
+	}
+	w.state.feed = w.state.feed[:0]
+	for _, post := range posts {
+		w.state.feed = append(w.state.feed, post.PostID)
+	}
+	w.state.cursor, w.state.cursorOp, w.state.cursorUser = cursor, op, user
+	if user != "" {
+		w.state.user = user
+	}
+}
+
+// readPost keeps a post opened or created, for the calls that follow on
+// from it.
+func (w *worker) readPost(post *s.Post) {
+	if w.session == nil || post == nil {
+		return
+	}
+	w.state.post = post.PostID
+	w.state.user = post.OwnerID
+}
+
+// readUser keeps a user read, for the calls that follow on from it.
+func (w *worker) readUser(userID string) {
+	if w.session == nil || userID == "" {
+		return
+	}
+	w.state.user = userID
+}
@@ -0,0 +1,88 @@
+package driver
+
+import (
+	"fmt"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// SLOResult is the outcome of checking one objective of an SLO.
+type SLOResult struct {
+	Operation s.Operation
+	// Objective is p50, p90, p99 or error_rate.
+	Objective string
+	// Target and Actual are latencies in nanoseconds, or fractions of calls
+	// for error_rate.
+	Target float64
+	Actual float64
+	Met    bool
+}
+
+func (r *SLOResult) String() string {
+	format := func(value float64) string {
+		if r.Objective == "error_rate" {
+			return fmt.Sprintf("%.2f%%", 100*value)
+		}
+		return formatLatency(time.Duration(value))
+	}
+	return fmt.Sprintf("%s %s %s (target %s)", r.Operation, r.Objective, format(r.Actual), format(r.Target))
+}
+
+// checkSLOs checks slos against operations. Operations without measured
+// calls are skipped.
+func checkSLOs(slos map[s.Operation]*SLO, operations map[s.Operation]*OperationResult) []*SLOResult {
+	results := []*SLOResult{}
+	for _, op := range s.Operations {
+		slo, ok := slos[op]
+		opResult, called := operations[op]
+		if !ok || !called || opResult.Count == 0 {
+			continue
+		}
+
+		latencies := []struct {
+			objective string
+			target    time.Duration
+			actual    time.Duration
+		}{
+			{"p50", slo.P50, opResult.P50},
+			{"p90", slo.P90, opResult.P90},
+			{"p99", slo.P99, opResult.P99},
+		}
+		for _, latency := range latencies {
+			if latency.target <= 0 {
+				continue
+			}
+			results = append(results, &SLOResult{
+				Operation: op,
+				Objective: latency.objective,
+				Target:    float64(latency.target),
+				Actual:    float64(latency.actual),
+				Met:       latency.actual <= latency.target,
+			})
+		}
+
+		if slo.ErrorRate != nil {
+			errorRate := float64(opResult.Errors) / float64(opResult.Count)
+			results = append(results, &SLOResult{
+				Operation: op,
+				Objective: "error_rate",
+				Target:    *slo.ErrorRate,
+				Actual:    errorRate,
+				Met:       errorRate <= *slo.ErrorRate,
+			})
+		}
+	}
+	return results
+}
+
+// MissedSLOs returns the objectives of Config.SLOs that the run missed.
+func (r *Result) MissedSLOs() []*SLOResult {
+	missed := []*SLOResult{}
+	for _, slo := range r.SLOs {
+		if !slo.Met {
+			missed = append(missed, slo)
+		}
+	}
+	return missed
+}
@@ -0,0 +1,310 @@
+package driver
+
+import (
+	"context"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// operationMix picks operations at random in proportion to their weights.
+type operationMix struct {
+	operations []s.Operation
+	cumulative []int
+	total      int
+}
+
+func newOperationMix(weights map[s.Operation]int) (*operationMix, error) {
+	mix := &operationMix{}
+	for _, op := range s.Operations {
+		weight := weights[op]
+		if weight < 0 {
+			return nil, errors.Errorf("weight of %s was negative", op)
+		} else if weight == 0 {
+			continue
+		}
+		mix.total += weight
+		mix.operations = append(mix.operations, op)
+		mix.cumulative = append(mix.cumulative, mix.total)
+	}
+
+	if mix.total == 0 {
+		return nil, errors.New("operation mix was empty")
+	}
+	return mix, nil
+}
+
+func (m *operationMix) pick(random *rand.Rand) s.Operation {
+	n := random.IntN(m.total)
+	i := sort.SearchInts(m.cumulative, n+1)
+	return m.operations[i]
+}
+
+// idlePoll is how often idle virtual users check whether the schedule made
+// them active.
+const idlePoll = 10 * time.Millisecond
+
+// arrival is a call of an open-loop phase.
+type arrival struct {
+	at    time.Time
+	phase int
+}
+
+// worker is a virtual user.
+type worker struct {
+	server  s.Server
+	dataset *Dataset
+	targets *targets
+	sched   *schedule
+	// start is when the workload started.
+	start time.Time
+	// group and index place the virtual user in the schedule.
+	group int
+	index int
+	// userID is the user the virtual user calls as. When empty, callers are
+	// picked per call.
+	userID string
+	// mix picks the operations of the virtual user, unless session is set.
+	mix *operationMix
+	// session, when set, makes the virtual user act in sessions, with state
+	// holding what it read in the current one.
+	session   *sessionModel
+	state     sessionState
+	thinkTime ThinkTime
+	random    *rand.Rand
+	faker     *gofakeit.Faker
+	// arrivals holds the calls of open-loop phases for the worker's group.
+	arrivals <-chan arrival
+	// recorders holds a recorder per phase.
+	recorders []*stats.Recorder
+	// intervals is drained at every Config.SampleInterval to build
+	// Result.Intervals.
+	intervals *stats.Recorder
+}
+
+func (w *worker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		phase, progress, ok := w.sched.at(time.Since(w.start))
+		if !ok {
+			return
+		}
+		if w.index >= w.sched.groupUsers(w.sched.users(phase, progress), w.group) {
+			sleep(ctx, idlePoll)
+			continue
+		}
+
+		if w.sched.peakRate(phase) > 0 {
+			timer := time.NewTimer(idlePoll)
+			select {
+			case a := <-w.arrivals:
+				w.execute(ctx, a.at, a.phase)
+			case <-timer.C:
+			case <-ctx.Done():
+			}
+			timer.Stop()
+			continue
+		}
+
+		w.execute(ctx, time.Now(), phase)
+		w.think(ctx)
+	}
+}
+
+// execute makes a call that was due at start during phase.
+func (w *worker) execute(ctx context.Context, start time.Time, phase int) {
+	var op s.Operation
+	if w.session != nil {
+		op = w.state.next(w.session, w.random)
+	} else {
+		op = w.mix.pick(w.random)
+	}
+	err := w.call(ctx, op)
+	latency := time.Since(start)
+
+	// Calls cut short by the end of the run are not measured.
+	if ctx.Err() != nil {
+		return
+	}
+	w.recorders[phase].Record(op, latency, err)
+	w.intervals.Record(op, latency, err)
+}
+
+// think pauses between calls, or between sessions when the last call ended
+// one.
+func (w *worker) think(ctx context.Context) {
+	thinkTime := w.thinkTime
+	if w.session != nil && w.state.remaining == 0 {
+		thinkTime = w.session.pause
+	}
+
+	pause := thinkTime.Min
+	if spread := thinkTime.Max - thinkTime.Min; spread > 0 {
+		pause += time.Duration(w.random.Int64N(int64(spread)))
+	}
+	if pause > 0 {
+		sleep(ctx, pause)
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
+
+func (w *worker) call(ctx context.Context, op s.Operation) error {
+	var err error
+	switch op {
+	case s.OpCreateUser:
+		_, err = w.server.CreateUser(ctx, &s.CreateUserRequest{
+			UserName: w.faker.Username() + "-" + w.faker.DigitN(8),
+			Role:     s.RoleViewer,
+		})
+	case s.OpGetUser:
+		userID := w.userTarget(w.targetUser)
+		_, err = w.server.GetUser(ctx, &s.GetUserRequest{
+			CallerID: w.caller(w.anyUser),
+			UserID:   userID,
+		})
+		if err == nil {
+			w.readUser(userID)
+		}
+	case s.OpFollowUser:
+		_, err = w.server.FollowUser(ctx, &s.FollowUserRequest{
+			CallerID:     w.caller(w.viewer),
+			TargetUserID: w.userTarget(w.targetCreator),
+		})
+	case s.OpGetUserFeed:
+		var resp *s.GetUserFeedResponse
+		ownerID := w.userTarget(w.targetCreator)
+		resp, err = w.server.GetUserFeed(ctx, &s.GetUserFeedRequest{
+			CallerID: w.caller(w.anyUser),
+			OwnerID:  ownerID,
+			Cursor:   w.cursor(op, ownerID),
+		})
+		if err == nil {
+			w.readFeed(op, ownerID, resp.Posts, resp.Cursor)
+		}
+	case s.OpGetFollowedFeed:
+		var resp *s.GetFollowedFeedResponse
+		resp, err = w.server.GetFollowedFeed(ctx, &s.GetFollowedFeedRequest{
+			CallerID: w.caller(w.viewer),
+			Cursor:   w.cursor(op, ""),
+		})
+		if err == nil {
+			w.readFeed(op, "", resp.Posts, resp.Cursor)
+		}
+	case s.OpGetFollowed:
+		var resp *s.GetFollowedResponse
+		resp, err = w.server.GetFollowed(ctx, &s.GetFollowedRequest{
+			CallerID: w.caller(w.viewer),
+		})
+		if err == nil && len(resp.User) > 0 {
+			w.readUser(resp.User[w.random.IntN(len(resp.User))].UserID)
+		}
+	case s.OpCreatePost:
+		var resp *s.CreatePostResponse
+		resp, err = w.server.CreatePost(ctx, &s.CreatePostRequest{
+			CallerID: w.caller(w.creator),
+			Content:  w.faker.Sentence(12),
+		})
+		if err == nil && resp.Post != nil {
+			w.targets.posts.add(resp.Post.PostID)
+			w.readPost(resp.Post)
+		}
+	case s.OpGetPost:
+		var resp *s.GetPostResponse
+		resp, err = w.server.GetPost(ctx, &s.GetPostRequest{
+			CallerID: w.caller(w.anyUser),
+			PostID:   w.postToOpen(),
+		})
+		if err == nil {
+			w.readPost(resp.Post)
+		}
+	case s.OpLikePost:
+		_, err = w.server.LikePost(ctx, &s.LikePostRequest{
+			CallerID: w.caller(w.anyUser),
+			PostID:   w.postToLike(),
+		})
+	default:
+		err = errors.Errorf("unsupported operation: \"%s\"", op)
+	}
+	return err
+}
+
+// caller returns the user the virtual user calls as, or one picked by pick
+// when it has no role.
+func (w *worker) caller(pick func() string) string {
+	if w.userID != "" {
+		return w.userID
+	}
+	return pick()
+}
+
+// anyUser picks a creator or a viewer, each user equally likely.
+func (w *worker) anyUser() string {
+	creators, viewers := w.dataset.Creators, w.dataset.Users[s.RoleViewer]
+	if len(creators)+len(viewers) == 0 {
+		return ""
+	}
+
+	i := w.random.IntN(len(creators) + len(viewers))
+	if i < len(creators) {
+		return creators[i]
+	}
+	return viewers[i-len(creators)]
+}
+
+func (w *worker) viewer() string {
+	return pick(w.random, w.dataset.Users[s.RoleViewer])
+}
+
+func (w *worker) creator() string {
+	return pick(w.random, w.dataset.Creators)
+}
+
+// targetUser picks a creator or a viewer to target, by Config.UserTargets.
+func (w *worker) targetUser() string {
+	i := w.targets.users.pick(w.random)
+	if i < 0 {
+		return ""
+	} else if i < len(w.dataset.Creators) {
+		return w.dataset.Creators[i]
+	}
+	return w.dataset.Users[s.RoleViewer][i-len(w.dataset.Creators)]
+}
+
+// targetCreator picks a creator to target, by Config.UserTargets.
+func (w *worker) targetCreator() string {
+	return pickAt(w.dataset.Creators, w.targets.creators.pick(w.random))
+}
+
+// post picks a post to target, by Config.PostTargets, among the seeded posts
+// and those created during the run.
+func (w *worker) post() string {
+	return w.targets.posts.pick(w.random)
+}
+
+func pick(random *rand.Rand, ids []string) string {
+	if len(ids) == 0 {
+		return ""
+	}
+	return ids[random.IntN(len(ids))]
+}
+
+// pickAt returns the ID at i, or "" when i is -1.
+func pickAt(ids []string, i int) string {
+	if i < 0 {
+		return ""
+	}
+	return ids[i]
+}
@@ -0,0 +1,108 @@
+package host_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jlym/dbbenchmark/go/internal/host"
+	"github.com/stretchr/testify/require"
+)
+
+// writeProc writes a fake procfs in which every counter is scaled by tick.
+func writeProc(t *testing.T, root string, tick int) {
+	files := map[string]string{
+		"stat": fmt.Sprintf(
+			"cpu  %d 0 %d %d %d 0 0 0 0 0\ncpu0 1 2 3 4 5 6 7 8 9 10\nctxt %d\nprocs_running 3\n",
+			60*tick, 20*tick, 100*tick, 20*tick, 1000*tick),
+		"meminfo": "MemTotal:       1000 kB\nMemFree:         200 kB\nMemAvailable:    500 kB\n" +
+			"SwapTotal:       100 kB\nSwapFree:         60 kB\n",
+		"diskstats": fmt.Sprintf(""+
+			"   8       0 sda %d 0 %d 0 %d 0 %d 0 0 %d 0\n"+
+			"   8       1 sda1 %d 0 %d 0 %d 0 %d 0 0 %d 0\n"+
+			"   7       0 loop0 %d 0 %d 0 %d 0 %d 0 0 %d 0\n",
+			tick, 2*tick, tick, 4*tick, 10*tick,
+			tick, 2*tick, tick, 4*tick, 10*tick,
+			tick, 2*tick, tick, 4*tick, 10*tick),
+		"net/dev": fmt.Sprintf(""+
+			"Inter-|   Receive                                                |  Transmit\n"+
+			" face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets\n"+
+			"    lo: %d 0 0 0 0 0 0 0 %d 0 0 0 0 0 0 0\n"+
+			"  eth0: %d 0 0 0 0 0 0 0 %d 0 0 0 0 0 0 0\n",
+			999*tick, 999*tick, 100*tick, 50*tick),
+		"self/stat":   processStat("bench", 150*tick, 4),
+		"self/status": fmt.Sprintf("voluntary_ctxt_switches:\t%d\nnonvoluntary_ctxt_switches:\t%d\n", 5*tick, 5*tick),
+		"self/io":     fmt.Sprintf("read_bytes: %d\nwrite_bytes: %d\n", 10*tick, 20*tick),
+		"42/comm":     "postgres\n",
+		"42/stat":     processStat("postgres", 50*tick, 1),
+		"42/status":   "voluntary_ctxt_switches:\t1\nnonvoluntary_ctxt_switches:\t1\n",
+		"43/comm":     "bash\n",
+	}
+	if tick > 1 {
+		// A backend started between the samples.
+		files["44/comm"] = "postgres\n"
+		files["44/stat"] = processStat("postgres", 25, 1)
+		files["44/status"] = "voluntary_ctxt_switches:\t3\nnonvoluntary_ctxt_switches:\t0\n"
+	}
+
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+// processStat returns a /proc/<pid>/stat line with cpuTicks split evenly
+// between utime and stime.
+func processStat(comm string, cpuTicks int, threads int) string {
+	return fmt.Sprintf("1 (%s) S 0 0 0 0 0 0 0 0 0 0 %d %d 0 0 20 0 %d 0 0 0 10 0\n",
+		comm, cpuTicks/2, cpuTicks/2, threads)
+}
+
+func TestProcSampler(t *testing.T) {
+	ctx := context.Background()
+	root := t.TempDir()
+	sampler := host.NewProcSampler()
+	sampler.ProcRoot = root
+
+	writeProc(t, root, 1)
+	baseline, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1000.0*1024, baseline["host.memory.total_bytes"])
+	require.NotContains(t, baseline, "host.context_switches")
+
+	// Act:
+	writeProc(t, root, 2)
+	values, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+
+	// Assert: The host's CPU is split by share of its time.
+	require.InDelta(t, 30.0, values["host.cpu.user_percent"], 1e-9)
+	require.InDelta(t, 10.0, values["host.cpu.system_percent"], 1e-9)
+	require.InDelta(t, 50.0, values["host.cpu.idle_percent"], 1e-9)
+	require.InDelta(t, 10.0, values["host.cpu.iowait_percent"], 1e-9)
+	require.Equal(t, 1000.0, values["host.context_switches"])
+	require.Equal(t, 3.0, values["host.procs_running"])
+	require.Equal(t, 500.0*1024, values["host.memory.available_bytes"])
+	require.Equal(t, 40.0*1024, values["host.memory.swap_used_bytes"])
+
+	// Assert: Partitions, loop devices and loopback are left out.
+	require.Equal(t, 2.0*512, values["host.disk.read_bytes"])
+	require.Equal(t, 4.0*512, values["host.disk.write_bytes"])
+	require.Equal(t, 10.0, values["host.disk.io_time_ms"])
+	require.Equal(t, 100.0, values["host.net.rx_bytes"])
+	require.Equal(t, 50.0, values["host.net.tx_bytes"])
+
+	require.Equal(t, 10.0, values["driver.context_switches"])
+	require.Equal(t, 10.0, values["driver.read_bytes"])
+	require.Equal(t, 4.0, values["driver.threads"])
+	require.Equal(t, 10.0*float64(os.Getpagesize()), values["driver.rss_bytes"])
+	require.Greater(t, values["driver.cpu_percent"], 0.0)
+
+	// Assert: The new backend counts from zero.
+	require.Equal(t, 2.0, values["postgres.processes"])
+	require.Equal(t, 3.0, values["postgres.context_switches"])
+	require.Equal(t, 2.0, values["postgres.threads"])
+}
@@ -0,0 +1,311 @@
+package host
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc. It is 100 on every
+// Linux platform Go supports.
+const clockTicks = 100
+
+const sectorSize = 512
+
+type cpuTimes struct {
+	user   float64
+	system float64
+	idle   float64
+	iowait float64
+	steal  float64
+	total  float64
+}
+
+type hostStat struct {
+	cpu             cpuTimes
+	contextSwitches float64
+	procsRunning    float64
+}
+
+// readHostStat reads /proc/stat.
+func readHostStat(procRoot string) (*hostStat, error) {
+	stat := &hostStat{}
+	err := scanLines(filepath.Join(procRoot, "stat"), func(fields []string) error {
+		switch fields[0] {
+		case "cpu":
+			// user nice system idle iowait irq softirq steal guest guest_nice;
+			// guest time is already counted in user.
+			values, err := parseFloats(fields[1:])
+			if err != nil {
+				return err
+			}
+			values = append(values, make([]float64, 8)...)
+			stat.cpu = cpuTimes{
+				user:   values[0] + values[1],
+				system: values[2] + values[5] + values[6],
+				idle:   values[3],
+				iowait: values[4],
+				steal:  values[7],
+			}
+			for _, value := range values[:8] {
+				stat.cpu.total += value
+			}
+		case "ctxt":
+			return parseField(fields, 1, &stat.contextSwitches)
+		case "procs_running":
+			return parseField(fields, 1, &stat.procsRunning)
+		}
+		return nil
+	})
+	return stat, err
+}
+
+// readMemInfo reads /proc/meminfo, in bytes.
+func readMemInfo(procRoot string) (map[string]float64, error) {
+	memInfo := map[string]float64{}
+	err := scanLines(filepath.Join(procRoot, "meminfo"), func(fields []string) error {
+		var value float64
+		err := parseField(fields, 1, &value)
+		if err != nil {
+			return err
+		}
+		if len(fields) > 2 && fields[2] == "kB" {
+			value *= 1024
+		}
+		memInfo[strings.TrimSuffix(fields[0], ":")] = value
+		return nil
+	})
+	return memInfo, err
+}
+
+type diskStats struct {
+	readBytes  float64
+	writeBytes float64
+	// ioTime is the time spent doing I/O in milliseconds, summed over
+	// devices.
+	ioTime float64
+}
+
+// readDiskStats reads /proc/diskstats, summed over whole disks. Partitions,
+// loop, RAM and device-mapper devices are left out so that no I/O is counted
+// twice.
+func readDiskStats(procRoot string) (*diskStats, error) {
+	type device struct {
+		name   string
+		fields []string
+	}
+	devices := []device{}
+	err := scanLines(filepath.Join(procRoot, "diskstats"), func(fields []string) error {
+		if len(fields) < 14 {
+			return errors.Errorf("expected at least 14 fields, got %d", len(fields))
+		}
+		devices = append(devices, device{name: fields[2], fields: fields})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := map[string]bool{}
+	for _, d := range devices {
+		names[d.name] = true
+	}
+
+	stats := &diskStats{}
+	for _, d := range devices {
+		if isVirtualDisk(d.name) || isPartition(d.name, names) {
+			continue
+		}
+		values, err := parseFloats(d.fields[3:13])
+		if err != nil {
+			return nil, err
+		}
+		stats.readBytes += values[2] * sectorSize
+		stats.writeBytes += values[6] * sectorSize
+		stats.ioTime += values[9]
+	}
+	return stats, nil
+}
+
+func isVirtualDisk(name string) bool {
+	return strings.HasPrefix(name, "loop") || strings.HasPrefix(name, "ram") || strings.HasPrefix(name, "dm-")
+}
+
+// isPartition reports whether name is a partition of another device, such as
+// sda1 of sda or nvme0n1p1 of nvme0n1.
+func isPartition(name string, names map[string]bool) bool {
+	for i := len(name) - 1; i > 0; i-- {
+		if name[i] < '0' || name[i] > '9' {
+			prefix := strings.TrimSuffix(name[:i+1], "p")
+			return i < len(name)-1 && (names[name[:i+1]] || names[prefix])
+		}
+	}
+	return false
+}
+
+type netStats struct {
+	rxBytes float64
+	txBytes float64
+}
+
+// readNetDev reads /proc/net/dev, summed over every interface but loopback.
+func readNetDev(procRoot string) (*netStats, error) {
+	stats := &netStats{}
+	err := scanLines(filepath.Join(procRoot, "net", "dev"), func(fields []string) error {
+		if !strings.HasSuffix(fields[0], ":") || fields[0] == "lo:" {
+			// Headers, which have no colon after their first field, and
+			// loopback.
+			return nil
+		}
+		if len(fields) < 10 {
+			return errors.Errorf("expected at least 10 fields, got %d", len(fields))
+		}
+		values, err := parseFloats([]string{fields[1], fields[9]})
+		if err != nil {
+			return err
+		}
+		stats.rxBytes += values[0]
+		stats.txBytes += values[1]
+		return nil
+	})
+	return stats, err
+}
+
+type processStats struct {
+	comm    string
+	cpuTime float64
+	rss     float64
+	threads float64
+	// contextSwitches counts voluntary and involuntary switches.
+	contextSwitches float64
+	readBytes       float64
+	writeBytes      float64
+}
+
+// readProcess reads /proc/<pid>/stat, status and io. io is only readable for
+// processes of the same user, so its values are left at zero otherwise.
+func readProcess(procRoot string, pid string) (*processStats, error) {
+	dir := filepath.Join(procRoot, pid)
+	data, err := os.ReadFile(filepath.Join(dir, "stat"))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	// The command name is in parentheses and may hold spaces.
+	stat := string(data)
+	open, end := strings.IndexByte(stat, '('), strings.LastIndexByte(stat, ')')
+	if open < 0 || end < open {
+		return nil, errors.Errorf("parsing %s/stat failed", dir)
+	}
+	fields := strings.Fields(stat[end+1:])
+	if len(fields) < 22 {
+		return nil, errors.Errorf("expected at least 22 fields after the command in %s/stat, got %d", dir, len(fields))
+	}
+	// fields[0] is field 3 of proc(5), the state.
+	values, err := parseFloats([]string{fields[11], fields[12], fields[17], fields[21]})
+	if err != nil {
+		return nil, err
+	}
+	process := &processStats{
+		comm:    stat[open+1 : end],
+		cpuTime: (values[0] + values[1]) / clockTicks,
+		threads: values[2],
+		rss:     values[3] * float64(os.Getpagesize()),
+	}
+
+	err = scanLines(filepath.Join(dir, "status"), func(fields []string) error {
+		switch fields[0] {
+		case "voluntary_ctxt_switches:", "nonvoluntary_ctxt_switches:":
+			var value float64
+			err := parseField(fields, 1, &value)
+			process.contextSwitches += value
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_ = scanLines(filepath.Join(dir, "io"), func(fields []string) error {
+		switch fields[0] {
+		case "read_bytes:":
+			return parseField(fields, 1, &process.readBytes)
+		case "write_bytes:":
+			return parseField(fields, 1, &process.writeBytes)
+		}
+		return nil
+	})
+
+	return process, nil
+}
+
+// findProcesses returns the IDs of the processes named comm.
+func findProcesses(procRoot string, comm string) ([]string, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	pids := []string{}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "comm"))
+		if err != nil {
+			// The process exited.
+			continue
+		}
+		if strings.TrimSpace(string(data)) == comm {
+			pids = append(pids, entry.Name())
+		}
+	}
+	return pids, nil
+}
+
+// scanLines calls fn with the fields of every non-empty line of path.
+func scanLines(path string, fn func(fields []string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		err = fn(fields)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s failed", path)
+		}
+	}
+	return errors.WithStack(scanner.Err())
+}
+
+func parseField(fields []string, i int, value *float64) error {
+	if len(fields) <= i {
+		return errors.Errorf("expected at least %d fields, got %d", i+1, len(fields))
+	}
+	var err error
+	*value, err = strconv.ParseFloat(fields[i], 64)
+	return errors.WithStack(err)
+}
+
+func parseFloats(fields []string) ([]float64, error) {
+	values := make([]float64, len(fields))
+	for i := range fields {
+		err := parseField(fields, i, &values[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
@@ -0,0 +1,199 @@
+// Package host samples the resources used by the host, the driver process
+// and local Postgres processes from /proc, so that results show whether a
+// bottleneck was the database, the driver or the machine. It only works on
+// Linux.
+package host
+
+import (
+	"context"
+	"time"
+)
+
+// ProcSampler samples /proc. CPU use is returned as a percentage of the time
+// since the previous sample, memory and thread counts as they are, and other
+// cumulative counters as how much they grew since the previous sample.
+//
+// Values are keyed "host.<name>" for the whole machine, "driver.<name>" for
+// this process and "postgres.<name>" for the processes named PostgresComm.
+type ProcSampler struct {
+	// ProcRoot is where procfs is mounted.
+	ProcRoot string
+	// PostgresComm is the command name of local Postgres processes. They are
+	// not sampled when it is empty.
+	PostgresComm string
+
+	previousAt time.Time
+	previous   map[string]float64
+	// previousPostgres holds the counters of each Postgres process, so that
+	// processes starting and exiting between samples do not count as
+	// negative usage.
+	previousPostgres map[string]*processStats
+}
+
+func NewProcSampler() *ProcSampler {
+	return &ProcSampler{
+		ProcRoot:     "/proc",
+		PostgresComm: "postgres",
+	}
+}
+
+func (p *ProcSampler) SamplerName() string {
+	return "host"
+}
+
+func (p *ProcSampler) Sample(ctx context.Context) (map[string]float64, error) {
+	now := time.Now()
+	counters := map[string]float64{}
+	gauges := map[string]float64{}
+
+	err := p.sampleHost(counters, gauges)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := readProcess(p.ProcRoot, "self")
+	if err != nil {
+		return nil, err
+	}
+	addProcess("driver.", driver, counters, gauges)
+
+	var postgres map[string]*processStats
+	if p.PostgresComm != "" {
+		postgres, err = p.samplePostgres(counters, gauges)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	values := gauges
+	if p.previous != nil {
+		elapsed := now.Sub(p.previousAt).Seconds()
+		for key, value := range counters {
+			values[key] = value - p.previous[key]
+		}
+
+		// Host CPU times are shares of all CPUs' time; process CPU times
+		// are seconds, so they can exceed 100% on several CPUs.
+		totalCPU := values["host.cpu.total"]
+		for _, name := range []string{"user", "system", "idle", "iowait", "steal"} {
+			values["host.cpu."+name+"_percent"] = percent(values["host.cpu."+name], totalCPU)
+			delete(values, "host.cpu."+name)
+		}
+		delete(values, "host.cpu.total")
+		for _, prefix := range []string{"driver.", "postgres."} {
+			if cpuTime, ok := values[prefix+"cpu_seconds"]; ok {
+				values[prefix+"cpu_percent"] = percent(cpuTime, elapsed)
+				delete(values, prefix+"cpu_seconds")
+			}
+		}
+	}
+
+	p.previousAt = now
+	p.previous = counters
+	p.previousPostgres = postgres
+	return values, nil
+}
+
+func (p *ProcSampler) sampleHost(counters map[string]float64, gauges map[string]float64) error {
+	stat, err := readHostStat(p.ProcRoot)
+	if err != nil {
+		return err
+	}
+	counters["host.cpu.user"] = stat.cpu.user
+	counters["host.cpu.system"] = stat.cpu.system
+	counters["host.cpu.idle"] = stat.cpu.idle
+	counters["host.cpu.iowait"] = stat.cpu.iowait
+	counters["host.cpu.steal"] = stat.cpu.steal
+	counters["host.cpu.total"] = stat.cpu.total
+	counters["host.context_switches"] = stat.contextSwitches
+	gauges["host.procs_running"] = stat.procsRunning
+
+	memInfo, err := readMemInfo(p.ProcRoot)
+	if err != nil {
+		return err
+	}
+	gauges["host.memory.total_bytes"] = memInfo["MemTotal"]
+	gauges["host.memory.available_bytes"] = memInfo["MemAvailable"]
+	gauges["host.memory.swap_used_bytes"] = memInfo["SwapTotal"] - memInfo["SwapFree"]
+
+	disk, err := readDiskStats(p.ProcRoot)
+	if err != nil {
+		return err
+	}
+	counters["host.disk.read_bytes"] = disk.readBytes
+	counters["host.disk.write_bytes"] = disk.writeBytes
+	counters["host.disk.io_time_ms"] = disk.ioTime
+
+	net, err := readNetDev(p.ProcRoot)
+	if err != nil {
+		return err
+	}
+	counters["host.net.rx_bytes"] = net.rxBytes
+	counters["host.net.tx_bytes"] = net.txBytes
+	return nil
+}
+
+// samplePostgres sums the Postgres processes into counters and gauges. The
+// counters of each process are added up since the first sample that saw it,
+// so the sum only ever grows.
+func (p *ProcSampler) samplePostgres(
+	counters map[string]float64, gauges map[string]float64) (map[string]*processStats, error) {
+
+	pids, err := findProcesses(p.ProcRoot, p.PostgresComm)
+	if err != nil {
+		return nil, err
+	}
+
+	processes := map[string]*processStats{}
+	total := &processStats{}
+	for _, pid := range pids {
+		process, err := readProcess(p.ProcRoot, pid)
+		if err != nil {
+			// The process exited.
+			continue
+		}
+		processes[pid] = process
+
+		// A process that is new since the previous sample counts from zero,
+		// except on the first sample, which only sets the baseline.
+		previous, ok := p.previousPostgres[pid]
+		if !ok {
+			previous = &processStats{}
+			if p.previous == nil {
+				previous = process
+			}
+		}
+		total.cpuTime += process.cpuTime - previous.cpuTime
+		total.contextSwitches += process.contextSwitches - previous.contextSwitches
+		total.readBytes += process.readBytes - previous.readBytes
+		total.writeBytes += process.writeBytes - previous.writeBytes
+		total.rss += process.rss
+		total.threads += process.threads
+	}
+
+	// Turn the growth since the previous sample back into a counter.
+	for _, key := range []string{"cpu_seconds", "context_switches", "read_bytes", "write_bytes"} {
+		counters["postgres."+key] = p.previous["postgres."+key]
+	}
+	addProcess("postgres.", total, counters, gauges)
+	gauges["postgres.processes"] = float64(len(processes))
+	return processes, nil
+}
+
+// addProcess adds process' counters to counters and its gauges to gauges,
+// under prefix.
+func addProcess(prefix string, process *processStats, counters map[string]float64, gauges map[string]float64) {
+	counters[prefix+"cpu_seconds"] += process.cpuTime
+	counters[prefix+"context_switches"] += process.contextSwitches
+	counters[prefix+"read_bytes"] += process.readBytes
+	counters[prefix+"write_bytes"] += process.writeBytes
+	gauges[prefix+"rss_bytes"] = process.rss
+	gauges[prefix+"threads"] = process.threads
+}
+
+func percent(part float64, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * part / total
+}
@@ -0,0 +1,118 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Error is returned by Client when the server replies with an error.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("server replied with status %d: %s", e.StatusCode, e.Message)
+}
+
+// Client is an s.Server that sends every call to a Handler. NewClient's
+// HTTPClient propagates the caller's trace context.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// Enforce that Client implements s.Server interface.
+var _ s.Server = &Client{}
+
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)},
+	}
+}
+
+func (c *Client) CreateUser(ctx context.Context, request *s.CreateUserRequest) (*s.CreateUserResponse, error) {
+	return call[s.CreateUserResponse](ctx, c, s.OpCreateUser, request)
+}
+
+func (c *Client) GetUser(ctx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	return call[s.GetUserResponse](ctx, c, s.OpGetUser, request)
+}
+
+func (c *Client) FollowUser(ctx context.Context, request *s.FollowUserRequest) (*s.FollowUserResponse, error) {
+	return call[s.FollowUserResponse](ctx, c, s.OpFollowUser, request)
+}
+
+func (c *Client) GetUserFeed(
+	ctx context.Context, request *s.GetUserFeedRequest) (*s.GetUserFeedResponse, error) {
+	return call[s.GetUserFeedResponse](ctx, c, s.OpGetUserFeed, request)
+}
+
+func (c *Client) GetFollowedFeed(
+	ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+	return call[s.GetFollowedFeedResponse](ctx, c, s.OpGetFollowedFeed, request)
+}
+
+func (c *Client) GetFollowed(
+	ctx context.Context, request *s.GetFollowedRequest) (*s.GetFollowedResponse, error) {
+	return call[s.GetFollowedResponse](ctx, c, s.OpGetFollowed, request)
+}
+
+func (c *Client) CreatePost(ctx context.Context, request *s.CreatePostRequest) (*s.CreatePostResponse, error) {
+	return call[s.CreatePostResponse](ctx, c, s.OpCreatePost, request)
+}
+
+func (c *Client) GetPost(ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
+	return call[s.GetPostResponse](ctx, c, s.OpGetPost, request)
+}
+
+func (c *Client) LikePost(ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+	return call[s.LikePostResponse](ctx, c, s.OpLikePost, request)
+}
+
+func call[Resp any](ctx context.Context, c *Client, op s.Operation, request any) (*Resp, error) {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, errors.Wrapf(err, "encoding %s request failed", op)
+	}
+
+	httpRequest, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, c.BaseURL+pathPrefix+string(op), bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s request failed", op)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	httpResponse, err := c.HTTPClient.Do(httpRequest)
+	if err != nil {
+		return nil, errors.Wrapf(err, "sending %s request failed", op)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusOK {
+		errBody := &errorBody{}
+		err = json.NewDecoder(httpResponse.Body).Decode(errBody)
+		if err != nil {
+			errBody.Error = http.StatusText(httpResponse.StatusCode)
+		}
+		return nil, &Error{StatusCode: httpResponse.StatusCode, Message: errBody.Error}
+	}
+
+	resp := new(Resp)
+	err = json.NewDecoder(httpResponse.Body).Decode(resp)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding %s response failed", op)
+	}
+
+	return resp, nil
+}
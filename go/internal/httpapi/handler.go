@@ -0,0 +1,84 @@
+// Package httpapi serves an s.Server over HTTP and provides a client for it.
+// Every operation is a POST to /v1/<operation> with the request as its JSON
+// body and the response as the JSON body of the reply.
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+const pathPrefix = "/v1/"
+
+// errorBody is the body of every non-200 reply.
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+// Handler serves an s.Server. Every request gets a span, continuing the
+// trace propagated by the caller, when a tracer provider is installed.
+type Handler struct {
+	Server  s.Server
+	mux     *http.ServeMux
+	handler http.Handler
+}
+
+func NewHandler(server s.Server) *Handler {
+	h := &Handler{
+		Server: server,
+		mux:    http.NewServeMux(),
+	}
+
+	route(h, s.OpCreateUser, server.CreateUser)
+	route(h, s.OpGetUser, server.GetUser)
+	route(h, s.OpFollowUser, server.FollowUser)
+	route(h, s.OpGetUserFeed, server.GetUserFeed)
+	route(h, s.OpGetFollowedFeed, server.GetFollowedFeed)
+	route(h, s.OpGetFollowed, server.GetFollowed)
+	route(h, s.OpCreatePost, server.CreatePost)
+	route(h, s.OpGetPost, server.GetPost)
+	route(h, s.OpLikePost, server.LikePost)
+
+	h.handler = otelhttp.NewHandler(h.mux, "httpapi",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	)
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+func route[Req any, Resp any](
+	h *Handler, op s.Operation, method func(context.Context, *Req) (*Resp, error)) {
+
+	h.mux.HandleFunc("POST "+pathPrefix+string(op), func(w http.ResponseWriter, r *http.Request) {
+		request := new(Req)
+		err := json.NewDecoder(r.Body).Decode(request)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, &errorBody{Error: "decoding request failed: " + err.Error()})
+			return
+		}
+
+		resp, err := method(r.Context(), request)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, &errorBody{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, http.StatusOK, resp)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,61 @@
+package httpapi_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/httpapi"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	inner := servertest.NewFakeServer()
+	httpServer := httptest.NewServer(httpapi.NewHandler(inner))
+	defer httpServer.Close()
+	client := httpapi.NewClient(httpServer.URL)
+
+	createUserResp, err := client.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: "creator",
+		Role:     s.RoleSmallCreator,
+	})
+	require.NoError(t, err)
+	creator := createUserResp.User
+
+	createPostResp, err := client.CreatePost(ctx, &s.CreatePostRequest{
+		CallerID: creator.UserID,
+		Content:  "hello",
+	})
+	require.NoError(t, err)
+
+	likePostResp, err := client.LikePost(ctx, &s.LikePostRequest{
+		CallerID: creator.UserID,
+		PostID:   createPostResp.Post.PostID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, likePostResp.Post.LikeCount)
+	require.True(t, likePostResp.Post.LikedByCaller)
+	require.True(t, createPostResp.Post.CreatedAt.Equal(likePostResp.Post.CreatedAt))
+
+	getUserResp, err := client.GetUser(ctx, &s.GetUserRequest{
+		CallerID: creator.UserID,
+		UserID:   creator.UserID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "creator", getUserResp.User.UserName)
+
+	// Act: Errors from the server come back as *httpapi.Error.
+	_, err = client.CreatePost(ctx, &s.CreatePostRequest{CallerID: creator.UserID})
+	var apiErr *httpapi.Error
+	require.True(t, errors.As(err, &apiErr))
+	require.Equal(t, http.StatusInternalServerError, apiErr.StatusCode)
+	require.Contains(t, apiErr.Message, "request.Content was empty")
+}
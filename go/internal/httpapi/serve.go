@@ -0,0 +1,36 @@
+package httpapi
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Serve serves handler on addr until ctx is done.
+func Serve(ctx context.Context, addr string, handler http.Handler) error {
+	httpServer := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return errors.Wrapf(err, "serving failed, addr=\"%s\"", addr)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := httpServer.Shutdown(shutdownCtx)
+	if err != nil {
+		return errors.Wrap(err, "shutting down failed")
+	}
+	return nil
+}
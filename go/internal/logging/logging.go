@@ -0,0 +1,88 @@
+// Package logging sets up log/slog for the commands, and carries
+// request-scoped attributes, such as the operation, caller ID and run ID, in
+// contexts so that every record logged on behalf of a call has them.
+package logging
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log/slog"
+
+	"github.com/pkg/errors"
+)
+
+// Formats accepted in Config.Format.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Config configures the logger returned by New.
+type Config struct {
+	Level slog.Level
+	// Format is "text" or "json". JSON is easier to parse after long runs.
+	Format string
+}
+
+var DefaultConfig = &Config{
+	Level:  slog.LevelInfo,
+	Format: FormatText,
+}
+
+// RegisterFlags adds -log-level and -log-format to flags, which set the
+// fields of c.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.TextVar(&c.Level, "log-level", c.Level, "lowest level logged: debug, info, warn or error")
+	flags.StringVar(&c.Format, "log-format", c.Format, "log format: text or json")
+}
+
+// New returns a logger that writes to w and adds the attributes of WithAttrs
+// to every record.
+func New(w io.Writer, config *Config) (*slog.Logger, error) {
+	options := &slog.HandlerOptions{Level: config.Level}
+
+	var handler slog.Handler
+	switch config.Format {
+	case FormatText, "":
+		handler = slog.NewTextHandler(w, options)
+	case FormatJSON:
+		handler = slog.NewJSONHandler(w, options)
+	default:
+		return nil, errors.Errorf("unknown log format: \"%s\"", config.Format)
+	}
+
+	return slog.New(&contextHandler{Handler: handler}), nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose records, logged through a logger from
+// New, carry attrs as well as the attributes already in ctx.
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	combined := make([]slog.Attr, 0, len(existing)+len(attrs))
+	combined = append(combined, existing...)
+	combined = append(combined, attrs...)
+	return context.WithValue(ctx, attrsKey{}, combined)
+}
+
+// contextHandler adds the attributes of WithAttrs to records.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		record.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
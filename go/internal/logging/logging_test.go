@@ -0,0 +1,46 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContextAttrs(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, &logging.Config{Level: slog.LevelDebug, Format: logging.FormatJSON})
+	require.NoError(t, err)
+
+	ctx := logging.WithAttrs(context.Background(), slog.String("run_id", "run"))
+
+	// Act:
+	logger.InfoContext(logging.WithAttrs(ctx, slog.String("operation", "GetPost")), "inside call", "rows", 3)
+
+	// Assert: The record is JSON and carries the attributes of both contexts.
+	var record map[string]any
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+	require.Equal(t, "inside call", record["msg"])
+	require.Equal(t, "INFO", record["level"])
+	require.Equal(t, "run", record["run_id"])
+	require.Equal(t, "GetPost", record["operation"])
+	require.Equal(t, 3.0, record["rows"])
+}
+
+func TestLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, &logging.Config{Level: slog.LevelWarn, Format: logging.FormatText})
+	require.NoError(t, err)
+
+	logger.Info("dropped")
+	logger.Warn("kept")
+	require.NotContains(t, buf.String(), "dropped")
+	require.Contains(t, buf.String(), "msg=kept")
+
+	_, err = logging.New(&buf, &logging.Config{Format: "xml"})
+	require.Error(t, err)
+}
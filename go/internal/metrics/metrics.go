@@ -0,0 +1,87 @@
+// Package metrics exposes live benchmark metrics in the Prometheus format.
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+const namespace = "dbbenchmark"
+
+// Metrics counts calls per operation and records their latency. It is a
+// middleware.Interceptor, so it can be placed in front of any s.Server.
+type Metrics struct {
+	Registry *prometheus.Registry
+	Requests *prometheus.CounterVec
+	Errors   *prometheus.CounterVec
+	Latency  *prometheus.HistogramVec
+	// ClassifyError gives the class label of failed calls. Every error is
+	// labeled "error" when it is nil.
+	ClassifyError func(err error) string
+}
+
+// Enforce that Metrics implements middleware.Interceptor interface.
+var _ middleware.Interceptor = &Metrics{}
+
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		Registry: prometheus.NewRegistry(),
+		Requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Calls made, by operation.",
+		}, []string{"operation"}),
+		Errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "errors_total",
+			Help:      "Calls that failed, by operation and error class.",
+		}, []string{"operation", "class"}),
+		Latency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of calls, by operation.",
+			Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 16),
+		}, []string{"operation"}),
+	}
+
+	m.Registry.MustRegister(
+		m.Requests,
+		m.Errors,
+		m.Latency,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return m
+}
+
+func (m *Metrics) Intercept(ctx context.Context, op s.Operation, request any, call middleware.Call) error {
+	start := time.Now()
+	err := call(ctx)
+	latency := time.Since(start)
+
+	m.Requests.WithLabelValues(string(op)).Inc()
+	m.Latency.WithLabelValues(string(op)).Observe(latency.Seconds())
+	if err != nil {
+		class := "error"
+		if m.ClassifyError != nil {
+			class = m.ClassifyError(err)
+		}
+		m.Errors.WithLabelValues(string(op), class).Inc()
+	}
+
+	return err
+}
+
+// Handler serves the registry in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.Registry, promhttp.HandlerOpts{Registry: m.Registry})
+}
@@ -0,0 +1,44 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/metrics"
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	m := metrics.NewMetrics()
+	m.ClassifyError = func(err error) string {
+		return "permanent"
+	}
+	server := middleware.Wrap(servertest.NewFakeServer(), m)
+
+	_, err := server.GetPost(ctx, &s.GetPostRequest{CallerID: "caller", PostID: "post"})
+	require.NoError(t, err)
+	_, err = server.CreatePost(ctx, &s.CreatePostRequest{CallerID: "caller"})
+	require.Error(t, err)
+
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Requests.WithLabelValues("GetPost")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Requests.WithLabelValues("CreatePost")))
+	require.Equal(t, 1.0, testutil.ToFloat64(m.Errors.WithLabelValues("CreatePost", "permanent")))
+
+	// Assert: The handler serves the metrics in the text format.
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body, err := io.ReadAll(recorder.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), `dbbenchmark_request_duration_seconds_count{operation="GetPost"} 1`)
+	require.Contains(t, string(body), `dbbenchmark_errors_total{class="permanent",operation="CreatePost"} 1`)
+}
@@ -0,0 +1,78 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// poolCollector reads pgxpool.Stat every time the metrics are scraped.
+type poolCollector struct {
+	stat func() *pgxpool.Stat
+
+	acquiredConns       *prometheus.Desc
+	idleConns           *prometheus.Desc
+	totalConns          *prometheus.Desc
+	constructingConns   *prometheus.Desc
+	maxConns            *prometheus.Desc
+	acquires            *prometheus.Desc
+	emptyAcquires       *prometheus.Desc
+	canceledAcquires    *prometheus.Desc
+	acquireDurationSecs *prometheus.Desc
+}
+
+// RegisterPool adds gauges and counters read from stat, such as
+// pgxpool.Pool.Stat, to the registry.
+func (m *Metrics) RegisterPool(stat func() *pgxpool.Stat) {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "pool", name), help, nil, nil)
+	}
+
+	m.Registry.MustRegister(&poolCollector{
+		stat:              stat,
+		acquiredConns:     desc("acquired_conns", "Connections currently in use."),
+		idleConns:         desc("idle_conns", "Connections currently idle."),
+		totalConns:        desc("total_conns", "Connections open, in use or idle or being opened."),
+		constructingConns: desc("constructing_conns", "Connections currently being opened."),
+		maxConns:          desc("max_conns", "Maximum size of the pool."),
+		acquires:          desc("acquires_total", "Connections acquired from the pool."),
+		emptyAcquires: desc("empty_acquires_total",
+			"Acquires that had to wait for a connection because none was idle."),
+		canceledAcquires: desc("canceled_acquires_total",
+			"Acquires cancelled by their context before getting a connection."),
+		acquireDurationSecs: desc("acquire_duration_seconds_total",
+			"Total time spent waiting to acquire connections."),
+	})
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.totalConns
+	ch <- c.constructingConns
+	ch <- c.maxConns
+	ch <- c.acquires
+	ch <- c.emptyAcquires
+	ch <- c.canceledAcquires
+	ch <- c.acquireDurationSecs
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.stat()
+
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+
+	gauge(c.acquiredConns, float64(stat.AcquiredConns()))
+	gauge(c.idleConns, float64(stat.IdleConns()))
+	gauge(c.totalConns, float64(stat.TotalConns()))
+	gauge(c.constructingConns, float64(stat.ConstructingConns()))
+	gauge(c.maxConns, float64(stat.MaxConns()))
+	counter(c.acquires, float64(stat.AcquireCount()))
+	counter(c.emptyAcquires, float64(stat.EmptyAcquireCount()))
+	counter(c.canceledAcquires, float64(stat.CanceledAcquireCount()))
+	counter(c.acquireDurationSecs, stat.AcquireDuration().Seconds())
+}
@@ -0,0 +1,221 @@
+package middleware
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+// Stage names accepted in Config.Pipeline.
+const (
+	StageTiming  = "timing"
+	StageLogging = "logging"
+	StageRetry   = "retry"
+	StageFaults  = "faults"
+)
+
+// Config describes a pipeline of interceptors.
+type Config struct {
+	// Pipeline lists stages from outermost to innermost. For example, with
+	// ["timing", "retry", "faults"] the recorded latency includes retries of
+	// injected faults.
+	Pipeline []string      `yaml:"pipeline"`
+	Logging  LoggingConfig `yaml:"logging"`
+	Retry    RetryConfig   `yaml:"retry"`
+	Faults   FaultsConfig  `yaml:"faults"`
+}
+
+// DefaultConfig logs calls and carries their operation and caller ID to the
+// records logged on their behalf. Retries take effect once "retry" is added
+// to the pipeline.
+var DefaultConfig = &Config{
+	Pipeline: []string{StageLogging},
+	Retry: RetryConfig{
+		MaxAttempts:    3,
+		InitialBackoff: 10 * time.Millisecond,
+		MaxBackoff:     time.Second,
+	},
+}
+
+// RegisterFlags adds -middleware and flags for the settings of each stage to
+// flags, which set the fields of c.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.Var((*stagesFlag)(&c.Pipeline), "middleware", "comma-separated stages wrapped around the server, outermost first: logging, retry or faults")
+	flags.DurationVar(&c.Logging.SlowThreshold, "slow-call-threshold", c.Logging.SlowThreshold, "log calls slower than this at info level; 0 disables it")
+	flags.IntVar(&c.Retry.MaxAttempts, "retry-attempts", c.Retry.MaxAttempts, "attempts of a call, including the first, in the retry stage")
+	flags.DurationVar(&c.Retry.InitialBackoff, "retry-initial-backoff", c.Retry.InitialBackoff, "delay before the first retry, doubling with every retry")
+	flags.DurationVar(&c.Retry.MaxBackoff, "retry-max-backoff", c.Retry.MaxBackoff, "longest delay between retries; 0 leaves it unbounded")
+	flags.Var((*operationsFlag)(&c.Retry.Operations), "retry-ops", "comma-separated operations the retry stage retries; empty retries the reads only, so writes must be listed")
+	flags.Float64Var(&c.Faults.ErrorRate, "fault-error-rate", c.Faults.ErrorRate, "fraction of calls the faults stage fails")
+	flags.DurationVar(&c.Faults.Latency, "fault-latency", c.Faults.Latency, "latency the faults stage adds to every call")
+	flags.DurationVar(&c.Faults.LatencyJitter, "fault-latency-jitter", c.Faults.LatencyJitter, "random latency the faults stage adds on top of -fault-latency, up to this")
+	flags.Var((*operationsFlag)(&c.Faults.Operations), "fault-ops", "comma-separated operations the faults stage affects; empty affects all")
+}
+
+type LoggingConfig struct {
+	SlowThreshold time.Duration `yaml:"slow_threshold"`
+}
+
+type RetryConfig struct {
+	MaxAttempts    int           `yaml:"max_attempts"`
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	MaxBackoff     time.Duration `yaml:"max_backoff"`
+	Operations     []s.Operation `yaml:"operations"`
+}
+
+type FaultsConfig struct {
+	ErrorRate     float64       `yaml:"error_rate"`
+	Latency       time.Duration `yaml:"latency"`
+	LatencyJitter time.Duration `yaml:"latency_jitter"`
+	Operations    []s.Operation `yaml:"operations"`
+}
+
+// Dependencies holds the objects stages need but that cannot be configured
+// from a file.
+type Dependencies struct {
+	// Recorder is required by the timing stage.
+	Recorder LatencyRecorder
+	// Logger is used by the logging stage, slog.Default() when nil.
+	Logger *slog.Logger
+	// Retryable is passed to the retry stage.
+	Retryable func(err error) bool
+}
+
+// Build wraps inner with the stages listed in config.Pipeline.
+func Build(inner s.Server, config *Config, deps *Dependencies) (s.Server, error) {
+	if deps == nil {
+		deps = &Dependencies{}
+	}
+
+	interceptors := []Interceptor{}
+	for _, stage := range config.Pipeline {
+		switch stage {
+		case StageTiming:
+			if deps.Recorder == nil {
+				return nil, errors.New("timing stage requires deps.Recorder")
+			}
+			interceptors = append(interceptors, &Timing{Recorder: deps.Recorder})
+
+		case StageLogging:
+			logger := deps.Logger
+			if logger == nil {
+				logger = slog.Default()
+			}
+			interceptors = append(interceptors, &Logging{
+				Logger:        logger,
+				SlowThreshold: config.Logging.SlowThreshold,
+			})
+
+		case StageRetry:
+			if config.Retry.MaxAttempts < 1 {
+				return nil, errors.Errorf("retry.max_attempts must be at least 1, was %d", config.Retry.MaxAttempts)
+			}
+			interceptors = append(interceptors, &Retry{
+				MaxAttempts: config.Retry.MaxAttempts,
+				Backoff: util.Backoff{
+					Initial: config.Retry.InitialBackoff,
+					Max:     config.Retry.MaxBackoff,
+				},
+				Retryable:  deps.Retryable,
+				Operations: config.Retry.Operations,
+			})
+
+		case StageFaults:
+			if config.Faults.ErrorRate < 0 || config.Faults.ErrorRate > 1 {
+				return nil, errors.Errorf("faults.error_rate must be between 0 and 1, was %f", config.Faults.ErrorRate)
+			}
+			interceptors = append(interceptors, &FaultInjector{
+				ErrorRate:     config.Faults.ErrorRate,
+				Latency:       config.Faults.Latency,
+				LatencyJitter: config.Faults.LatencyJitter,
+				Operations:    config.Faults.Operations,
+			})
+
+		default:
+			return nil, errors.Errorf("unknown middleware stage: \"%s\"", stage)
+		}
+	}
+
+	return Chain(inner, interceptors...), nil
+}
+
+// listFlag sets a list from a comma-separated flag.
+type listFlag []string
+
+func (f *listFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(*f, ",")
+}
+
+func (f *listFlag) Set(value string) error {
+	*f = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*f = append(*f, item)
+		}
+	}
+	return nil
+}
+
+// stagesFlag sets a pipeline from a comma-separated flag. The timing stage is
+// left out, since it needs a Dependencies.Recorder that flags cannot provide.
+type stagesFlag []string
+
+func (f *stagesFlag) String() string {
+	return (*listFlag)(f).String()
+}
+
+func (f *stagesFlag) Set(value string) error {
+	var items listFlag
+	items.Set(value)
+
+	for _, item := range items {
+		switch item {
+		case StageLogging, StageRetry, StageFaults:
+		case StageTiming:
+			return errors.New("the timing stage cannot be set from flags, since it needs a latency recorder")
+		default:
+			return fmt.Errorf("unsupported middleware stage: \"%s\"", item)
+		}
+	}
+	*f = stagesFlag(items)
+	return nil
+}
+
+// operationsFlag sets a list of operations from a comma-separated flag.
+type operationsFlag []s.Operation
+
+func (f *operationsFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	names := make([]string, len(*f))
+	for i, op := range *f {
+		names[i] = string(op)
+	}
+	return strings.Join(names, ",")
+}
+
+func (f *operationsFlag) Set(value string) error {
+	var items listFlag
+	items.Set(value)
+
+	*f = nil
+	for _, item := range items {
+		if !slices.Contains(s.Operations, s.Operation(item)) {
+			return fmt.Errorf("unsupported operation: \"%s\"", item)
+		}
+		*f = append(*f, s.Operation(item))
+	}
+	return nil
+}
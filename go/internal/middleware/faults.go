@@ -0,0 +1,61 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+// InjectedError is returned by FaultInjector in place of calling the server.
+// It is temporary, so Retry treats it as transient by default.
+type InjectedError struct {
+	Op s.Operation
+}
+
+func (e *InjectedError) Error() string {
+	return fmt.Sprintf("injected fault, op=\"%s\"", e.Op)
+}
+
+func (e *InjectedError) Temporary() bool {
+	return true
+}
+
+// FaultInjector delays calls and fails a fraction of them without reaching
+// the server.
+type FaultInjector struct {
+	// ErrorRate is the fraction of calls, between 0 and 1, that fail.
+	ErrorRate float64
+	// Latency is added before every call, plus a random amount up to
+	// LatencyJitter.
+	Latency       time.Duration
+	LatencyJitter time.Duration
+	// Operations limits faults to the listed operations. Every operation is
+	// affected when it is empty.
+	Operations []s.Operation
+}
+
+func (f *FaultInjector) Intercept(ctx context.Context, op s.Operation, request any, call Call) error {
+	if len(f.Operations) > 0 && !slices.Contains(f.Operations, op) {
+		return call(ctx)
+	}
+
+	delay := f.Latency
+	if f.LatencyJitter > 0 {
+		delay += rand.N(f.LatencyJitter + 1)
+	}
+	err := util.Sleep(ctx, delay)
+	if err != nil {
+		return err
+	}
+
+	if f.ErrorRate > 0 && rand.Float64() < f.ErrorRate {
+		return &InjectedError{Op: op}
+	}
+
+	return call(ctx)
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Logging logs every call. Failed calls are logged at warn level, calls slower
+// than SlowThreshold at info level, and everything else at debug level. It
+// also adds the operation and caller ID of every call to its context, so that
+// records logged on behalf of the call through a logger from logging.New carry
+// them.
+type Logging struct {
+	Logger *slog.Logger
+	// SlowThreshold disables slow-call logging when zero.
+	SlowThreshold time.Duration
+}
+
+func (l *Logging) Intercept(ctx context.Context, op s.Operation, request any, call Call) error {
+	attrs := []slog.Attr{slog.String("operation", string(op))}
+	if callerID := s.CallerID(request); callerID != "" {
+		attrs = append(attrs, slog.String("caller_id", callerID))
+	}
+
+	start := time.Now()
+	err := call(logging.WithAttrs(ctx, attrs...))
+	latency := time.Since(start)
+
+	level := slog.LevelDebug
+	msg := "call finished"
+	if err != nil {
+		level = slog.LevelWarn
+		msg = "call failed"
+	} else if l.SlowThreshold > 0 && latency >= l.SlowThreshold {
+		level = slog.LevelInfo
+		msg = "slow call"
+	}
+
+	if l.Logger.Enabled(ctx, level) {
+		attrs = append(attrs, slog.Duration("latency", latency))
+		if err != nil {
+			attrs = append(attrs, slog.String("error", err.Error()))
+		}
+		l.Logger.LogAttrs(ctx, level, msg, attrs...)
+	}
+
+	return err
+}
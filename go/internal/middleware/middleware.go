@@ -0,0 +1,116 @@
+// Package middleware wraps an s.Server with cross-cutting behavior such as
+// timing, logging, retries and fault injection.
+package middleware
+
+import (
+	"context"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Call runs one s.Server method with the given context.
+type Call func(ctx context.Context) error
+
+// Interceptor runs around every call made through a wrapped s.Server. request
+// is the call's request, such as *s.GetPostRequest, and must not be modified.
+// It must call call at most once per attempt and return its error, or its
+// own.
+type Interceptor interface {
+	Intercept(ctx context.Context, op s.Operation, request any, call Call) error
+}
+
+// InterceptorFunc adapts a function to the Interceptor interface.
+type InterceptorFunc func(ctx context.Context, op s.Operation, request any, call Call) error
+
+func (f InterceptorFunc) Intercept(ctx context.Context, op s.Operation, request any, call Call) error {
+	return f(ctx, op, request, call)
+}
+
+// Chain wraps inner with interceptors. The first interceptor is the outermost,
+// so it sees every call first and every result last.
+func Chain(inner s.Server, interceptors ...Interceptor) s.Server {
+	server := inner
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		server = Wrap(server, interceptors[i])
+	}
+	return server
+}
+
+// Wrap returns an s.Server that sends every call to next through interceptor.
+func Wrap(next s.Server, interceptor Interceptor) s.Server {
+	return &wrappedServer{
+		next:        next,
+		interceptor: interceptor,
+	}
+}
+
+type wrappedServer struct {
+	next        s.Server
+	interceptor Interceptor
+}
+
+// Enforce that wrappedServer implements s.Server interface.
+var _ s.Server = &wrappedServer{}
+
+// intercept sends one call through the interceptor and returns the response
+// of the last attempt.
+func intercept[Req any, Resp any](
+	w *wrappedServer,
+	ctx context.Context,
+	op s.Operation,
+	request *Req,
+	method func(context.Context, *Req) (*Resp, error)) (*Resp, error) {
+
+	var resp *Resp
+	err := w.interceptor.Intercept(ctx, op, request, func(ctx context.Context) error {
+		var err error
+		resp, err = method(ctx, request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (w *wrappedServer) CreateUser(
+	ctx context.Context, request *s.CreateUserRequest) (*s.CreateUserResponse, error) {
+	return intercept(w, ctx, s.OpCreateUser, request, w.next.CreateUser)
+}
+
+func (w *wrappedServer) GetUser(ctx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	return intercept(w, ctx, s.OpGetUser, request, w.next.GetUser)
+}
+
+func (w *wrappedServer) FollowUser(
+	ctx context.Context, request *s.FollowUserRequest) (*s.FollowUserResponse, error) {
+	return intercept(w, ctx, s.OpFollowUser, request, w.next.FollowUser)
+}
+
+func (w *wrappedServer) GetUserFeed(
+	ctx context.Context, request *s.GetUserFeedRequest) (*s.GetUserFeedResponse, error) {
+	return intercept(w, ctx, s.OpGetUserFeed, request, w.next.GetUserFeed)
+}
+
+func (w *wrappedServer) GetFollowedFeed(
+	ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+	return intercept(w, ctx, s.OpGetFollowedFeed, request, w.next.GetFollowedFeed)
+}
+
+func (w *wrappedServer) GetFollowed(
+	ctx context.Context, request *s.GetFollowedRequest) (*s.GetFollowedResponse, error) {
+	return intercept(w, ctx, s.OpGetFollowed, request, w.next.GetFollowed)
+}
+
+func (w *wrappedServer) CreatePost(
+	ctx context.Context, request *s.CreatePostRequest) (*s.CreatePostResponse, error) {
+	return intercept(w, ctx, s.OpCreatePost, request, w.next.CreatePost)
+}
+
+func (w *wrappedServer) GetPost(ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
+	return intercept(w, ctx, s.OpGetPost, request, w.next.GetPost)
+}
+
+func (w *wrappedServer) LikePost(ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+	return intercept(w, ctx, s.OpLikePost, request, w.next.LikePost)
+}
@@ -0,0 +1,203 @@
+package middleware_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/logging"
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func getTestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+// failFirst fails the first n calls of each operation with a temporary error.
+func failFirst(n int) middleware.Interceptor {
+	failures := map[s.Operation]int{}
+	return middleware.InterceptorFunc(func(ctx context.Context, op s.Operation, request any, call middleware.Call) error {
+		if failures[op] < n {
+			failures[op]++
+			return &middleware.InjectedError{Op: op}
+		}
+		return call(ctx)
+	})
+}
+
+func TestChainOrder(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	order := []string{}
+	named := func(name string) middleware.Interceptor {
+		return middleware.InterceptorFunc(func(ctx context.Context, op s.Operation, request any, call middleware.Call) error {
+			order = append(order, name+":"+string(op))
+			return call(ctx)
+		})
+	}
+
+	server := middleware.Chain(servertest.NewFakeServer(), named("outer"), named("inner"))
+	_, err := server.GetPost(ctx, &s.GetPostRequest{CallerID: "caller", PostID: "post"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"outer:GetPost", "inner:GetPost"}, order)
+}
+
+func TestLogging(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, &logging.Config{Level: slog.LevelInfo, Format: logging.FormatJSON})
+	require.NoError(t, err)
+	inner := middleware.InterceptorFunc(func(ctx context.Context, op s.Operation, request any, call middleware.Call) error {
+		logger.InfoContext(ctx, "inside call")
+		return &middleware.InjectedError{Op: op}
+	})
+	server := middleware.Chain(servertest.NewFakeServer(), &middleware.Logging{Logger: logger}, inner)
+
+	// Act:
+	_, err = server.GetPost(ctx, &s.GetPostRequest{CallerID: "caller", PostID: "post"})
+	require.Error(t, err)
+
+	// Assert: The record logged inside the call carries its operation and
+	// caller, and the failure is logged once.
+	decoder := json.NewDecoder(&buf)
+	records := []map[string]any{}
+	for decoder.More() {
+		var record map[string]any
+		require.NoError(t, decoder.Decode(&record))
+		records = append(records, record)
+	}
+	require.Len(t, records, 2)
+	require.Equal(t, "inside call", records[0]["msg"])
+	require.Equal(t, "GetPost", records[0]["operation"])
+	require.Equal(t, "caller", records[0]["caller_id"])
+	require.Equal(t, "call failed", records[1]["msg"])
+	require.Equal(t, "GetPost", records[1]["operation"])
+	require.Contains(t, records[1]["error"], "injected fault")
+}
+
+func TestRetry(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	inner := servertest.NewFakeServer()
+	recorder := stats.NewRecorder()
+	retry := &middleware.Retry{MaxAttempts: 3, Operations: []s.Operation{s.OpCreateUser}}
+	server := middleware.Chain(inner, &middleware.Timing{Recorder: recorder}, retry, failFirst(2))
+
+	// Act: Two failures are retried, so the call succeeds on its third attempt.
+	resp, err := server.CreateUser(ctx, &s.CreateUserRequest{UserName: "user", Role: s.RoleViewer})
+	require.NoError(t, err)
+	require.Equal(t, "user", resp.User.UserName)
+	require.Equal(t, 1, inner.Calls(s.OpCreateUser))
+
+	snapshot := recorder.Snapshot()
+	require.Equal(t, uint64(1), snapshot[s.OpCreateUser].Latency.Total)
+	require.Equal(t, uint64(0), snapshot[s.OpCreateUser].Errors)
+
+	// Act: Permanent errors are not retried.
+	_, err = server.CreateUser(ctx, &s.CreateUserRequest{Role: s.RoleViewer})
+	require.Error(t, err)
+	require.Equal(t, 2, inner.Calls(s.OpCreateUser))
+	require.Equal(t, uint64(1), recorder.Snapshot()[s.OpCreateUser].Errors)
+}
+
+func TestRetryGivesUp(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	inner := servertest.NewFakeServer()
+	server := middleware.Chain(inner, &middleware.Retry{MaxAttempts: 2}, failFirst(2))
+
+	_, err := server.GetUser(ctx, &s.GetUserRequest{CallerID: "caller", UserID: "user"})
+	var injected *middleware.InjectedError
+	require.True(t, errors.As(err, &injected))
+	require.Equal(t, 0, inner.Calls(s.OpGetUser))
+}
+
+func TestRetryReadsOnly(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	inner := servertest.NewFakeServer()
+	server := middleware.Chain(inner, &middleware.Retry{MaxAttempts: 2}, failFirst(1))
+
+	// Act: Writes are not retried unless they are listed.
+	_, err := server.CreateUser(ctx, &s.CreateUserRequest{UserName: "user", Role: s.RoleViewer})
+	require.Error(t, err)
+	require.Equal(t, 0, inner.Calls(s.OpCreateUser))
+
+	// Act: Reads are.
+	_, err = server.GetUser(ctx, &s.GetUserRequest{CallerID: "caller", UserID: "user"})
+	require.NoError(t, err)
+	require.Equal(t, 1, inner.Calls(s.OpGetUser))
+}
+
+func TestBuild(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	inner := servertest.NewFakeServer()
+	recorder := stats.NewRecorder()
+	server, err := middleware.Build(inner, &middleware.Config{
+		Pipeline: []string{middleware.StageTiming, middleware.StageFaults},
+		Faults: middleware.FaultsConfig{
+			ErrorRate:  1,
+			Latency:    time.Millisecond,
+			Operations: []s.Operation{s.OpLikePost},
+		},
+	}, &middleware.Dependencies{Recorder: recorder})
+	require.NoError(t, err)
+
+	// Act: LikePost always fails, other operations are untouched.
+	_, err = server.LikePost(ctx, &s.LikePostRequest{CallerID: "caller", PostID: "post"})
+	require.Error(t, err)
+	_, err = server.GetPost(ctx, &s.GetPostRequest{CallerID: "caller", PostID: "post"})
+	require.NoError(t, err)
+
+	snapshot := recorder.Snapshot()
+	require.Equal(t, uint64(1), snapshot[s.OpLikePost].Errors)
+	require.GreaterOrEqual(t, snapshot[s.OpLikePost].Latency.Min, time.Millisecond)
+	require.Equal(t, uint64(0), snapshot[s.OpGetPost].Errors)
+	require.Equal(t, 0, inner.Calls(s.OpLikePost))
+	require.Equal(t, 1, inner.Calls(s.OpGetPost))
+
+	_, err = middleware.Build(inner, &middleware.Config{Pipeline: []string{"unknown"}}, nil)
+	require.Error(t, err)
+}
+
+func TestConfigFlags(t *testing.T) {
+	config := *middleware.DefaultConfig
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	config.RegisterFlags(flags)
+
+	// Act:
+	err := flags.Parse([]string{"-middleware", "logging, retry,faults", "-retry-ops", "GetPost,GetUser", "-fault-error-rate", "0.5"})
+	require.NoError(t, err)
+
+	// Assert: The default is left untouched.
+	require.Equal(t, []string{middleware.StageLogging, middleware.StageRetry, middleware.StageFaults}, config.Pipeline)
+	require.Equal(t, []string{middleware.StageLogging}, middleware.DefaultConfig.Pipeline)
+	require.Equal(t, []s.Operation{s.OpGetPost, s.OpGetUser}, config.Retry.Operations)
+	require.Equal(t, 0.5, config.Faults.ErrorRate)
+	require.Equal(t, 3, config.Retry.MaxAttempts)
+	_, err = middleware.Build(servertest.NewFakeServer(), &config, nil)
+	require.NoError(t, err)
+
+	err = flags.Parse([]string{"-fault-ops", "Unknown"})
+	require.ErrorContains(t, err, "unsupported operation")
+
+	err = flags.Parse([]string{"-middleware", "timing,logging"})
+	require.ErrorContains(t, err, "timing stage")
+}
@@ -0,0 +1,76 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"slices"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+// Retry repeats calls that fail with a transient error, waiting a jittered,
+// exponentially growing delay between attempts.
+type Retry struct {
+	// MaxAttempts includes the first attempt, so 1 disables retries.
+	MaxAttempts int
+	Backoff     util.Backoff
+	// Retryable decides which errors, besides those IsTransient accepts, are
+	// worth retrying, such as postgres.IsRetryable. Only transient errors are
+	// retried when it is nil.
+	Retryable func(err error) bool
+	// Operations lists the operations that are retried. A write that fails
+	// may still have committed, so only IdempotentOperations are retried
+	// when it is empty, and writes must be listed to be retried.
+	Operations []s.Operation
+}
+
+// IdempotentOperations are the operations that can be repeated without
+// changing their outcome: the reads.
+var IdempotentOperations = []s.Operation{
+	s.OpGetUser,
+	s.OpGetUserFeed,
+	s.OpGetFollowedFeed,
+	s.OpGetFollowed,
+	s.OpGetPost,
+}
+
+func (r *Retry) Intercept(ctx context.Context, op s.Operation, request any, call Call) error {
+	operations := r.Operations
+	if len(operations) == 0 {
+		operations = IdempotentOperations
+	}
+	if !slices.Contains(operations, op) {
+		return call(ctx)
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = call(ctx)
+		if err == nil || attempt >= r.MaxAttempts || !r.retryable(err) {
+			return err
+		}
+
+		sleepErr := util.Sleep(ctx, r.Backoff.Delay(attempt))
+		if sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (r *Retry) retryable(err error) bool {
+	return IsTransient(err) || (r.Retryable != nil && r.Retryable(err))
+}
+
+// IsTransient reports whether err is a network timeout or says it is temporary,
+// as injected faults do.
+func IsTransient(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var temporary interface{ Temporary() bool }
+	return errors.As(err, &temporary) && temporary.Temporary()
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// LatencyRecorder receives the outcome of every call. stats.Recorder
+// implements it.
+type LatencyRecorder interface {
+	Record(op s.Operation, latency time.Duration, err error)
+}
+
+// Timing records the latency and error of every call.
+type Timing struct {
+	Recorder LatencyRecorder
+}
+
+func (t *Timing) Intercept(ctx context.Context, op s.Operation, request any, call Call) error {
+	start := time.Now()
+	err := call(ctx)
+	t.Recorder.Record(op, time.Since(start), err)
+	return err
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"encoding/base64"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pageCursor marks the last row of a page. Pages are ordered by (CreatedAt, ID)
+// descending, so the next page starts strictly after the cursor.
+type pageCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "," + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor returns a nil cursor for the first page.
+func decodeCursor(cursor string) (*pageCursor, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.Wrapf(err, "decoding cursor failed, cursor=\"%s\"", cursor)
+	}
+
+	createdAtStr, id, found := strings.Cut(string(raw), ",")
+	if !found || id == "" {
+		return nil, errors.Errorf("malformed cursor, cursor=\"%s\"", cursor)
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, createdAtStr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing cursor timestamp failed, cursor=\"%s\"", cursor)
+	}
+
+	return &pageCursor{
+		CreatedAt: createdAt,
+		ID:        id,
+	}, nil
+}
+
+// args returns the cursor as query arguments. A nil cursor becomes two NULLs.
+func (c *pageCursor) args() (any, any) {
+	if c == nil {
+		return nil, nil
+	}
+	return c.CreatedAt, c.ID
+}
+
+func getPageLimit(limit int) (int, error) {
+	if limit < 0 {
+		return 0, errors.Errorf("limit was negative, limit=%d", limit)
+	} else if limit == 0 {
+		return defaultPageLimit, nil
+	} else if limit > maxPageLimit {
+		return maxPageLimit, nil
+	}
+	return limit, nil
+}
@@ -3,7 +3,7 @@ package postgres
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/pkg/errors"
@@ -43,13 +43,13 @@ func (d *DBManager) InitDB(parentCtx context.Context) error {
 		return errors.Wrap(err, "checking if feeds database exists failed")
 	}
 
-	if feedsDBExists {
-		return nil
-	}
-
-	_, err = postgresConn.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s;", dbFeed))
-	if err != nil {
-		return errors.Wrap(err, "creating feeds db failed")
+	// The schema below only creates what is missing, so it also runs on an
+	// existing database to add indexes introduced since it was created.
+	if !feedsDBExists {
+		_, err = postgresConn.Exec(ctx, fmt.Sprintf("CREATE DATABASE %s;", dbFeed))
+		if err != nil {
+			return errors.Wrap(err, "creating feeds db failed")
+		}
 	}
 
 	feedConn, err := d.openConn(parentCtx, dbFeed)
@@ -91,6 +91,12 @@ func (d *DBManager) InitDB(parentCtx context.Context) error {
 		);
 
 		CREATE INDEX IF NOT EXISTS likes_post_id_idx ON likes (post_id);
+
+		CREATE INDEX IF NOT EXISTS posts_owner_id_created_at_idx
+			ON posts (owner_id, created_at DESC, post_id DESC);
+
+		CREATE INDEX IF NOT EXISTS follows_source_id_created_at_idx
+			ON follows (source_id, created_at DESC, target_id DESC);
 	`)
 	if err != nil {
 		return errors.Wrap(err, "initializing feed db failed")
@@ -153,6 +159,6 @@ func (d *DBManager) closeConn(parentCtx context.Context, conn *pgx.Conn) {
 
 	err := conn.Close(ctx)
 	if err != nil {
-		log.Printf("closing connection failed: %+v\n", err)
+		slog.WarnContext(ctx, "closing connection failed", "error", err)
 	}
 }
@@ -0,0 +1,107 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrorClass groups errors by what a caller can do about them.
+type ErrorClass string
+
+const (
+	ErrorClassNone ErrorClass = ""
+	// ErrorClassSerialization is a serialization failure (40001).
+	ErrorClassSerialization ErrorClass = "serialization_failure"
+	// ErrorClassDeadlock is a detected deadlock (40P01).
+	ErrorClassDeadlock ErrorClass = "deadlock"
+	// ErrorClassLockNotAvailable is a lock that could not be acquired in time
+	// (55P03).
+	ErrorClassLockNotAvailable ErrorClass = "lock_not_available"
+	// ErrorClassConnection is a lost, reset or refused connection, including
+	// server shutdowns and connection limits.
+	ErrorClassConnection ErrorClass = "connection"
+	// ErrorClassTimeout is a cancelled statement or an expired context.
+	ErrorClassTimeout ErrorClass = "timeout"
+	// ErrorClassPermanent is everything else, which will fail again if retried.
+	ErrorClassPermanent ErrorClass = "permanent"
+)
+
+// Retryable reports whether an operation that failed with this class of
+// error can succeed if it is run again from the start.
+func (c ErrorClass) Retryable() bool {
+	switch c {
+	case ErrorClassSerialization, ErrorClassDeadlock, ErrorClassLockNotAvailable, ErrorClassConnection:
+		return true
+	default:
+		return false
+	}
+}
+
+// ClassifyError classifies err by its SQLSTATE when it came from Postgres,
+// and by its cause otherwise.
+func ClassifyError(err error) ErrorClass {
+	if err == nil {
+		return ErrorClassNone
+	}
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return classifySQLState(pgErr.Code)
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrorClassTimeout
+	}
+
+	var connectErr *pgconn.ConnectError
+	if errors.As(err, &connectErr) || pgconn.SafeToRetry(err) {
+		return ErrorClassConnection
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassPermanent
+}
+
+// IsRetryable reports whether err is worth retrying. It can be used as the
+// middleware.Retry Retryable function.
+func IsRetryable(err error) bool {
+	return ClassifyError(err).Retryable()
+}
+
+func classifySQLState(code string) ErrorClass {
+	switch code {
+	case "40001":
+		return ErrorClassSerialization
+	case "40P01":
+		return ErrorClassDeadlock
+	case "55P03":
+		return ErrorClassLockNotAvailable
+	case "57014":
+		// query_canceled, raised by statement_timeout.
+		return ErrorClassTimeout
+	case "57P01", "57P02", "57P03", "53300":
+		// Server shutting down, or too many connections.
+		return ErrorClassConnection
+	}
+
+	// Class 08 is connection exception.
+	if len(code) == 5 && code[:2] == "08" {
+		return ErrorClassConnection
+	}
+
+	return ErrorClassPermanent
+}
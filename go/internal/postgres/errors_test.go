@@ -0,0 +1,43 @@
+package postgres_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err       error
+		class     p.ErrorClass
+		retryable bool
+	}{
+		{nil, p.ErrorClassNone, false},
+		{&pgconn.PgError{Code: "40001"}, p.ErrorClassSerialization, true},
+		{&pgconn.PgError{Code: "40P01"}, p.ErrorClassDeadlock, true},
+		{&pgconn.PgError{Code: "55P03"}, p.ErrorClassLockNotAvailable, true},
+		{&pgconn.PgError{Code: "08006"}, p.ErrorClassConnection, true},
+		{&pgconn.PgError{Code: "57P01"}, p.ErrorClassConnection, true},
+		{&pgconn.PgError{Code: "57014"}, p.ErrorClassTimeout, false},
+		{&pgconn.PgError{Code: "23505"}, p.ErrorClassPermanent, false},
+		{io.ErrUnexpectedEOF, p.ErrorClassConnection, true},
+		{context.DeadlineExceeded, p.ErrorClassTimeout, false},
+		{errors.New("given user does not exist"), p.ErrorClassPermanent, false},
+	}
+
+	for _, c := range cases {
+		require.Equal(t, c.class, p.ClassifyError(c.err), "err=%v", c.err)
+		require.Equal(t, c.retryable, p.IsRetryable(c.err), "err=%v", c.err)
+
+		// Wrapping must not change the class.
+		if c.err != nil {
+			wrapped := errors.Wrap(c.err, "following user failed")
+			require.Equal(t, c.class, p.ClassifyError(wrapped), "err=%v", wrapped)
+		}
+	}
+}
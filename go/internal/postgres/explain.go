@@ -0,0 +1,180 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// CapturedQuery is a query PGServer ran, with the arguments of its first
+// call.
+type CapturedQuery struct {
+	Method    string
+	Statement string
+	SQL       string
+	Args      []any
+}
+
+// QueryCapture is a pgx.QueryTracer that keeps the first call of every query
+// tagged with a PGServer method, so that it can be explained later. Add it to
+// PGServerOptions.QueryTracers.
+type QueryCapture struct {
+	lock    sync.Mutex
+	queries map[string]*CapturedQuery
+	order   []string
+}
+
+// Enforce that QueryCapture implements pgx.QueryTracer interface.
+var _ pgx.QueryTracer = &QueryCapture{}
+
+func NewQueryCapture() *QueryCapture {
+	return &QueryCapture{
+		queries: map[string]*CapturedQuery{},
+	}
+}
+
+func (c *QueryCapture) TraceQueryStart(
+	ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+
+	method := QueryMethod(data.SQL)
+	if method == "" {
+		return ctx
+	}
+	statement := statementName(data.SQL)
+	key := method + "\n" + statement
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.queries[key]; !ok {
+		c.queries[key] = &CapturedQuery{
+			Method:    method,
+			Statement: statement,
+			SQL:       data.SQL,
+			Args:      append([]any{}, data.Args...),
+		}
+		c.order = append(c.order, key)
+	}
+	return ctx
+}
+
+func (c *QueryCapture) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+}
+
+// Queries returns the captured queries in the order they were first run.
+func (c *QueryCapture) Queries() []*CapturedQuery {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	queries := make([]*CapturedQuery, 0, len(c.order))
+	for _, key := range c.order {
+		queries = append(queries, c.queries[key])
+	}
+	return queries
+}
+
+// Explainer runs EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) on its own
+// connection. Every query is run in a transaction that is rolled back, so
+// explaining writes leaves the dataset as it was.
+type Explainer struct {
+	conn *pgx.Conn
+	// Capture provides the queries explained by QueryPlans.
+	Capture *QueryCapture
+}
+
+func NewExplainer(
+	parentCtx context.Context,
+	connOptions *ConnStringOptions,
+	capture *QueryCapture) (*Explainer, error) {
+
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, connOptions.GetConnString(dbFeed))
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting failed, connString=\"%s\"", connOptions.GetDebugConnString(dbFeed))
+	}
+
+	return &Explainer{
+		conn:    conn,
+		Capture: capture,
+	}, nil
+}
+
+func (p *Explainer) Close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
+
+// QueryPlans explains every captured query against the current dataset, for
+// run results. A query that cannot be explained gets a plan with its error.
+func (p *Explainer) QueryPlans(ctx context.Context) ([]*stats.QueryPlan, error) {
+	plans := []*stats.QueryPlan{}
+	for _, query := range p.Capture.Queries() {
+		plan, err := p.Explain(ctx, query)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			plan = &stats.QueryPlan{
+				Method:    query.Method,
+				Statement: query.Statement,
+				Error:     err.Error(),
+			}
+		}
+		plans = append(plans, plan)
+	}
+	return plans, nil
+}
+
+// Explain runs query with EXPLAIN ANALYZE and returns its plan.
+func (p *Explainer) Explain(parentCtx context.Context, query *CapturedQuery) (*stats.QueryPlan, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	tx, err := p.conn.Begin(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting transaction failed")
+	}
+	defer tx.Rollback(ctx)
+
+	var output []byte
+	err = tx.QueryRow(ctx, "EXPLAIN (ANALYZE, BUFFERS, FORMAT JSON) "+query.SQL, query.Args...).Scan(&output)
+	if err != nil {
+		return nil, errors.Wrapf(err, "explaining query failed, method=\"%s\"", query.Method)
+	}
+
+	plan, err := parsePlan(output)
+	if err != nil {
+		return nil, err
+	}
+	plan.Method = query.Method
+	plan.Statement = query.Statement
+	return plan, nil
+}
+
+// parsePlan parses the output of EXPLAIN (ANALYZE, FORMAT JSON), which is a
+// list holding one plan.
+func parsePlan(output []byte) (*stats.QueryPlan, error) {
+	var explained []struct {
+		Plan          *stats.PlanNode `json:"Plan"`
+		PlanningTime  float64         `json:"Planning Time"`
+		ExecutionTime float64         `json:"Execution Time"`
+	}
+	err := json.Unmarshal(output, &explained)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing plan failed")
+	} else if len(explained) != 1 {
+		return nil, errors.Errorf("expected 1 plan, got %d", len(explained))
+	}
+
+	return &stats.QueryPlan{
+		Root:          explained[0].Plan,
+		PlanningTime:  millisecondsToDuration(explained[0].PlanningTime),
+		ExecutionTime: millisecondsToDuration(explained[0].ExecutionTime),
+	}, nil
+}
@@ -0,0 +1,79 @@
+package postgres_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	"github.com/jackc/pgx/v5"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	_, server := newTestEnv(ctx, t)
+	server.Close()
+
+	capture := p.NewQueryCapture()
+	serverOptions := *p.DefaultPGServerOptions
+	serverOptions.QueryTracers = []pgx.QueryTracer{capture}
+	server, err := p.NewPGServer(ctx, p.DevConnStringOptions, &serverOptions)
+	require.NoError(t, err)
+	defer server.Close()
+
+	// Enough posts and likes that scanning a table costs more than an index.
+	_, err = server.DBPool.Exec(ctx, `
+		INSERT INTO posts (post_id, owner_id, created_at, content)
+		SELECT gen_random_uuid(), md5((i % 100)::TEXT)::UUID, now() - i * INTERVAL '1 minute', 'post'
+		FROM generate_series(1, 2000) i;
+
+		INSERT INTO likes (post_id, user_id, created_at)
+		SELECT post_id, gen_random_uuid(), now()
+		FROM posts, generate_series(1, 10);
+
+		ANALYZE posts, likes;
+	`)
+	require.NoError(t, err)
+
+	var postID, ownerID string
+	err = server.DBPool.QueryRow(ctx, "SELECT post_id, owner_id FROM posts LIMIT 1;").Scan(&postID, &ownerID)
+	require.NoError(t, err)
+	callerID := gofakeit.UUID()
+
+	_, err = server.GetPost(ctx, &s.GetPostRequest{CallerID: callerID, PostID: postID})
+	require.NoError(t, err)
+	_, err = server.GetUserFeed(ctx, &s.GetUserFeedRequest{CallerID: callerID, OwnerID: ownerID})
+	require.NoError(t, err)
+
+	explainer, err := p.NewExplainer(ctx, p.DevConnStringOptions, capture)
+	require.NoError(t, err)
+	defer explainer.Close(ctx)
+
+	// Act:
+	plans, err := explainer.QueryPlans(ctx)
+	require.NoError(t, err)
+
+	// Assert:
+	likeCount := findPlan(t, plans, "getPost", "SELECT COUNT(*) FROM likes")
+	require.True(t, likeCount.UsesIndex("likes_post_id_idx"), "scans=%v", likeCount.Scans())
+
+	userFeed := findPlan(t, plans, "GetUserFeed", "")
+	require.False(t, userFeed.HasSeqScan("posts"), "scans=%v", userFeed.Scans())
+	require.True(t, userFeed.UsesIndex("posts_owner_id_created_at_idx"), "scans=%v", userFeed.Scans())
+}
+
+func findPlan(t *testing.T, plans []*stats.QueryPlan, method string, statementPrefix string) *stats.QueryPlan {
+	for _, plan := range plans {
+		if plan.Method == method && strings.HasPrefix(plan.Statement, statementPrefix) {
+			require.Empty(t, plan.Error)
+			return plan
+		}
+	}
+	require.FailNow(t, "plan not found", "method=%s statement=%s", method, statementPrefix)
+	return nil
+}
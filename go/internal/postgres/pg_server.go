@@ -2,8 +2,8 @@ package postgres
 
 import (
 	"context"
-	"database/sql"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -11,8 +11,10 @@ import (
 	e "errors"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/multitracer"
 	"github.com/jackc/pgx/v5/pgxpool"
 	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
 	"github.com/jlym/dbbenchmark/go/internal/util"
 )
 
@@ -22,28 +24,143 @@ const (
 )
 
 type PGServer struct {
-	DBPool *pgxpool.Pool
-	Clock  util.Clock
+	DBPool  *pgxpool.Pool
+	Clock   util.Clock
+	Options *PGServerOptions
+	// Statements records every SQL statement run by the pool when
+	// Options.TraceStatements is set.
+	Statements *stats.StatementRecorder
+
+	txStats *txStatsRecorder
+	// settings holds the server version and the settings in
+	// reportedSettings, read when the server is created.
+	settings map[string]string
 }
 
 // Enforce that PGServer implements s.Server interface.
-// var _ s.Server = &PGServer{}
+var _ s.Server = &PGServer{}
+
+// NewPGServer connects to the feed database. DefaultPGServerOptions is used
+// when serverOptions is nil.
+func NewPGServer(
+	parentCtx context.Context,
+	connOptions *ConnStringOptions,
+	serverOptions *PGServerOptions) (*PGServer, error) {
+
+	if serverOptions == nil {
+		serverOptions = DefaultPGServerOptions
+	}
 
-func NewPGServer(parentCtx context.Context, connOptions *ConnStringOptions) (*PGServer, error) {
 	ctx, cancel := getQueryContext(parentCtx)
 	defer cancel()
 
-	dbPool, err := pgxpool.New(ctx, connOptions.GetConnString(dbFeed))
+	poolConfig, err := pgxpool.ParseConfig(connOptions.GetConnString(dbFeed))
+	if err != nil {
+		return nil, errors.Wrapf(err, "parsing connection string failed, connString=\"%s\"", connOptions.GetDebugConnString(dbFeed))
+	}
+	if serverOptions.ExecMode != 0 {
+		poolConfig.ConnConfig.DefaultQueryExecMode = serverOptions.ExecMode
+	}
+	if serverOptions.MaxConns > 0 {
+		poolConfig.MaxConns = serverOptions.MaxConns
+	}
+	if _, ok := feedQueries[serverOptions.feedStrategy()]; !ok {
+		return nil, errors.Errorf("unknown feed strategy: \"%s\"", serverOptions.FeedStrategy)
+	}
+	statements := stats.NewStatementRecorder()
+	tracers := serverOptions.QueryTracers
+	if serverOptions.TraceStatements {
+		tracers = append([]pgx.QueryTracer{&statementTracer{recorder: statements}}, tracers...)
+	}
+	if len(tracers) == 1 {
+		poolConfig.ConnConfig.Tracer = tracers[0]
+	} else if len(tracers) > 1 {
+		poolConfig.ConnConfig.Tracer = multitracer.New(tracers...)
+	}
+
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolConfig)
 	if err != nil {
 		return nil, errors.Wrapf(err, "creating connection pool failed, connString=\"%s\"", connOptions.GetDebugConnString(dbFeed))
 	}
 
+	settings, err := readSettings(ctx, dbPool)
+	if err != nil {
+		dbPool.Close()
+		return nil, err
+	}
+
 	return &PGServer{
-		DBPool: dbPool,
-		Clock:  util.NewRealClock(),
+		DBPool:     dbPool,
+		Clock:      util.NewRealClock(),
+		Options:    serverOptions,
+		Statements: statements,
+		txStats:    newTxStatsRecorder(),
+		settings:   settings,
 	}, nil
 }
 
+// reportedSettings are the Postgres settings that most affect results.
+var reportedSettings = []string{
+	"server_version",
+	"max_connections",
+	"shared_buffers",
+	"effective_cache_size",
+	"work_mem",
+	"random_page_cost",
+	"synchronous_commit",
+	"fsync",
+	"default_transaction_isolation",
+	"max_parallel_workers_per_gather",
+	"jit",
+	"max_wal_size",
+	"checkpoint_timeout",
+}
+
+// readSettings returns reportedSettings as shown by SHOW, keyed
+// "pg.setting.<name>".
+func readSettings(ctx context.Context, dbPool *pgxpool.Pool) (map[string]string, error) {
+	rows, err := dbPool.Query(ctx, `
+		SELECT name, current_setting(name)
+		FROM pg_settings
+		WHERE name = ANY($1);
+	`, reportedSettings)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading settings failed")
+	}
+	defer rows.Close()
+
+	settings := map[string]string{}
+	for rows.Next() {
+		var name, value string
+		err = rows.Scan(&name, &value)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading settings failed")
+		}
+		settings["pg.setting."+name] = value
+	}
+	return settings, errors.Wrap(rows.Err(), "reading settings failed")
+}
+
+// RunMetadata describes how the server is configured, for run results.
+func (p *PGServer) RunMetadata() map[string]string {
+	isoLevel := string(p.Options.IsoLevel)
+	if isoLevel == "" {
+		isoLevel = "default"
+	}
+
+	metadata := map[string]string{
+		"pg.iso_level":       isoLevel,
+		"pg.exec_mode":       execModeName(p.DBPool.Config().ConnConfig.DefaultQueryExecMode),
+		"pg.tx_max_attempts": strconv.Itoa(p.Options.TxMaxAttempts),
+		"pg.max_conns":       strconv.Itoa(int(p.DBPool.Config().MaxConns)),
+		"pg.feed_strategy":   string(p.Options.feedStrategy()),
+	}
+	for key, value := range p.settings {
+		metadata[key] = value
+	}
+	return metadata
+}
+
 func (p *PGServer) Close() {
 	p.DBPool.Close()
 }
@@ -52,6 +169,8 @@ func (p *PGServer) CreateUser(
 	parentCtx context.Context,
 	request *s.CreateUserRequest) (*s.CreateUserResponse, error) {
 
+	parentCtx = s.WithOperation(parentCtx, s.OpCreateUser)
+
 	if request.UserName == "" {
 		return nil, errors.New("request.UserName was empty")
 	} else if request.Role == "" {
@@ -61,6 +180,7 @@ func (p *PGServer) CreateUser(
 	ctx, cancel := getQueryContext(parentCtx)
 	defer cancel()
 	row := p.DBPool.QueryRow(ctx, `
+		/* dbbenchmark:CreateUser */
 		INSERT INTO users (user_id, user_name, created_at, role)
 		VALUES (gen_random_uuid(), $1, $2, $3)
 		RETURNING user_id, user_name, created_at, role
@@ -87,6 +207,8 @@ func (p *PGServer) CreateUser(
 }
 
 func (p *PGServer) GetUser(parentCtx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	parentCtx = s.WithOperation(parentCtx, s.OpGetUser)
+
 	if request.CallerID == "" {
 		return nil, errors.New("request.CallerID was empty")
 	} else if request.UserID == "" {
@@ -99,6 +221,7 @@ func (p *PGServer) GetUser(parentCtx context.Context, request *s.GetUserRequest)
 	defer cancel()
 
 	row := p.DBPool.QueryRow(ctx, `
+		/* dbbenchmark:GetUser */
 		SELECT user_name, created_at, role
 		FROM users
 		WHERE user_id = $1
@@ -109,10 +232,10 @@ func (p *PGServer) GetUser(parentCtx context.Context, request *s.GetUserRequest)
 	var createdAt time.Time
 	var role s.Role
 	err := row.Scan(&userName, &createdAt, &role)
-	if err == sql.ErrNoRows {
+	if e.Is(err, pgx.ErrNoRows) {
 		return &s.GetUserResponse{}, nil
 	} else if err != nil {
-		return nil, errors.Wrap(err, "creating user failed")
+		return nil, errors.Wrap(err, "querying for user failed")
 	}
 
 	// Check if caller follows the user.
@@ -122,6 +245,7 @@ func (p *PGServer) GetUser(parentCtx context.Context, request *s.GetUserRequest)
 		defer cancel()
 
 		row = p.DBPool.QueryRow(ctx, `
+			/* dbbenchmark:GetUser */
 			SELECT EXISTS (
 				SELECT source_id, target_id
 				FROM follows
@@ -149,6 +273,8 @@ func (p *PGServer) GetUser(parentCtx context.Context, request *s.GetUserRequest)
 func (p *PGServer) FollowUser(
 	ctx context.Context, request *s.FollowUserRequest) (*s.FollowUserResponse, error) {
 
+	ctx = s.WithOperation(ctx, s.OpFollowUser)
+
 	if request.CallerID == "" {
 		return nil, errors.New("request.CallerID was empty")
 	} else if request.TargetUserID == "" {
@@ -156,46 +282,277 @@ func (p *PGServer) FollowUser(
 	}
 	callerID, targetUserID := request.CallerID, request.TargetUserID
 
+	err := p.runInTx(ctx, s.OpFollowUser, func(tx pgx.Tx) error {
+		err := p.assertUserExist(ctx, tx, s.OpFollowUser, callerID)
+		if err != nil {
+			return err
+		}
+		err = p.assertUserExist(ctx, tx, s.OpFollowUser, targetUserID)
+		if err != nil {
+			return err
+		}
+
+		innerCtx, cancel := getQueryContext(ctx)
+		defer cancel()
+		_, err = tx.Exec(innerCtx, `
+			/* dbbenchmark:FollowUser */
+			INSERT INTO follows (source_id, target_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING;
+		`, callerID, targetUserID, p.Clock.NowUtc())
+		return errors.Wrap(err, "inserting follow failed")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s.FollowUserResponse{}, nil
+}
+
+func (p *PGServer) GetUserFeed(
+	ctx context.Context, request *s.GetUserFeedRequest) (*s.GetUserFeedResponse, error) {
+
+	ctx = s.WithOperation(ctx, s.OpGetUserFeed)
+
+	if request.CallerID == "" {
+		return nil, errors.New("request.CallerID was empty")
+	} else if request.OwnerID == "" {
+		return nil, errors.New("request.OwnerID was empty")
+	}
+	callerID, ownerID := request.CallerID, request.OwnerID
+
+	limit, err := getPageLimit(request.Limit)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(request.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	cursorCreatedAt, cursorPostID := cursor.args()
+
 	innerCtx, cancel := getQueryContext(ctx)
 	defer cancel()
-	tx, err := p.DBPool.Begin(innerCtx)
+
+	rows, err := p.DBPool.Query(innerCtx, `
+		/* dbbenchmark:GetUserFeed */
+		SELECT
+			p.post_id,
+			p.owner_id,
+			p.created_at,
+			p.content,
+			(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.post_id),
+			EXISTS (
+				SELECT post_id, user_id
+				FROM likes l
+				WHERE l.post_id = p.post_id AND l.user_id = $2
+			)
+		FROM posts p
+		WHERE p.owner_id = $1
+			AND ($3::TIMESTAMPTZ IS NULL OR (p.created_at, p.post_id) < ($3, $4::UUID))
+		ORDER BY p.created_at DESC, p.post_id DESC
+		LIMIT $5;
+	`, ownerID, callerID, cursorCreatedAt, cursorPostID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying for user feed failed")
+	}
+
+	posts, err := scanPosts(rows)
 	if err != nil {
-		return nil, errors.Wrap(err, "starting transaction failed")
+		return nil, errors.Wrap(err, "reading user feed failed")
+	}
+
+	return &s.GetUserFeedResponse{
+		CallerID: callerID,
+		OwnerID:  ownerID,
+		Posts:    posts,
+		Limit:    limit,
+		Cursor:   getNextPostCursor(posts, limit),
+	}, nil
+}
+
+func (p *PGServer) GetFollowedFeed(
+	ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+
+	ctx = s.WithOperation(ctx, s.OpGetFollowedFeed)
+
+	if request.CallerID == "" {
+		return nil, errors.New("request.CallerID was empty")
 	}
+	callerID := request.CallerID
 
-	err = p.assertUserExist(ctx, tx, callerID)
+	limit, err := getPageLimit(request.Limit)
 	if err != nil {
-		return nil, p.rollbackDueToError(ctx, tx, err)
+		return nil, err
 	}
-	err = p.assertUserExist(ctx, tx, targetUserID)
+	cursor, err := decodeCursor(request.Cursor)
 	if err != nil {
-		return nil, p.rollbackDueToError(ctx, tx, err)
+		return nil, err
 	}
+	cursorCreatedAt, cursorPostID := cursor.args()
 
-	innerCtx, cancel = getQueryContext(ctx)
+	innerCtx, cancel := getQueryContext(ctx)
 	defer cancel()
-	_, err = tx.Exec(innerCtx, `
-		INSERT INTO follows (source_id, target_id, created_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT DO NOTHING;
-	`, callerID, targetUserID, p.Clock.NowUtc())
+
+	query := feedQueries[p.Options.feedStrategy()]
+	rows, err := p.DBPool.Query(innerCtx, query, callerID, cursorCreatedAt, cursorPostID, limit)
 	if err != nil {
-		return nil, p.rollbackDueToError(ctx, tx, err)
+		return nil, errors.Wrap(err, "querying for followed feed failed")
 	}
 
-	innerCtx, cancel = getQueryContext(ctx)
+	posts, err := scanPosts(rows)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading followed feed failed")
+	}
+
+	return &s.GetFollowedFeedResponse{
+		CallerID: callerID,
+		OwnerID:  callerID,
+		Posts:    posts,
+		Limit:    limit,
+		Cursor:   getNextPostCursor(posts, limit),
+	}, nil
+}
+
+// feedQueries holds the followed feed query of each FeedStrategy. Both take
+// the caller, the cursor's created_at and post ID, and the limit.
+var feedQueries = map[FeedStrategy]string{
+	FeedJoin: `
+		/* dbbenchmark:GetFollowedFeed */
+		SELECT
+			p.post_id,
+			p.owner_id,
+			p.created_at,
+			p.content,
+			(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.post_id),
+			EXISTS (
+				SELECT post_id, user_id
+				FROM likes l
+				WHERE l.post_id = p.post_id AND l.user_id = $1
+			)
+		FROM follows f
+		JOIN posts p ON p.owner_id = f.target_id
+		WHERE f.source_id = $1
+			AND ($2::TIMESTAMPTZ IS NULL OR (p.created_at, p.post_id) < ($2, $3::UUID))
+		ORDER BY p.created_at DESC, p.post_id DESC
+		LIMIT $4;
+	`,
+	FeedLateral: `
+		/* dbbenchmark:GetFollowedFeed */
+		SELECT
+			p.post_id,
+			p.owner_id,
+			p.created_at,
+			p.content,
+			(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.post_id),
+			EXISTS (
+				SELECT post_id, user_id
+				FROM likes l
+				WHERE l.post_id = p.post_id AND l.user_id = $1
+			)
+		FROM follows f
+		CROSS JOIN LATERAL (
+			SELECT fp.post_id, fp.owner_id, fp.created_at, fp.content
+			FROM posts fp
+			WHERE fp.owner_id = f.target_id
+				AND ($2::TIMESTAMPTZ IS NULL OR (fp.created_at, fp.post_id) < ($2, $3::UUID))
+			ORDER BY fp.created_at DESC, fp.post_id DESC
+			LIMIT $4
+		) p
+		WHERE f.source_id = $1
+		ORDER BY p.created_at DESC, p.post_id DESC
+		LIMIT $4;
+	`,
+}
+
+func (o *PGServerOptions) feedStrategy() FeedStrategy {
+	if o.FeedStrategy == "" {
+		return FeedJoin
+	}
+	return o.FeedStrategy
+}
+
+func (p *PGServer) GetFollowed(
+	ctx context.Context, request *s.GetFollowedRequest) (*s.GetFollowedResponse, error) {
+
+	ctx = s.WithOperation(ctx, s.OpGetFollowed)
+
+	if request.CallerID == "" {
+		return nil, errors.New("request.CallerID was empty")
+	}
+	callerID := request.CallerID
+
+	limit, err := getPageLimit(request.Limit)
+	if err != nil {
+		return nil, err
+	}
+	cursor, err := decodeCursor(request.Cursor)
+	if err != nil {
+		return nil, err
+	}
+	cursorCreatedAt, cursorUserID := cursor.args()
+
+	innerCtx, cancel := getQueryContext(ctx)
 	defer cancel()
-	err = tx.Commit(innerCtx)
+
+	rows, err := p.DBPool.Query(innerCtx, `
+		/* dbbenchmark:GetFollowed */
+		SELECT u.user_id, u.user_name, u.created_at, u.role, f.created_at
+		FROM follows f
+		JOIN users u ON u.user_id = f.target_id
+		WHERE f.source_id = $1
+			AND ($2::TIMESTAMPTZ IS NULL OR (f.created_at, f.target_id) < ($2, $3::UUID))
+		ORDER BY f.created_at DESC, f.target_id DESC
+		LIMIT $4;
+	`, callerID, cursorCreatedAt, cursorUserID, limit)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, errors.Wrap(err, "querying for followed users failed")
 	}
+	defer rows.Close()
+
+	users := []*s.User{}
+	var lastFollowedAt time.Time
+	for rows.Next() {
+		var userID string
+		var userName string
+		var createdAt time.Time
+		var role s.Role
+		err = rows.Scan(&userID, &userName, &createdAt, &role, &lastFollowedAt)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading followed user failed")
+		}
 
-	return &s.FollowUserResponse{}, nil
+		users = append(users, &s.User{
+			UserID:           userID,
+			UserName:         userName,
+			Role:             role,
+			CreatedAt:        createdAt.UTC(),
+			FollowedByCaller: true,
+		})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading followed users failed")
+	}
+
+	nextCursor := ""
+	if len(users) == limit {
+		nextCursor = encodeCursor(lastFollowedAt, users[len(users)-1].UserID)
+	}
+
+	return &s.GetFollowedResponse{
+		CallerID: callerID,
+		OwnerID:  callerID,
+		User:     users,
+		Limit:    limit,
+		Cursor:   nextCursor,
+	}, nil
 }
 
 func (p *PGServer) CreatePost(
 	ctx context.Context, request *s.CreatePostRequest) (*s.CreatePostResponse, error) {
 
+	ctx = s.WithOperation(ctx, s.OpCreatePost)
+
 	if request.CallerID == "" {
 		return nil, errors.New("request.CallerID was empty")
 	} else if request.Content == "" {
@@ -207,6 +564,7 @@ func (p *PGServer) CreatePost(
 	defer cancel()
 
 	row := p.DBPool.QueryRow(innerCtx, `
+		/* dbbenchmark:CreatePost */
 		INSERT INTO posts (post_id, owner_id, created_at, content)
 		VALUES (gen_random_uuid(), $1, $2, $3)
 		RETURNING post_id, owner_id, created_at, content
@@ -236,6 +594,29 @@ func (p *PGServer) CreatePost(
 func (p *PGServer) GetPost(
 	ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
 
+	ctx = s.WithOperation(ctx, s.OpGetPost)
+
+	if request.CallerID == "" {
+		return nil, errors.New("request.CallerID was empty")
+	} else if request.PostID == "" {
+		return nil, errors.New("request.PostID was empty")
+	}
+
+	post, err := p.getPost(ctx, p.DBPool, s.OpGetPost, request.CallerID, request.PostID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s.GetPostResponse{
+		Post: post,
+	}, nil
+}
+
+func (p *PGServer) LikePost(
+	ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+
+	ctx = s.WithOperation(ctx, s.OpLikePost)
+
 	if request.CallerID == "" {
 		return nil, errors.New("request.CallerID was empty")
 	} else if request.PostID == "" {
@@ -243,11 +624,51 @@ func (p *PGServer) GetPost(
 	}
 	callerID, postID := request.CallerID, request.PostID
 
+	// The like and the read of the updated post share a transaction, so the
+	// returned like count is consistent with the configured isolation level.
+	var post *s.Post
+	err := p.runInTx(ctx, s.OpLikePost, func(tx pgx.Tx) error {
+		innerCtx, cancel := getQueryContext(ctx)
+		defer cancel()
+
+		_, err := tx.Exec(innerCtx, `
+			/* dbbenchmark:LikePost */
+			INSERT INTO likes (post_id, user_id, created_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT DO NOTHING;
+		`, postID, callerID, p.Clock.NowUtc())
+		if err != nil {
+			return errors.Wrap(err, "liking post failed")
+		}
+
+		post, err = p.getPost(ctx, tx, s.OpLikePost, callerID, postID)
+		return errors.Wrap(err, "getting updated post failed")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &s.LikePostResponse{
+		Post: post,
+	}, nil
+}
+
+// querier is implemented by both the pool and transactions.
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+// getPost returns nil if the post does not exist. Its statements are tagged
+// with op, the operation that reads the post, as shared by GetPost and
+// LikePost.
+func (p *PGServer) getPost(ctx context.Context, q querier, op s.Operation, callerID string, postID string) (*s.Post, error) {
+	tag := sharedMethodTag(op, s.OpGetPost, s.OpLikePost)
+
 	// Query for post.
 	innerCtx, cancel := getQueryContext(ctx)
 	defer cancel()
 
-	row := p.DBPool.QueryRow(innerCtx, `
+	row := q.QueryRow(innerCtx, tag+`
 		SELECT owner_id, created_at, content
 		FROM posts
 		WHERE post_id = $1
@@ -258,8 +679,8 @@ func (p *PGServer) GetPost(
 	var createdAt time.Time
 	var content string
 	err := row.Scan(&ownerID, &createdAt, &content)
-	if err == sql.ErrNoRows {
-		return &s.GetPostResponse{}, nil
+	if e.Is(err, pgx.ErrNoRows) {
+		return nil, nil
 	} else if err != nil {
 		return nil, errors.Wrap(err, "querying for post failed")
 	}
@@ -268,7 +689,7 @@ func (p *PGServer) GetPost(
 	innerCtx, cancel = getQueryContext(ctx)
 	defer cancel()
 
-	row = p.DBPool.QueryRow(innerCtx, `
+	row = q.QueryRow(innerCtx, tag+`
 		SELECT COUNT(*)
 		FROM likes
 		WHERE post_id = $1;
@@ -283,7 +704,7 @@ func (p *PGServer) GetPost(
 	innerCtx, cancel = getQueryContext(ctx)
 	defer cancel()
 
-	row = p.DBPool.QueryRow(innerCtx, `
+	row = q.QueryRow(innerCtx, tag+`
 		SELECT EXISTS (
 			SELECT post_id, user_id
 			FROM likes
@@ -296,58 +717,23 @@ func (p *PGServer) GetPost(
 		return nil, errors.Wrap(err, "querying to see if post is liked by caller failed")
 	}
 
-	return &s.GetPostResponse{
-		Post: &s.Post{
-			PostID:        postID,
-			OwnerID:       ownerID,
-			Content:       content,
-			CreatedAt:     createdAt.UTC(),
-			LikeCount:     likeCount,
-			LikedByCaller: likedByCaller,
-		},
-	}, nil
-}
-
-func (p *PGServer) LikePost(
-	ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
-
-	if request.CallerID == "" {
-		return nil, errors.New("request.CallerID was empty")
-	} else if request.PostID == "" {
-		return nil, errors.New("request.PostID was empty")
-	}
-	callerID, postID := request.CallerID, request.PostID
-
-	innerCtx, cancel := getQueryContext(ctx)
-	defer cancel()
-
-	_, err := p.DBPool.Exec(innerCtx, `
-		INSERT INTO likes (post_id, user_id, created_at)
-		VALUES ($1, $2, $3)
-		ON CONFLICT DO NOTHING;
-	`, postID, callerID, p.Clock.NowUtc())
-	if err != nil {
-		return nil, errors.Wrap(err, "liking post failed")
-	}
-
-	getPostResp, err := p.GetPost(ctx, &s.GetPostRequest{
-		CallerID: callerID,
-		PostID:   postID,
-	})
-	if err != nil {
-		return nil, errors.Wrap(err, "getting updated post failed")
-	}
-
-	return &s.LikePostResponse{
-		Post: getPostResp.Post,
+	return &s.Post{
+		PostID:        postID,
+		OwnerID:       ownerID,
+		Content:       content,
+		CreatedAt:     createdAt.UTC(),
+		LikeCount:     likeCount,
+		LikedByCaller: likedByCaller,
 	}, nil
 }
 
-func (p *PGServer) assertUserExist(ctx context.Context, tx pgx.Tx, userID string) error {
+// assertUserExist tags its statement with op, the operation that needs the
+// user.
+func (p *PGServer) assertUserExist(ctx context.Context, tx pgx.Tx, op s.Operation, userID string) error {
 	innerCtx, cancel := getQueryContext(ctx)
 	defer cancel()
 
-	row := tx.QueryRow(innerCtx, `
+	row := tx.QueryRow(innerCtx, methodTag(op)+`
 		SELECT EXISTS (
 			SELECT user_id FROM users WHERE user_id = $1 LIMIT 1
 		);
@@ -363,6 +749,50 @@ func (p *PGServer) assertUserExist(ctx context.Context, tx pgx.Tx, userID string
 	return nil
 }
 
+// scanPosts reads rows of (post_id, owner_id, created_at, content, like_count,
+// liked_by_caller) and closes them.
+func scanPosts(rows pgx.Rows) ([]*s.Post, error) {
+	defer rows.Close()
+
+	posts := []*s.Post{}
+	for rows.Next() {
+		var postID string
+		var ownerID string
+		var createdAt time.Time
+		var content string
+		var likeCount int
+		var likedByCaller bool
+		err := rows.Scan(&postID, &ownerID, &createdAt, &content, &likeCount, &likedByCaller)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+
+		posts = append(posts, &s.Post{
+			PostID:        postID,
+			OwnerID:       ownerID,
+			Content:       content,
+			CreatedAt:     createdAt.UTC(),
+			LikeCount:     likeCount,
+			LikedByCaller: likedByCaller,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return posts, nil
+}
+
+// getNextPostCursor returns the cursor for the page after posts, or "" if posts
+// was the last page.
+func getNextPostCursor(posts []*s.Post, limit int) string {
+	if len(posts) < limit || len(posts) == 0 {
+		return ""
+	}
+	last := posts[len(posts)-1]
+	return encodeCursor(last.CreatedAt, last.PostID)
+}
+
 func getQueryContext(parentCtx context.Context) (context.Context, context.CancelFunc) {
 	return context.WithTimeout(parentCtx, 10*time.Second)
 }
@@ -0,0 +1,125 @@
+package postgres
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+var DefaultPGServerOptions = &PGServerOptions{
+	TraceStatements: true,
+	TxMaxAttempts:   5,
+	TxBackoff: util.Backoff{
+		Initial: 5 * time.Millisecond,
+		Max:     200 * time.Millisecond,
+	},
+}
+
+type PGServerOptions struct {
+	// IsoLevel is the isolation level of write transactions. The server's
+	// default, normally read committed, is used when it is empty.
+	IsoLevel pgx.TxIsoLevel
+	// ExecMode is how queries are sent: with cached prepared statements, with
+	// cached descriptions, or unprepared. pgx's default, cache statement, is
+	// used when it is zero. PgBouncer in transaction mode needs exec or
+	// simple protocol.
+	ExecMode pgx.QueryExecMode
+	// TraceStatements records the latency of every statement in
+	// PGServer.Statements.
+	TraceStatements bool
+	// QueryTracers also see every statement run by the pool, for example to
+	// export spans.
+	QueryTracers []pgx.QueryTracer
+	// TxMaxAttempts is how many times a transaction is run before a retryable
+	// error is returned. It includes the first attempt.
+	TxMaxAttempts int
+	TxBackoff     util.Backoff
+	// MaxConns is the size of the connection pool. pgx's default, the
+	// greater of 4 and the number of CPUs, is used when it is 0.
+	MaxConns int32
+	// FeedStrategy is how followed feeds are queried. FeedJoin is used when
+	// it is empty.
+	FeedStrategy FeedStrategy
+}
+
+// FeedStrategy is a way of querying the followed feed.
+type FeedStrategy string
+
+const (
+	// FeedJoin joins the caller's follows with all their posts, then sorts
+	// and limits the result.
+	FeedJoin FeedStrategy = "join"
+	// FeedLateral takes the latest page of posts of each followed user with
+	// a lateral subquery on the posts index, then merges them. It reads less
+	// when followed users have many posts.
+	FeedLateral FeedStrategy = "lateral"
+)
+
+// ParseFeedStrategy parses a feed strategy such as "join" or "lateral". An
+// empty value returns FeedJoin.
+func ParseFeedStrategy(value string) (FeedStrategy, error) {
+	switch normalizeOption(value) {
+	case "", string(FeedJoin):
+		return FeedJoin, nil
+	case string(FeedLateral):
+		return FeedLateral, nil
+	}
+	return "", errors.Errorf("unknown feed strategy: \"%s\"", value)
+}
+
+// ParseIsoLevel parses an isolation level such as "serializable" or
+// "REPEATABLE READ". Words may be separated by spaces, dashes or underscores.
+func ParseIsoLevel(value string) (pgx.TxIsoLevel, error) {
+	switch normalizeOption(value) {
+	case "":
+		return "", nil
+	case "read uncommitted":
+		return pgx.ReadUncommitted, nil
+	case "read committed":
+		return pgx.ReadCommitted, nil
+	case "repeatable read":
+		return pgx.RepeatableRead, nil
+	case "serializable":
+		return pgx.Serializable, nil
+	}
+	return "", errors.Errorf("unknown isolation level: \"%s\"", value)
+}
+
+// ParseExecMode parses a query exec mode such as "cache_statement" or
+// "simple protocol". An empty value returns zero, which keeps pgx's default.
+func ParseExecMode(value string) (pgx.QueryExecMode, error) {
+	normalized := normalizeOption(value)
+	if normalized == "" {
+		return 0, nil
+	}
+
+	for _, mode := range execModes {
+		if mode.String() == normalized {
+			return mode, nil
+		}
+	}
+	return 0, errors.Errorf("unknown query exec mode: \"%s\"", value)
+}
+
+var execModes = []pgx.QueryExecMode{
+	pgx.QueryExecModeCacheStatement,
+	pgx.QueryExecModeCacheDescribe,
+	pgx.QueryExecModeDescribeExec,
+	pgx.QueryExecModeExec,
+	pgx.QueryExecModeSimpleProtocol,
+}
+
+// execModeName returns mode as it is written in connection strings, e.g.
+// "cache_statement".
+func execModeName(mode pgx.QueryExecMode) string {
+	return strings.ReplaceAll(mode.String(), " ", "_")
+}
+
+func normalizeOption(value string) string {
+	value = strings.NewReplacer("-", " ", "_", " ").Replace(strings.TrimSpace(value))
+	return strings.ToLower(value)
+}
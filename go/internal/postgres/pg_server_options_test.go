@@ -0,0 +1,61 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/jackc/pgx/v5"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIsoLevel(t *testing.T) {
+	cases := map[string]pgx.TxIsoLevel{
+		"":                pgx.TxIsoLevel(""),
+		"read-committed":  pgx.ReadCommitted,
+		"REPEATABLE READ": pgx.RepeatableRead,
+		"serializable":    pgx.Serializable,
+	}
+	for value, expected := range cases {
+		isoLevel, err := p.ParseIsoLevel(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, isoLevel)
+	}
+
+	_, err := p.ParseIsoLevel("snapshot")
+	require.Error(t, err)
+}
+
+func TestParseExecMode(t *testing.T) {
+	cases := map[string]pgx.QueryExecMode{
+		"":                pgx.QueryExecMode(0),
+		"cache_statement": pgx.QueryExecModeCacheStatement,
+		"cache-describe":  pgx.QueryExecModeCacheDescribe,
+		"describe exec":   pgx.QueryExecModeDescribeExec,
+		"exec":            pgx.QueryExecModeExec,
+		"SIMPLE_PROTOCOL": pgx.QueryExecModeSimpleProtocol,
+	}
+	for value, expected := range cases {
+		execMode, err := p.ParseExecMode(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, execMode)
+	}
+
+	_, err := p.ParseExecMode("prepared")
+	require.Error(t, err)
+}
+
+func TestParseFeedStrategy(t *testing.T) {
+	cases := map[string]p.FeedStrategy{
+		"":        p.FeedJoin,
+		"join":    p.FeedJoin,
+		"LATERAL": p.FeedLateral,
+	}
+	for value, expected := range cases {
+		strategy, err := p.ParseFeedStrategy(value)
+		require.NoError(t, err)
+		require.Equal(t, expected, strategy)
+	}
+
+	_, err := p.ParseFeedStrategy("fan-out")
+	require.Error(t, err)
+}
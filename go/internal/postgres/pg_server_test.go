@@ -18,7 +18,7 @@ func newTestEnv(ctx context.Context, t *testing.T) (*p.DBManager, *p.PGServer) {
 	require.NoError(t, err)
 	dbManager.TruncateTables(ctx)
 
-	server, err := p.NewPGServer(ctx, p.DevConnStringOptions)
+	server, err := p.NewPGServer(ctx, p.DevConnStringOptions, p.DefaultPGServerOptions)
 	require.NoError(t, err)
 
 	return dbManager, server
@@ -76,6 +76,15 @@ func TestGetUser(t *testing.T) {
 	require.NoError(t, err)
 	require.NotNil(t, getUserResp)
 	require.Equal(t, createdUser, getUserResp.User)
+
+	// Assert: A missing user is not found rather than an error.
+	getUserResp, err = server.GetUser(ctx, &s.GetUserRequest{
+		CallerID: createdUser.UserID,
+		UserID:   "00000000-0000-0000-0000-000000000000",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, getUserResp)
+	require.Nil(t, getUserResp.User)
 }
 
 func TestFollowUser(t *testing.T) {
@@ -123,6 +132,7 @@ func TestFollowUser(t *testing.T) {
 	})
 	require.NoError(t, err)
 	require.NotNil(t, followUserResp)
+	require.Equal(t, int64(1), server.TxStats()[s.OpFollowUser].Commits)
 
 	// Assert: Verify that the viewer follows the creator.
 	getUserResp, err = server.GetUser(ctx, &s.GetUserRequest{
@@ -229,4 +239,181 @@ func TestPost(t *testing.T) {
 	require.Equal(t, stubClock.NowUtc(), likedPost.CreatedAt)
 	require.Equal(t, 2, likedPost.LikeCount)
 	require.True(t, likedPost.LikedByCaller)
+
+	// Assert: GetPost's three statements were traced under its name.
+	getPostStatements := 0
+	for _, statement := range server.Statements.Snapshot() {
+		if statement.Operation == s.OpGetPost {
+			getPostStatements++
+			require.Equal(t, uint64(1), statement.Latency.Total)
+		}
+	}
+	require.Equal(t, 3, getPostStatements)
+}
+
+func TestFeeds(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	_, server := newTestEnv(ctx, t)
+	defer server.Close()
+	stubClock := util.NewStubClock()
+	server.Clock = stubClock
+
+	// Setup: Create a creator with 3 posts, and a viewer that follows the creator.
+	createUserResp, err := server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     s.RoleViewer,
+	})
+	require.NoError(t, err)
+	viewerID := createUserResp.User.UserID
+
+	createUserResp, err = server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     s.RoleSmallCreator,
+	})
+	require.NoError(t, err)
+	creator := createUserResp.User
+	creatorID := creator.UserID
+
+	posts := []*s.Post{}
+	for i := 0; i < 3; i++ {
+		stubClock.SetNow(stubClock.NowUtc().Add(time.Second))
+		createPostResp, err := server.CreatePost(ctx, &s.CreatePostRequest{
+			CallerID: creatorID,
+			Content:  gofakeit.Sentence(8),
+		})
+		require.NoError(t, err)
+		posts = append(posts, createPostResp.Post)
+	}
+
+	_, err = server.FollowUser(ctx, &s.FollowUserRequest{
+		CallerID:     viewerID,
+		TargetUserID: creatorID,
+	})
+	require.NoError(t, err)
+
+	_, err = server.LikePost(ctx, &s.LikePostRequest{
+		CallerID: viewerID,
+		PostID:   posts[2].PostID,
+	})
+	require.NoError(t, err)
+
+	// Act: The viewer pages through the creator's feed, 2 posts at a time.
+	userFeedResp, err := server.GetUserFeed(ctx, &s.GetUserFeedRequest{
+		CallerID: viewerID,
+		OwnerID:  creatorID,
+		Limit:    2,
+	})
+	require.NoError(t, err)
+	require.Len(t, userFeedResp.Posts, 2)
+	require.Equal(t, posts[2].PostID, userFeedResp.Posts[0].PostID)
+	require.Equal(t, 1, userFeedResp.Posts[0].LikeCount)
+	require.True(t, userFeedResp.Posts[0].LikedByCaller)
+	require.Equal(t, posts[1].PostID, userFeedResp.Posts[1].PostID)
+	require.NotEmpty(t, userFeedResp.Cursor)
+
+	userFeedResp, err = server.GetUserFeed(ctx, &s.GetUserFeedRequest{
+		CallerID: viewerID,
+		OwnerID:  creatorID,
+		Limit:    2,
+		Cursor:   userFeedResp.Cursor,
+	})
+	require.NoError(t, err)
+	require.Len(t, userFeedResp.Posts, 1)
+	require.Equal(t, posts[0], userFeedResp.Posts[0])
+	require.Empty(t, userFeedResp.Cursor)
+
+	// Act: The viewer reads their followed feed.
+	followedFeedResp, err := server.GetFollowedFeed(ctx, &s.GetFollowedFeedRequest{
+		CallerID: viewerID,
+	})
+	require.NoError(t, err)
+	require.Len(t, followedFeedResp.Posts, 3)
+	require.Equal(t, posts[2].PostID, followedFeedResp.Posts[0].PostID)
+	require.Equal(t, posts[0].PostID, followedFeedResp.Posts[2].PostID)
+
+	// Act: The viewer lists the users they follow.
+	followedResp, err := server.GetFollowed(ctx, &s.GetFollowedRequest{
+		CallerID: viewerID,
+	})
+	require.NoError(t, err)
+	require.Len(t, followedResp.User, 1)
+	require.Equal(t, creatorID, followedResp.User[0].UserID)
+	require.True(t, followedResp.User[0].FollowedByCaller)
+}
+
+func TestFeedStrategies(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+	_, server := newTestEnv(ctx, t)
+	defer server.Close()
+	stubClock := util.NewStubClock()
+	server.Clock = stubClock
+
+	// Setup: A viewer follows 2 creators, who post in turns.
+	createUserResp, err := server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     s.RoleViewer,
+	})
+	require.NoError(t, err)
+	viewerID := createUserResp.User.UserID
+
+	creatorIDs := []string{}
+	for i := 0; i < 2; i++ {
+		createUserResp, err = server.CreateUser(ctx, &s.CreateUserRequest{
+			UserName: gofakeit.Username(),
+			Role:     s.RoleSmallCreator,
+		})
+		require.NoError(t, err)
+		creatorIDs = append(creatorIDs, createUserResp.User.UserID)
+
+		_, err = server.FollowUser(ctx, &s.FollowUserRequest{
+			CallerID:     viewerID,
+			TargetUserID: createUserResp.User.UserID,
+		})
+		require.NoError(t, err)
+	}
+
+	expected := []string{}
+	for i := 0; i < 6; i++ {
+		stubClock.SetNow(stubClock.NowUtc().Add(time.Second))
+		createPostResp, err := server.CreatePost(ctx, &s.CreatePostRequest{
+			CallerID: creatorIDs[i%2],
+			Content:  gofakeit.Sentence(8),
+		})
+		require.NoError(t, err)
+		expected = append([]string{createPostResp.Post.PostID}, expected...)
+	}
+
+	for _, strategy := range []p.FeedStrategy{p.FeedJoin, p.FeedLateral} {
+		options := *p.DefaultPGServerOptions
+		options.FeedStrategy = strategy
+		strategyServer, err := p.NewPGServer(ctx, p.DevConnStringOptions, &options)
+		require.NoError(t, err)
+		defer strategyServer.Close()
+
+		// Act: The viewer pages through their followed feed, 4 posts at a
+		// time.
+		actual := []string{}
+		cursor := ""
+		for {
+			resp, err := strategyServer.GetFollowedFeed(ctx, &s.GetFollowedFeedRequest{
+				CallerID: viewerID,
+				Limit:    4,
+				Cursor:   cursor,
+			})
+			require.NoError(t, err)
+			for _, post := range resp.Posts {
+				actual = append(actual, post.PostID)
+			}
+			if resp.Cursor == "" {
+				break
+			}
+			cursor = resp.Cursor
+		}
+
+		// Assert: Every strategy returns the same posts, newest first.
+		require.Equal(t, expected, actual, "strategy=%s", strategy)
+		require.Equal(t, string(strategy), strategyServer.RunMetadata()["pg.feed_strategy"])
+	}
 }
@@ -0,0 +1,181 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+)
+
+// PGStatsSampler samples Postgres' statistics views on its own connection,
+// so that it neither waits for nor shows up in the pool being measured.
+//
+// Cumulative counters, such as buffer hits and reads, scans and autovacuum
+// runs, are returned as how much they grew since the previous sample. Lock
+// waits, backends by wait event and dead tuples are returned as they are.
+type PGStatsSampler struct {
+	conn     *pgx.Conn
+	previous map[string]float64
+}
+
+func NewPGStatsSampler(parentCtx context.Context, connOptions *ConnStringOptions) (*PGStatsSampler, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, connOptions.GetConnString(dbFeed))
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting failed, connString=\"%s\"", connOptions.GetDebugConnString(dbFeed))
+	}
+
+	return &PGStatsSampler{
+		conn: conn,
+	}, nil
+}
+
+func (p *PGStatsSampler) Close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
+
+func (p *PGStatsSampler) SamplerName() string {
+	return "pg"
+}
+
+func (p *PGStatsSampler) Sample(parentCtx context.Context) (map[string]float64, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	counters := map[string]float64{}
+	gauges := map[string]float64{}
+
+	queries := []struct {
+		name   string
+		sql    string
+		values map[string]float64
+	}{
+		{"pg_stat_database", sqlSampleDatabase, counters},
+		{"pg_stat_user_tables", sqlSampleTables, counters},
+		{"pg_stat_user_tables", sqlSampleTableGauges, gauges},
+		{"pg_stat_user_indexes", sqlSampleIndexes, counters},
+		{"pg_locks", sqlSampleLocks, gauges},
+		{"pg_stat_activity", sqlSampleActivity, gauges},
+	}
+	for _, query := range queries {
+		err := p.query(ctx, query.sql, query.values)
+		if err != nil {
+			return nil, errors.Wrapf(err, "sampling %s failed", query.name)
+		}
+	}
+
+	values := gauges
+	if p.previous != nil {
+		for key, value := range counters {
+			values[key] = value - p.previous[key]
+		}
+	}
+	p.previous = counters
+	return values, nil
+}
+
+// query runs sql, which returns (key, value) rows, and stores its rows in
+// values.
+func (p *PGStatsSampler) query(ctx context.Context, sql string, values map[string]float64) error {
+	rows, err := p.conn.Query(ctx, sql)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var value float64
+		err = rows.Scan(&key, &value)
+		if err != nil {
+			return err
+		}
+		values[key] = value
+	}
+	return rows.Err()
+}
+
+const sqlSampleDatabase = `
+	SELECT 'database.' || key, value::float8
+	FROM pg_stat_database,
+		LATERAL (VALUES
+			('xact_commit', xact_commit),
+			('xact_rollback', xact_rollback),
+			('blks_read', blks_read),
+			('blks_hit', blks_hit),
+			('tup_returned', tup_returned),
+			('tup_fetched', tup_fetched),
+			('tup_inserted', tup_inserted),
+			('tup_updated', tup_updated),
+			('tup_deleted', tup_deleted),
+			('conflicts', conflicts),
+			('temp_bytes', temp_bytes),
+			('deadlocks', deadlocks)
+		) AS v(key, value)
+	WHERE datname = current_database();
+`
+
+const sqlSampleTables = `
+	SELECT 'table.' || t.relname || '.' || key, value::float8
+	FROM pg_stat_user_tables t
+		JOIN pg_statio_user_tables io USING (relid),
+		LATERAL (VALUES
+			('seq_scan', t.seq_scan),
+			('seq_tup_read', t.seq_tup_read),
+			('idx_scan', coalesce(t.idx_scan, 0)),
+			('n_tup_ins', t.n_tup_ins),
+			('n_tup_upd', t.n_tup_upd),
+			('n_tup_del', t.n_tup_del),
+			('heap_blks_read', io.heap_blks_read),
+			('heap_blks_hit', io.heap_blks_hit),
+			('autovacuum_count', t.autovacuum_count),
+			('autoanalyze_count', t.autoanalyze_count)
+		) AS v(key, value);
+`
+
+const sqlSampleTableGauges = `
+	SELECT 'table.' || relname || '.n_dead_tup', n_dead_tup::float8
+	FROM pg_stat_user_tables;
+`
+
+const sqlSampleIndexes = `
+	SELECT 'index.' || i.indexrelname || '.' || key, value::float8
+	FROM pg_stat_user_indexes i
+		JOIN pg_statio_user_indexes io USING (indexrelid),
+		LATERAL (VALUES
+			('idx_scan', i.idx_scan),
+			('idx_tup_read', i.idx_tup_read),
+			('idx_blks_read', io.idx_blks_read),
+			('idx_blks_hit', io.idx_blks_hit)
+		) AS v(key, value);
+`
+
+// sqlSampleLocks counts the locks held and waited for across the cluster.
+// Row lock waits are on transaction IDs, which belong to no database.
+const sqlSampleLocks = `
+	SELECT key, value::float8
+	FROM (
+			SELECT
+				count(*) FILTER (WHERE granted) AS granted,
+				count(*) FILTER (WHERE NOT granted) AS waiting
+			FROM pg_locks
+			WHERE pid <> pg_backend_pid()
+		) l,
+		LATERAL (VALUES
+			('locks.granted', l.granted),
+			('locks.waiting', l.waiting)
+		) AS v(key, value);
+`
+
+const sqlSampleActivity = `
+	SELECT 'activity.wait.' || coalesce(wait_event_type || '.' || wait_event, 'CPU'), count(*)::float8
+	FROM pg_stat_activity
+	WHERE datname = current_database() AND state = 'active' AND pid <> pg_backend_pid()
+	GROUP BY 1
+	UNION ALL
+	SELECT 'activity.autovacuum_workers', count(*)::float8
+	FROM pg_stat_activity
+	WHERE backend_type = 'autovacuum worker';
+`
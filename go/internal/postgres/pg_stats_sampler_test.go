@@ -0,0 +1,73 @@
+package postgres_test
+
+import (
+	"testing"
+
+	"github.com/brianvoe/gofakeit/v7"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPGStatsSampler(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	_, server := newTestEnv(ctx, t)
+	defer server.Close()
+
+	sampler, err := p.NewPGStatsSampler(ctx, p.DevConnStringOptions)
+	require.NoError(t, err)
+	defer sampler.Close(ctx)
+
+	baseline, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+	require.Contains(t, baseline, "locks.waiting")
+	require.NotContains(t, baseline, "database.xact_commit")
+
+	// Act:
+	for i := 0; i < 3; i++ {
+		_, err = server.CreateUser(ctx, &s.CreateUserRequest{
+			UserName: gofakeit.Username(),
+			Role:     s.RoleViewer,
+		})
+		require.NoError(t, err)
+	}
+	values, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+
+	// Assert: Counters are reported as deltas since the baseline. Stats may
+	// be flushed late, so only their presence and sign are checked.
+	require.Contains(t, values, "database.xact_commit")
+	require.GreaterOrEqual(t, values["database.xact_commit"], 0.0)
+	require.Contains(t, values, "table.users.n_tup_ins")
+	require.Contains(t, values, "index.users_pkey.idx_scan")
+	require.Contains(t, values, "table.users.n_dead_tup")
+}
+
+func TestPoolSampler(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	_, server := newTestEnv(ctx, t)
+	defer server.Close()
+
+	sampler := p.NewPoolSampler(server.DBPool.Stat)
+	baseline, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+	require.Contains(t, baseline, "pool.conns.total")
+	require.NotContains(t, baseline, "pool.acquires.total")
+
+	// Act:
+	_, err = server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     s.RoleViewer,
+	})
+	require.NoError(t, err)
+	values, err := sampler.Sample(ctx)
+	require.NoError(t, err)
+
+	// Assert:
+	require.GreaterOrEqual(t, values["pool.acquires.total"], 1.0)
+	require.Equal(t, 0.0, values["pool.conns.acquired"])
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// PoolSampler samples the statistics of a connection pool, such as
+// PGServer.DBPool. Acquires and the time spent waiting for them are returned
+// as how much they grew since the previous sample, connections as they are.
+type PoolSampler struct {
+	stat     func() *pgxpool.Stat
+	previous map[string]float64
+}
+
+// NewPoolSampler returns a PoolSampler that reads stat, such as
+// pgxpool.Pool.Stat.
+func NewPoolSampler(stat func() *pgxpool.Stat) *PoolSampler {
+	return &PoolSampler{
+		stat: stat,
+	}
+}
+
+func (p *PoolSampler) SamplerName() string {
+	return "pool"
+}
+
+func (p *PoolSampler) Sample(ctx context.Context) (map[string]float64, error) {
+	stat := p.stat()
+
+	values := map[string]float64{
+		"pool.conns.acquired":     float64(stat.AcquiredConns()),
+		"pool.conns.idle":         float64(stat.IdleConns()),
+		"pool.conns.total":        float64(stat.TotalConns()),
+		"pool.conns.constructing": float64(stat.ConstructingConns()),
+		"pool.conns.max":          float64(stat.MaxConns()),
+	}
+	counters := map[string]float64{
+		"pool.acquires.total":       float64(stat.AcquireCount()),
+		"pool.acquires.empty":       float64(stat.EmptyAcquireCount()),
+		"pool.acquires.canceled":    float64(stat.CanceledAcquireCount()),
+		"pool.acquire_wait_seconds": stat.AcquireDuration().Seconds(),
+	}
+
+	if p.previous != nil {
+		for key, value := range counters {
+			values[key] = value - p.previous[key]
+		}
+	}
+	p.previous = counters
+	return values, nil
+}
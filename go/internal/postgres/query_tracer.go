@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// statementTracer is a pgx.QueryTracer that records the latency, row count
+// and error of every statement, labeled with the operation in its context.
+type statementTracer struct {
+	recorder *stats.StatementRecorder
+}
+
+type statementTraceKey struct{}
+
+type statementTrace struct {
+	start time.Time
+	sql   string
+}
+
+func (t *statementTracer) TraceQueryStart(
+	ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+
+	return context.WithValue(ctx, statementTraceKey{}, &statementTrace{
+		start: time.Now(),
+		sql:   data.SQL,
+	})
+}
+
+func (t *statementTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	trace, ok := ctx.Value(statementTraceKey{}).(*statementTrace)
+	if !ok {
+		return
+	}
+
+	t.recorder.Record(
+		s.OperationFromContext(ctx),
+		statementName(trace.sql),
+		time.Since(trace.start),
+		data.CommandTag.RowsAffected(),
+		data.Err,
+	)
+}
+
+// statementName drops the method tag from sql and collapses its whitespace so
+// that it reads as a single line.
+func statementName(sql string) string {
+	sql = methodTagPattern.ReplaceAllString(sql, "")
+	return strings.TrimSuffix(strings.Join(strings.Fields(sql), " "), ";")
+}
@@ -0,0 +1,66 @@
+package postgres
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// SlowQueryLog is a pgx.QueryTracer that logs statements slower than
+// Threshold at warn level. Only a SampleRate fraction of them is logged, so
+// that an overloaded database does not also flood the log. Add it to
+// PGServerOptions.QueryTracers.
+type SlowQueryLog struct {
+	Logger    *slog.Logger
+	Threshold time.Duration
+	// SampleRate is the fraction of slow statements logged. All of them are
+	// logged when it is zero.
+	SampleRate float64
+}
+
+// Enforce that SlowQueryLog implements pgx.QueryTracer interface.
+var _ pgx.QueryTracer = &SlowQueryLog{}
+
+type slowQueryKey struct{}
+
+type slowQuery struct {
+	start time.Time
+	sql   string
+}
+
+func (l *SlowQueryLog) TraceQueryStart(
+	ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+
+	return context.WithValue(ctx, slowQueryKey{}, &slowQuery{
+		start: time.Now(),
+		sql:   data.SQL,
+	})
+}
+
+func (l *SlowQueryLog) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	query, ok := ctx.Value(slowQueryKey{}).(*slowQuery)
+	if !ok {
+		return
+	}
+	latency := time.Since(query.start)
+	if latency < l.Threshold {
+		return
+	}
+	if l.SampleRate > 0 && rand.Float64() >= l.SampleRate {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String("method", QueryMethod(query.sql)),
+		slog.String("statement", statementName(query.sql)),
+		slog.Duration("latency", latency),
+		slog.Int64("rows", data.CommandTag.RowsAffected()),
+	}
+	if data.Err != nil {
+		attrs = append(attrs, slog.String("error", data.Err.Error()))
+	}
+	l.Logger.LogAttrs(ctx, slog.LevelWarn, "slow query", attrs...)
+}
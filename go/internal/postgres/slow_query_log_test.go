@@ -0,0 +1,38 @@
+package postgres_test
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlowQueryLog(t *testing.T) {
+	var buf bytes.Buffer
+	slowQueryLog := &p.SlowQueryLog{
+		Logger:    slog.New(slog.NewTextHandler(&buf, nil)),
+		Threshold: 10 * time.Millisecond,
+	}
+	sql := "/* dbbenchmark:GetPost */ SELECT owner_id FROM posts WHERE post_id = $1;"
+	run := func(latency time.Duration) {
+		ctx := slowQueryLog.TraceQueryStart(context.Background(), nil, pgx.TraceQueryStartData{SQL: sql})
+		time.Sleep(latency)
+		slowQueryLog.TraceQueryEnd(ctx, nil, pgx.TraceQueryEndData{CommandTag: pgconn.NewCommandTag("SELECT 1")})
+	}
+
+	// Act:
+	run(0)
+	require.Empty(t, buf.String())
+	run(20 * time.Millisecond)
+
+	// Assert:
+	require.Contains(t, buf.String(), "level=WARN msg=\"slow query\" method=GetPost")
+	require.Contains(t, buf.String(), "statement=\"SELECT owner_id FROM posts WHERE post_id = $1\"")
+	require.Contains(t, buf.String(), "rows=1")
+}
@@ -0,0 +1,174 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// methodTagPattern matches the comment that starts every query PGServer runs,
+// such as "/* dbbenchmark:GetPost */", naming the method that runs it.
+// pg_stat_statements keeps comments in its query text, so the tag attributes
+// its rows to methods. Comments do not change query IDs though, so a query
+// run word for word by several methods gets a single row, with the comment
+// first seen. The tags of such queries list every method that runs them, as
+// in "/* dbbenchmark:LikePost shared:GetPost,LikePost */".
+var methodTagPattern = regexp.MustCompile(`/\* dbbenchmark:(\w+)(?: shared:([\w,]+))? \*/`)
+
+// methodTag returns the comment that tags the statements run for op.
+func methodTag(op s.Operation) string {
+	return "/* dbbenchmark:" + string(op) + " */"
+}
+
+// sharedMethodTag returns the comment that tags the statements run for op by
+// helpers that every method in shared calls.
+func sharedMethodTag(op s.Operation, shared ...s.Operation) string {
+	names := make([]string, len(shared))
+	for i, sharedOp := range shared {
+		names[i] = string(sharedOp)
+	}
+	return "/* dbbenchmark:" + string(op) + " shared:" + strings.Join(names, ",") + " */"
+}
+
+// QueryMethod returns the method sql is tagged with, or "".
+func QueryMethod(sql string) string {
+	match := methodTagPattern.FindStringSubmatch(sql)
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// statementMethods returns the methods that may have run sql, comma-separated:
+// the method it is tagged with, or every method sharing it.
+func statementMethods(sql string) string {
+	match := methodTagPattern.FindStringSubmatch(sql)
+	if match == nil {
+		return ""
+	} else if match[2] != "" {
+		return match[2]
+	}
+	return match[1]
+}
+
+// StatStatements reads pg_stat_statements for the feed database on its own
+// connection. It needs Postgres 13 or later with the extension loaded and
+// created.
+type StatStatements struct {
+	conn *pgx.Conn
+}
+
+func NewStatStatements(parentCtx context.Context, connOptions *ConnStringOptions) (*StatStatements, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	conn, err := pgx.Connect(ctx, connOptions.GetConnString(dbFeed))
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting failed, connString=\"%s\"", connOptions.GetDebugConnString(dbFeed))
+	}
+
+	return &StatStatements{
+		conn: conn,
+	}, nil
+}
+
+func (p *StatStatements) Close(ctx context.Context) error {
+	return p.conn.Close(ctx)
+}
+
+// Available reports whether pg_stat_statements was created in the feed
+// database.
+func (p *StatStatements) Available(parentCtx context.Context) (bool, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	available := false
+	row := p.conn.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT extname
+			FROM pg_extension
+			WHERE extname = 'pg_stat_statements'
+		);
+	`)
+	err := row.Scan(&available)
+	if err != nil {
+		return false, errors.Wrap(err, "checking for pg_stat_statements failed")
+	}
+	return available, nil
+}
+
+// ResetQueryStats clears pg_stat_statements. It needs superuser or a grant
+// on pg_stat_statements_reset.
+func (p *StatStatements) ResetQueryStats(parentCtx context.Context) error {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	_, err := p.conn.Exec(ctx, "SELECT pg_stat_statements_reset();")
+	return errors.Wrap(err, "resetting pg_stat_statements failed")
+}
+
+// QueryStats returns the statistics of every query pg_stat_statements kept
+// for the feed database, one per query ID. Queries that several methods run
+// list them all in Method. pg_stat_statements keeps a row
+// per user a query ran as, and in Postgres 14 and later per whether it ran
+// at top level or nested in a function; those rows are summed, with the
+// largest MaxTime, so that before and after snapshots diff by query ID.
+func (p *StatStatements) QueryStats(parentCtx context.Context) ([]*stats.QueryStats, error) {
+	ctx, cancel := getQueryContext(parentCtx)
+	defer cancel()
+
+	rows, err := p.conn.Query(ctx, `
+		SELECT
+			queryid, min(query), sum(calls)::BIGINT, sum(total_exec_time), max(max_exec_time),
+			sum(rows)::BIGINT, sum(shared_blks_hit)::BIGINT, sum(shared_blks_read)::BIGINT
+		FROM pg_stat_statements
+		WHERE dbid = (SELECT oid FROM pg_database WHERE datname = current_database())
+			AND queryid IS NOT NULL
+		GROUP BY queryid;
+	`)
+	if err != nil {
+		return nil, errors.Wrap(err, "querying pg_stat_statements failed")
+	}
+	defer rows.Close()
+
+	queries := []*stats.QueryStats{}
+	for rows.Next() {
+		var queryStats stats.QueryStats
+		var totalTime float64
+		var maxTime float64
+		err = rows.Scan(
+			&queryStats.QueryID,
+			&queryStats.Query,
+			&queryStats.Calls,
+			&totalTime,
+			&maxTime,
+			&queryStats.Rows,
+			&queryStats.SharedBlksHit,
+			&queryStats.SharedBlksRead,
+		)
+		if err != nil {
+			return nil, errors.Wrap(err, "scanning pg_stat_statements failed")
+		}
+		queryStats.Method = statementMethods(queryStats.Query)
+		queryStats.Query = statementName(queryStats.Query)
+		queryStats.TotalTime = millisecondsToDuration(totalTime)
+		queryStats.MaxTime = millisecondsToDuration(maxTime)
+		queries = append(queries, &queryStats)
+	}
+	if rows.Err() != nil {
+		return nil, errors.Wrap(rows.Err(), "reading pg_stat_statements failed")
+	}
+
+	return queries, nil
+}
+
+func millisecondsToDuration(milliseconds float64) time.Duration {
+	return time.Duration(milliseconds * float64(time.Millisecond))
+}
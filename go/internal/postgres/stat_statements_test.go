@@ -0,0 +1,84 @@
+package postgres_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v7"
+	p "github.com/jlym/dbbenchmark/go/internal/postgres"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryMethod(t *testing.T) {
+	require.Equal(t, "GetPost", p.QueryMethod(`
+		/* dbbenchmark:GetPost */
+		SELECT owner_id FROM posts WHERE post_id = $1;
+	`))
+	require.Equal(t, "", p.QueryMethod("SELECT 1;"))
+	require.Equal(t, "LikePost", p.QueryMethod(`
+		/* dbbenchmark:LikePost shared:GetPost,LikePost */
+		SELECT COUNT(*) FROM likes WHERE post_id = $1;
+	`))
+}
+
+func TestStatStatements(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	_, server := newTestEnv(ctx, t)
+	defer server.Close()
+
+	statStatements, err := p.NewStatStatements(ctx, p.DevConnStringOptions)
+	require.NoError(t, err)
+	defer statStatements.Close(ctx)
+	available, err := statStatements.Available(ctx)
+	require.NoError(t, err)
+	if !available {
+		t.Skip("pg_stat_statements is not available")
+	}
+
+	// Setup: Create a post, then take the snapshot the delta starts from.
+	createUserResp, err := server.CreateUser(ctx, &s.CreateUserRequest{
+		UserName: gofakeit.Username(),
+		Role:     s.RoleSmallCreator,
+	})
+	require.NoError(t, err)
+	callerID := createUserResp.User.UserID
+	createPostResp, err := server.CreatePost(ctx, &s.CreatePostRequest{CallerID: callerID, Content: "content"})
+	require.NoError(t, err)
+	postID := createPostResp.Post.PostID
+
+	_ = statStatements.ResetQueryStats(ctx)
+	before, err := statStatements.QueryStats(ctx)
+	require.NoError(t, err)
+
+	// Act:
+	for i := 0; i < 3; i++ {
+		_, err = server.GetPost(ctx, &s.GetPostRequest{CallerID: callerID, PostID: postID})
+		require.NoError(t, err)
+	}
+	after, err := statStatements.QueryStats(ctx)
+	require.NoError(t, err)
+
+	// Assert: The delta holds the calls since the snapshot, tagged with the
+	// operations that share the query, one row per query.
+	ids := map[int64]bool{}
+	for _, queryStats := range after {
+		require.False(t, ids[queryStats.QueryID], "query %d has more than one row", queryStats.QueryID)
+		ids[queryStats.QueryID] = true
+	}
+
+	var found *stats.QueryStats
+	for _, queryStats := range stats.DiffQueryStats(before, after) {
+		if queryStats.Query == "SELECT owner_id, created_at, content FROM posts WHERE post_id = $1 LIMIT 1" {
+			found = queryStats
+		}
+	}
+	require.NotNil(t, found)
+	require.Equal(t, "GetPost,LikePost", found.Method)
+	require.Equal(t, int64(3), found.Calls)
+	require.Equal(t, int64(3), found.Rows)
+	require.Greater(t, found.TotalTime, time.Duration(0))
+}
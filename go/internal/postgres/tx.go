@@ -0,0 +1,151 @@
+package postgres
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+// TxStats counts the transactions run for one operation.
+type TxStats struct {
+	// Commits counts transactions that committed, whether or not they were
+	// retried first.
+	Commits int64
+	// Retries counts failed attempts that were retried, by error class. These
+	// measure contention rather than failures.
+	Retries map[ErrorClass]int64
+	// Failures counts transactions that failed for good, by the error class of
+	// their last attempt.
+	Failures map[ErrorClass]int64
+}
+
+func newTxStats() *TxStats {
+	return &TxStats{
+		Retries:  map[ErrorClass]int64{},
+		Failures: map[ErrorClass]int64{},
+	}
+}
+
+func (t *TxStats) clone() *TxStats {
+	clone := newTxStats()
+	clone.Commits = t.Commits
+	for class, count := range t.Retries {
+		clone.Retries[class] = count
+	}
+	for class, count := range t.Failures {
+		clone.Failures[class] = count
+	}
+	return clone
+}
+
+type txStatsRecorder struct {
+	lock       sync.Mutex
+	operations map[s.Operation]*TxStats
+}
+
+func newTxStatsRecorder() *txStatsRecorder {
+	return &txStatsRecorder{
+		operations: map[s.Operation]*TxStats{},
+	}
+}
+
+func (r *txStatsRecorder) record(op s.Operation, class ErrorClass, retried bool) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	txStats, ok := r.operations[op]
+	if !ok {
+		txStats = newTxStats()
+		r.operations[op] = txStats
+	}
+
+	if class == ErrorClassNone {
+		txStats.Commits++
+	} else if retried {
+		txStats.Retries[class]++
+	} else {
+		txStats.Failures[class]++
+	}
+}
+
+func (r *txStatsRecorder) snapshot() map[s.Operation]*TxStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	snapshot := map[s.Operation]*TxStats{}
+	for op, txStats := range r.operations {
+		snapshot[op] = txStats.clone()
+	}
+	return snapshot
+}
+
+// TxStats returns the transaction counts of each operation so far.
+func (p *PGServer) TxStats() map[s.Operation]*TxStats {
+	return p.txStats.snapshot()
+}
+
+// RunCounters flattens TxStats into counters named
+// "tx.<operation>.commits", "tx.<operation>.retries.<error class>" and
+// "tx.<operation>.failures.<error class>", for run results.
+func (p *PGServer) RunCounters() map[string]int64 {
+	counters := map[string]int64{}
+	for op, txStats := range p.TxStats() {
+		prefix := "tx." + string(op) + "."
+		counters[prefix+"commits"] = txStats.Commits
+		for class, count := range txStats.Retries {
+			counters[prefix+"retries."+string(class)] = count
+		}
+		for class, count := range txStats.Failures {
+			counters[prefix+"failures."+string(class)] = count
+		}
+	}
+	return counters
+}
+
+// runInTx runs fn in a transaction. When an attempt fails with a retryable
+// error, the whole transaction is run again after a jittered backoff, up to
+// TxMaxAttempts times.
+func (p *PGServer) runInTx(ctx context.Context, op s.Operation, fn func(tx pgx.Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		err := p.runInTxOnce(ctx, fn)
+		class := ClassifyError(err)
+		retry := class.Retryable() && attempt < p.Options.TxMaxAttempts
+		p.txStats.record(op, class, retry)
+		if err == nil || !retry {
+			return err
+		}
+
+		sleepErr := util.Sleep(ctx, p.Options.TxBackoff.Delay(attempt))
+		if sleepErr != nil {
+			return err
+		}
+	}
+}
+
+func (p *PGServer) runInTxOnce(ctx context.Context, fn func(tx pgx.Tx) error) error {
+	innerCtx, cancel := getQueryContext(ctx)
+	defer cancel()
+	tx, err := p.DBPool.BeginTx(innerCtx, pgx.TxOptions{IsoLevel: p.Options.IsoLevel})
+	if err != nil {
+		return errors.Wrap(err, "starting transaction failed")
+	}
+
+	err = fn(tx)
+	if err != nil {
+		return p.rollbackDueToError(ctx, tx, err)
+	}
+
+	innerCtx, cancel = getQueryContext(ctx)
+	defer cancel()
+	err = tx.Commit(innerCtx)
+	if err != nil {
+		return errors.Wrap(err, "committing transaction failed")
+	}
+
+	return nil
+}
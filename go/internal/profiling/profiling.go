@@ -0,0 +1,131 @@
+// Package profiling captures Go runtime profiles and an execution trace of
+// the current process for a window of time.
+package profiling
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config describes when to profile, relative to the start of a workload.
+type Config struct {
+	// Dir is where each run's profiles are written, in a directory named
+	// after the run. Profiling is disabled when it is empty.
+	Dir string
+	// Delay skips the start of the workload, such as connection setup and
+	// cold caches.
+	Delay  time.Duration
+	Window time.Duration
+}
+
+// Rates used while the window is open. A mutex fraction of 10 samples one
+// contention event in 10, and a block rate of 10µs samples one blocking event
+// per 10µs spent blocked.
+const (
+	mutexProfileFraction = 10
+	blockProfileRate     = int(10 * time.Microsecond)
+)
+
+// Capture waits for config.Delay, then profiles for config.Window or until
+// ctx is done, and writes to dir:
+//
+//   - cpu.pprof and trace.out, covering the window;
+//   - mutex.pprof and block.pprof, sampled during the window only;
+//   - heap.pprof and allocs.pprof, as of the end of the window.
+//
+// It returns the paths of the files it wrote, which is none when ctx is done
+// before the window opens.
+func Capture(ctx context.Context, config *Config, dir string) ([]string, error) {
+	select {
+	case <-time.After(config.Delay):
+	case <-ctx.Done():
+		return nil, nil
+	}
+
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating profile directory failed, path=\"%s\"", dir)
+	}
+
+	paths := []string{}
+	cpuFile, err := create(dir, "cpu.pprof", &paths)
+	if err != nil {
+		return nil, err
+	}
+	defer cpuFile.Close()
+	traceFile, err := create(dir, "trace.out", &paths)
+	if err != nil {
+		return nil, err
+	}
+	defer traceFile.Close()
+
+	err = pprof.StartCPUProfile(cpuFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "starting CPU profile failed")
+	}
+	err = trace.Start(traceFile)
+	if err != nil {
+		pprof.StopCPUProfile()
+		return nil, errors.Wrap(err, "starting execution trace failed")
+	}
+	previousMutexFraction := runtime.SetMutexProfileFraction(mutexProfileFraction)
+	runtime.SetBlockProfileRate(blockProfileRate)
+
+	select {
+	case <-time.After(config.Window):
+	case <-ctx.Done():
+	}
+
+	trace.Stop()
+	pprof.StopCPUProfile()
+	runtime.SetMutexProfileFraction(previousMutexFraction)
+	runtime.SetBlockProfileRate(0)
+
+	err = cpuFile.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "closing CPU profile failed")
+	}
+	err = traceFile.Close()
+	if err != nil {
+		return nil, errors.Wrap(err, "closing execution trace failed")
+	}
+
+	for _, name := range []string{"mutex", "block", "heap", "allocs"} {
+		err = writeProfile(dir, name, &paths)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return paths, nil
+}
+
+func writeProfile(dir string, name string, paths *[]string) error {
+	file, err := create(dir, name+".pprof", paths)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	err = pprof.Lookup(name).WriteTo(file, 0)
+	if err != nil {
+		return errors.Wrapf(err, "writing %s profile failed", name)
+	}
+	return errors.Wrapf(file.Close(), "closing %s profile failed", name)
+}
+
+func create(dir string, name string, paths *[]string) (*os.File, error) {
+	path := filepath.Join(dir, name)
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating profile failed, path=\"%s\"", path)
+	}
+	*paths = append(*paths, path)
+	return file, nil
+}
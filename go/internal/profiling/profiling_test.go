@@ -0,0 +1,44 @@
+package profiling_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/profiling"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	dir := filepath.Join(t.TempDir(), "run")
+
+	// Act:
+	paths, err := profiling.Capture(ctx, &profiling.Config{Window: 100 * time.Millisecond}, dir)
+	require.NoError(t, err)
+
+	// Assert:
+	names := []string{}
+	for _, path := range paths {
+		require.Equal(t, dir, filepath.Dir(path))
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		require.Greater(t, info.Size(), int64(0), "path=%s", path)
+		names = append(names, filepath.Base(path))
+	}
+	require.ElementsMatch(t, []string{
+		"cpu.pprof", "trace.out", "mutex.pprof", "block.pprof", "heap.pprof", "allocs.pprof",
+	}, names)
+}
+
+func TestCaptureCancelledBeforeWindow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	paths, err := profiling.Capture(ctx, &profiling.Config{Delay: time.Minute, Window: time.Minute}, t.TempDir())
+	require.NoError(t, err)
+	require.Empty(t, paths)
+}
@@ -0,0 +1,217 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	chartWidth   = 720
+	chartHeight  = 280
+	marginLeft   = 64
+	marginRight  = 16
+	marginTop    = 12
+	marginBottom = 40
+	// maxSeries keeps charts readable. Charts of many series, such as the
+	// scans of every table, keep the ones with the highest peaks.
+	maxSeries = 8
+)
+
+// palette is a set of colors that stay distinguishable for most kinds of
+// color blindness.
+var palette = []string{
+	"#4477aa", "#ee6677", "#228833", "#ccbb44", "#66ccee", "#aa3377", "#bbbbbb", "#000000",
+}
+
+type point struct {
+	X float64
+	Y float64
+}
+
+type series struct {
+	Name   string
+	Points []point
+}
+
+func (s *series) peak() float64 {
+	peak := math.Inf(-1)
+	for _, p := range s.Points {
+		peak = math.Max(peak, p.Y)
+	}
+	return peak
+}
+
+// tick is a labelled value on an axis.
+type tick struct {
+	Value float64
+	Label string
+}
+
+// chart describes a line chart, which chartView lays out for the template.
+type chart struct {
+	Title  string
+	XLabel string
+	YLabel string
+	// XTicks replaces the evenly spaced ticks of the x axis, e.g. to label
+	// percentiles.
+	XTicks []tick
+	Series []*series
+}
+
+// chartView is a chart laid out in SVG coordinates.
+type chartView struct {
+	Title   string
+	XLabel  string
+	YLabel  string
+	Width   int
+	Height  int
+	Left    int
+	Right   int
+	Top     int
+	Bottom  int
+	XTicks  []tickView
+	YTicks  []tickView
+	Lines   []lineView
+	Omitted int
+}
+
+type tickView struct {
+	Position float64
+	Label    string
+}
+
+type lineView struct {
+	Name   string
+	Color  string
+	Points string
+}
+
+// view lays c out. It keeps the maxSeries series with the highest peaks.
+func (c *chart) view() *chartView {
+	view := &chartView{
+		Title:  c.Title,
+		XLabel: c.XLabel,
+		YLabel: c.YLabel,
+		Width:  chartWidth,
+		Height: chartHeight,
+		Left:   marginLeft,
+		Right:  chartWidth - marginRight,
+		Top:    marginTop,
+		Bottom: chartHeight - marginBottom,
+	}
+
+	shown := c.Series
+	if len(shown) > maxSeries {
+		shown = append([]*series{}, shown...)
+		sort.SliceStable(shown, func(i, j int) bool {
+			return shown[i].peak() > shown[j].peak()
+		})
+		view.Omitted = len(shown) - maxSeries
+		shown = shown[:maxSeries]
+	}
+
+	// Axes start at 0, e.g. at the start of the run.
+	minX, maxX := 0.0, math.Inf(-1)
+	minY, maxY := 0.0, math.Inf(-1)
+	for _, s := range shown {
+		for _, p := range s.Points {
+			minX, maxX = math.Min(minX, p.X), math.Max(maxX, p.X)
+			minY, maxY = math.Min(minY, p.Y), math.Max(maxY, p.Y)
+		}
+	}
+	if math.IsInf(maxX, -1) {
+		maxX, maxY = 1, 1
+	}
+
+	xTicks := c.XTicks
+	if xTicks == nil {
+		for _, value := range niceTicks(minX, maxX) {
+			xTicks = append(xTicks, tick{Value: value, Label: formatValue(value)})
+		}
+		minX, maxX = xTicks[0].Value, xTicks[len(xTicks)-1].Value
+	} else {
+		minX, maxX = math.Min(minX, xTicks[0].Value), math.Max(maxX, xTicks[len(xTicks)-1].Value)
+	}
+	yValues := niceTicks(minY, maxY)
+	minY, maxY = yValues[0], yValues[len(yValues)-1]
+
+	x := scale(minX, maxX, float64(view.Left), float64(view.Right))
+	y := scale(minY, maxY, float64(view.Bottom), float64(view.Top))
+	for _, t := range xTicks {
+		view.XTicks = append(view.XTicks, tickView{Position: x(t.Value), Label: t.Label})
+	}
+	for _, value := range yValues {
+		view.YTicks = append(view.YTicks, tickView{Position: y(value), Label: formatValue(value)})
+	}
+
+	for i, s := range shown {
+		points := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			points[j] = fmt.Sprintf("%.1f,%.1f", x(p.X), y(p.Y))
+		}
+		view.Lines = append(view.Lines, lineView{
+			Name:   s.Name,
+			Color:  palette[i%len(palette)],
+			Points: strings.Join(points, " "),
+		})
+	}
+	return view
+}
+
+// scale maps [min, max] onto [from, to].
+func scale(min float64, max float64, from float64, to float64) func(float64) float64 {
+	return func(value float64) float64 {
+		if max == min {
+			return from
+		}
+		return math.Round((from+(value-min)/(max-min)*(to-from))*10) / 10
+	}
+}
+
+// niceTicks returns about five evenly spaced round values that cover
+// [min, max].
+func niceTicks(min float64, max float64) []float64 {
+	if max <= min {
+		max = min + 1
+	}
+	step := niceStep((max - min) / 5)
+	start := math.Floor(min/step) * step
+	end := math.Ceil(max/step) * step
+
+	ticks := []float64{}
+	for i := 0; start+float64(i)*step <= end+step/2; i++ {
+		ticks = append(ticks, start+float64(i)*step)
+	}
+	return ticks
+}
+
+// niceStep rounds step up to 1, 2 or 5 times a power of ten.
+func niceStep(step float64) float64 {
+	magnitude := math.Pow(10, math.Floor(math.Log10(step)))
+	switch fraction := step / magnitude; {
+	case fraction <= 1:
+		return magnitude
+	case fraction <= 2:
+		return 2 * magnitude
+	case fraction <= 5:
+		return 5 * magnitude
+	}
+	return 10 * magnitude
+}
+
+// formatValue formats value compactly, e.g. 1.5k for 1500.
+func formatValue(value float64) string {
+	abs := math.Abs(value)
+	switch {
+	case abs >= 1e9:
+		return strconv.FormatFloat(value/1e9, 'g', 3, 64) + "G"
+	case abs >= 1e6:
+		return strconv.FormatFloat(value/1e6, 'g', 3, 64) + "M"
+	case abs >= 1e3:
+		return strconv.FormatFloat(value/1e3, 'g', 3, 64) + "k"
+	}
+	return strconv.FormatFloat(value, 'g', 3, 64)
+}
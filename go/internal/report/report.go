@@ -0,0 +1,491 @@
+// Package report renders results as single HTML files. Charts are drawn as
+// inline SVG and styles are embedded, so the files open offline and can be
+// attached to reviews as they are.
+package report
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/results"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+//go:embed report.html.tmpl
+var templateText string
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"duration": formatDuration,
+	"change":   formatChange,
+	"rate":     func(value float64) string { return fmt.Sprintf("%.1f", value) },
+}).Parse(templateText))
+
+// page is what the template renders.
+type page struct {
+	Title       string
+	GeneratedAt time.Time
+	Runs        []*driver.Result
+	// Labels name the runs in the header of the runs table, when set.
+	Labels     []string
+	Metadata   []metadataRow
+	Operations []operationRow
+	Phases     []phaseRow
+	Comparison *results.Comparison
+	Matrix     []matrixRow
+	Sections   []*section
+}
+
+type metadataRow struct {
+	Key    string
+	Values []string
+}
+
+type operationRow struct {
+	Operation s.Operation
+	Result    *driver.OperationResult
+}
+
+// phaseRow sums up the calls of a phase.
+type phaseRow struct {
+	Phase *driver.PhaseResult
+	Total *driver.OperationResult
+}
+
+// matrixRow is an operation in a cell of a matrix. Best marks the cell with
+// the lowest p99 of the operation.
+type matrixRow struct {
+	Operation s.Operation
+	Cell      string
+	Result    *driver.OperationResult
+	Best      bool
+}
+
+type section struct {
+	Title  string
+	Charts []*chartView
+}
+
+// WriteRun writes a report of result.
+func WriteRun(w io.Writer, result *driver.Result) error {
+	p := &page{
+		Title:       "Run " + result.RunID,
+		GeneratedAt: time.Now().UTC(),
+		Runs:        []*driver.Result{result},
+		Metadata:    metadataRows(result),
+		Operations:  operationRows(result),
+	}
+	if len(result.Phases) > 1 {
+		for _, phase := range result.Phases {
+			p.Phases = append(p.Phases, phaseRow{Phase: phase, Total: phase.Total()})
+		}
+	}
+
+	ops := operations(result)
+	workload := &section{Title: "Workload"}
+	workload.add(&chart{
+		Title:  "Throughput",
+		XLabel: "seconds",
+		YLabel: "calls/s",
+		Series: append(
+			[]*series{intervalSeries("all", result, func(i *driver.Interval) float64 { return i.Throughput("") })},
+			perOperation(ops, func(op s.Operation) *series {
+				return intervalSeries(string(op), result, func(i *driver.Interval) float64 { return i.Throughput(op) })
+			})...),
+	})
+	workload.add(&chart{
+		Title:  "Errors",
+		XLabel: "seconds",
+		YLabel: "errors/s",
+		Series: perOperation(ops, func(op s.Operation) *series {
+			return intervalSeries(string(op), result, func(i *driver.Interval) float64 { return i.ErrorRate(op) })
+		}),
+	})
+	workload.add(percentileChart("Latency by percentile", perOperation(ops, func(op s.Operation) *series {
+		return percentileSeries(string(op), result.Operations[op].Latency)
+	})))
+	for _, quantile := range []struct {
+		name  string
+		value func(*driver.IntervalOperation) time.Duration
+	}{
+		{"p50", func(o *driver.IntervalOperation) time.Duration { return o.P50 }},
+		{"p99", func(o *driver.IntervalOperation) time.Duration { return o.P99 }},
+	} {
+		workload.add(&chart{
+			Title:  quantile.name + " latency",
+			XLabel: "seconds",
+			YLabel: "ms",
+			Series: perOperation(ops, func(op s.Operation) *series {
+				return intervalLatencySeries(string(op), result, op, quantile.value)
+			}),
+		})
+	}
+	p.Sections = append(p.Sections, workload)
+	p.Sections = append(p.Sections, samplerSections(result)...)
+
+	return render(w, p)
+}
+
+// WriteComparison writes a report of comparison, which compared a with b.
+func WriteComparison(w io.Writer, comparison *results.Comparison, a *driver.Result, b *driver.Result) error {
+	p := &page{
+		Title:       fmt.Sprintf("Run %s vs %s", a.RunID, b.RunID),
+		GeneratedAt: time.Now().UTC(),
+		Runs:        []*driver.Result{a, b},
+		Labels:      []string{"A", "B"},
+		Metadata:    metadataRows(a, b),
+		Comparison:  comparison,
+	}
+
+	workload := &section{Title: "Workload"}
+	workload.add(&chart{
+		Title:  "Throughput",
+		XLabel: "seconds",
+		YLabel: "calls/s",
+		Series: []*series{
+			intervalSeries("A", a, func(i *driver.Interval) float64 { return i.Throughput("") }),
+			intervalSeries("B", b, func(i *driver.Interval) float64 { return i.Throughput("") }),
+		},
+	})
+	workload.add(&chart{
+		Title:  "Errors",
+		XLabel: "seconds",
+		YLabel: "errors/s",
+		Series: []*series{
+			intervalSeries("A", a, func(i *driver.Interval) float64 { return i.ErrorRate("") }),
+			intervalSeries("B", b, func(i *driver.Interval) float64 { return i.ErrorRate("") }),
+		},
+	})
+	p.Sections = append(p.Sections, workload)
+
+	latency := &section{Title: "Latency by percentile"}
+	for _, opComparison := range comparison.Operations {
+		op := opComparison.Operation
+		var curves []*series
+		if opResult, ok := a.Operations[op]; ok {
+			curves = append(curves, percentileSeries("A", opResult.Latency))
+		}
+		if opResult, ok := b.Operations[op]; ok {
+			curves = append(curves, percentileSeries("B", opResult.Latency))
+		}
+		latency.add(percentileChart(string(op), curves))
+	}
+	p.Sections = append(p.Sections, latency)
+
+	return render(w, p)
+}
+
+// WriteMatrix writes a report comparing the runs of the cells of a matrix.
+// labels name the cells, in the order of all.
+func WriteMatrix(w io.Writer, title string, labels []string, all []*driver.Result) error {
+	if len(labels) != len(all) {
+		return errors.Errorf("matrix needs a label per run, had %d labels and %d runs", len(labels), len(all))
+	}
+	p := &page{
+		Title:       title,
+		GeneratedAt: time.Now().UTC(),
+		Runs:        all,
+		Labels:      labels,
+		Metadata:    metadataRows(all...),
+		Matrix:      matrixRows(labels, all),
+	}
+
+	workload := &section{Title: "Workload"}
+	workload.add(&chart{
+		Title:  "Throughput",
+		XLabel: "seconds",
+		YLabel: "calls/s",
+		Series: perRun(labels, all, func(label string, result *driver.Result) *series {
+			return intervalSeries(label, result, func(i *driver.Interval) float64 { return i.Throughput("") })
+		}),
+	})
+	workload.add(&chart{
+		Title:  "Errors",
+		XLabel: "seconds",
+		YLabel: "errors/s",
+		Series: perRun(labels, all, func(label string, result *driver.Result) *series {
+			return intervalSeries(label, result, func(i *driver.Interval) float64 { return i.ErrorRate("") })
+		}),
+	})
+	p.Sections = append(p.Sections, workload)
+
+	latency := &section{Title: "Latency by percentile"}
+	for _, op := range s.Operations {
+		var curves []*series
+		for i, result := range all {
+			if opResult, ok := result.Operations[op]; ok {
+				curves = append(curves, percentileSeries(labels[i], opResult.Latency))
+			}
+		}
+		if len(curves) > 0 {
+			latency.add(percentileChart(string(op), curves))
+		}
+	}
+	p.Sections = append(p.Sections, latency)
+
+	return render(w, p)
+}
+
+func perRun(labels []string, all []*driver.Result, newSeries func(string, *driver.Result) *series) []*series {
+	lines := make([]*series, len(all))
+	for i, result := range all {
+		lines[i] = newSeries(labels[i], result)
+	}
+	return lines
+}
+
+// matrixRows returns a row per operation and cell, grouped by operation.
+func matrixRows(labels []string, all []*driver.Result) []matrixRow {
+	rows := []matrixRow{}
+	for _, op := range s.Operations {
+		first := len(rows)
+		best := -1
+		for i, result := range all {
+			opResult, ok := result.Operations[op]
+			if !ok {
+				continue
+			}
+			rows = append(rows, matrixRow{Operation: op, Cell: labels[i], Result: opResult})
+			if opResult.Count > 0 && (best < 0 || opResult.P99 < rows[best].Result.P99) {
+				best = len(rows) - 1
+			}
+		}
+		if best >= 0 && len(rows)-first > 1 {
+			rows[best].Best = true
+		}
+	}
+	return rows
+}
+
+func render(w io.Writer, p *page) error {
+	err := reportTemplate.Execute(w, p)
+	if err != nil {
+		return errors.Wrap(err, "rendering report failed")
+	}
+	return nil
+}
+
+func (s *section) add(c *chart) {
+	s.Charts = append(s.Charts, c.view())
+}
+
+// operations returns the operations called in result in the order of
+// s.Operations.
+func operations(result *driver.Result) []s.Operation {
+	ops := []s.Operation{}
+	for _, op := range s.Operations {
+		if _, ok := result.Operations[op]; ok {
+			ops = append(ops, op)
+		}
+	}
+	return ops
+}
+
+func perOperation(ops []s.Operation, newSeries func(s.Operation) *series) []*series {
+	all := make([]*series, len(ops))
+	for i, op := range ops {
+		all[i] = newSeries(op)
+	}
+	return all
+}
+
+// intervalSeries plots value for every interval of result against the
+// seconds since the start of the run.
+func intervalSeries(name string, result *driver.Result, value func(*driver.Interval) float64) *series {
+	line := &series{Name: name}
+	for _, interval := range result.Intervals {
+		line.Points = append(line.Points, point{
+			X: interval.End.Sub(result.StartedAt).Seconds(),
+			Y: value(interval),
+		})
+	}
+	return line
+}
+
+// intervalLatencySeries plots a latency of op in milliseconds, leaving out
+// the intervals in which it was not called.
+func intervalLatencySeries(name string, result *driver.Result, op s.Operation, value func(*driver.IntervalOperation) time.Duration) *series {
+	line := &series{Name: name}
+	for _, interval := range result.Intervals {
+		intervalOp, ok := interval.Operations[op]
+		if !ok || intervalOp.Count == 0 {
+			continue
+		}
+		line.Points = append(line.Points, point{
+			X: interval.End.Sub(result.StartedAt).Seconds(),
+			Y: milliseconds(value(intervalOp)),
+		})
+	}
+	return line
+}
+
+// maxNines is the highest percentile plotted, 99.99.
+const maxNines = 4
+
+// percentileSeries plots the latency of histogram in milliseconds against
+// the number of nines of the percentile, so that the tail gets as much room
+// as the median. Percentiles beyond what the calls can resolve are left out.
+func percentileSeries(name string, histogram *stats.Histogram) *series {
+	line := &series{Name: name}
+	if histogram == nil || histogram.Total == 0 {
+		return line
+	}
+
+	nines := math.Min(maxNines, math.Max(1, math.Log10(float64(histogram.Total))))
+	for x := 0.0; x <= nines+1e-9; x += 0.1 {
+		quantile := 1 - math.Pow(10, -x)
+		line.Points = append(line.Points, point{X: x, Y: milliseconds(histogram.Quantile(quantile))})
+	}
+	return line
+}
+
+func percentileChart(title string, curves []*series) *chart {
+	return &chart{
+		Title:  title,
+		XLabel: "percentile",
+		YLabel: "ms",
+		XTicks: []tick{
+			{Value: 0, Label: "0"},
+			{Value: 1, Label: "90"},
+			{Value: 2, Label: "99"},
+			{Value: 3, Label: "99.9"},
+			{Value: 4, Label: "99.99"},
+		},
+		Series: curves,
+	}
+}
+
+// samplerSections returns a section per sampler with a chart per group of
+// its values. Values are grouped by their name without its last part and by
+// their unit, so that host.memory.total_bytes and
+// host.memory.available_bytes share a chart.
+func samplerSections(result *driver.Result) []*section {
+	sections := []*section{}
+	for _, name := range sortedKeys(result.Samples) {
+		samples := result.Samples[name]
+
+		groups := map[string]map[string]*series{}
+		for _, sample := range samples {
+			for key, value := range sample.Values {
+				group := sampleGroup(key)
+				if groups[group] == nil {
+					groups[group] = map[string]*series{}
+				}
+				keySeries, ok := groups[group][key]
+				if !ok {
+					keySeries = &series{Name: key}
+					groups[group][key] = keySeries
+				}
+				keySeries.Points = append(keySeries.Points, point{
+					X: sample.Time.Sub(result.StartedAt).Seconds(),
+					Y: value,
+				})
+			}
+		}
+
+		samplerSection := &section{Title: "Sampler " + name}
+		for _, group := range sortedKeys(groups) {
+			c := &chart{
+				Title:  group,
+				XLabel: "seconds",
+				YLabel: unit(group),
+			}
+			for _, key := range sortedKeys(groups[group]) {
+				c.Series = append(c.Series, groups[group][key])
+			}
+			samplerSection.add(c)
+		}
+		sections = append(sections, samplerSection)
+	}
+	return sections
+}
+
+// units maps the suffixes of sampled values to their units.
+var units = []struct {
+	suffix string
+	unit   string
+}{
+	{"_percent", "%"},
+	{"_bytes", "bytes"},
+	{"_seconds", "s"},
+	{"_ms", "ms"},
+}
+
+func sampleGroup(key string) string {
+	group := key
+	if i := strings.LastIndex(key, "."); i >= 0 {
+		group = key[:i]
+	}
+	for _, u := range units {
+		if strings.HasSuffix(key, u.suffix) {
+			return group + " (" + u.unit + ")"
+		}
+	}
+	return group
+}
+
+func unit(group string) string {
+	for _, u := range units {
+		if strings.HasSuffix(group, " ("+u.unit+")") {
+			return u.unit
+		}
+	}
+	return ""
+}
+
+func metadataRows(runs ...*driver.Result) []metadataRow {
+	keys := map[string]bool{}
+	for _, result := range runs {
+		for key := range result.Metadata {
+			keys[key] = true
+		}
+	}
+
+	rows := []metadataRow{}
+	for _, key := range sortedKeys(keys) {
+		row := metadataRow{Key: key}
+		for _, result := range runs {
+			row.Values = append(row.Values, result.Metadata[key])
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+func operationRows(result *driver.Result) []operationRow {
+	rows := []operationRow{}
+	for _, op := range operations(result) {
+		rows = append(rows, operationRow{Operation: op, Result: result.Operations[op]})
+	}
+	return rows
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func formatChange(value float64) string {
+	return fmt.Sprintf("%+.1f%%", 100*value)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
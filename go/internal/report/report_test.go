@@ -0,0 +1,155 @@
+package report_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/report"
+	"github.com/jlym/dbbenchmark/go/internal/results"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+func newResult(runID string, latency time.Duration) *driver.Result {
+	histogram := stats.NewHistogram()
+	for i := 0; i < 1000; i++ {
+		histogram.Record(latency + time.Duration(i)*time.Microsecond)
+	}
+
+	startedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	result := &driver.Result{
+		RunID:     runID,
+		StartedAt: startedAt,
+		EndedAt:   startedAt.Add(3 * time.Second),
+		Metadata:  map[string]string{"driver.backend": "postgres"},
+		Operations: map[s.Operation]*driver.OperationResult{
+			s.OpGetPost: {
+				Count:      histogram.Total,
+				Errors:     2,
+				Throughput: float64(histogram.Total) / 3,
+				P50:        histogram.Quantile(0.5),
+				P99:        histogram.Quantile(0.99),
+				Latency:    histogram,
+			},
+		},
+		Samples: map[string][]*driver.Sample{},
+		Phases: []*driver.PhaseResult{
+			{
+				Name:       "warmup",
+				Kind:       driver.PhaseWarmup,
+				StartedAt:  startedAt,
+				EndedAt:    startedAt.Add(time.Second),
+				Stable:     true,
+				Operations: map[s.Operation]*driver.OperationResult{},
+			},
+			{
+				Name:      "steady",
+				Kind:      driver.PhaseSteady,
+				StartedAt: startedAt.Add(time.Second),
+				EndedAt:   startedAt.Add(3 * time.Second),
+				Operations: map[s.Operation]*driver.OperationResult{
+					s.OpGetPost: {Count: histogram.Total, Errors: 2, Latency: histogram},
+				},
+			},
+		},
+	}
+	for i := 1; i <= 3; i++ {
+		end := startedAt.Add(time.Duration(i) * time.Second)
+		result.Intervals = append(result.Intervals, &driver.Interval{
+			End:     end,
+			Elapsed: time.Second,
+			Operations: map[s.Operation]*driver.IntervalOperation{
+				s.OpGetPost: {Count: 333, Errors: 1, P50: latency, P99: 2 * latency},
+			},
+		})
+		result.Samples["pool"] = append(result.Samples["pool"], &driver.Sample{
+			Time: end,
+			Values: map[string]float64{
+				"pool.conns.acquired":       float64(i),
+				"pool.conns.idle":           float64(4 - i),
+				"pool.acquire_wait_seconds": 0.01,
+			},
+		})
+	}
+	return result
+}
+
+func TestWriteRun(t *testing.T) {
+	result := newResult("20240601-120000-abcd", time.Millisecond)
+
+	// Act:
+	var buffer bytes.Buffer
+	err := report.WriteRun(&buffer, result)
+	require.NoError(t, err)
+
+	// Assert: The report stands alone and has a chart of each kind.
+	html := buffer.String()
+	require.True(t, strings.HasPrefix(html, "<!DOCTYPE html>"))
+	require.NotContains(t, html, "<script")
+	require.NotContains(t, html, "<link")
+	require.NotContains(t, html, "ZgotmplZ")
+	require.Contains(t, html, "Run 20240601-120000-abcd")
+	for _, title := range []string{"Throughput", "Errors", "Latency by percentile", "p99 latency", "pool.conns", "pool (s)"} {
+		require.Contains(t, html, "<h3>"+title+"</h3>", "title=%s", title)
+	}
+
+	// Assert: The phases are summed up.
+	require.Contains(t, html, "<h2>Phases</h2>")
+	require.Contains(t, html, "<td>steady</td><td>steady</td><td>2s</td><td>1000</td><td>2</td><td>500.0</td>")
+	require.Contains(t, html, "ended once stable")
+	require.Contains(t, html, "<h2>Sampler pool</h2>")
+	require.Contains(t, html, "<polyline")
+	require.Contains(t, html, "99.9</text>")
+	require.Contains(t, html, "GetPost")
+}
+
+func TestWriteComparison(t *testing.T) {
+	a := newResult("a", time.Millisecond)
+	b := newResult("b", 2*time.Millisecond)
+	comparison := results.Compare(a, b, nil)
+
+	// Act:
+	var buffer bytes.Buffer
+	err := report.WriteComparison(&buffer, comparison, a, b)
+	require.NoError(t, err)
+
+	// Assert:
+	html := buffer.String()
+	require.NotContains(t, html, "ZgotmplZ")
+	require.Contains(t, html, "Run a vs b")
+	require.Contains(t, html, `<tr class="regression">`)
+	require.Contains(t, html, "<h3>GetPost</h3>")
+}
+
+func TestWriteMatrix(t *testing.T) {
+	fast := newResult("fast", time.Millisecond)
+	slow := newResult("slow", 3*time.Millisecond)
+
+	// Act:
+	var buffer bytes.Buffer
+	err := report.WriteMatrix(&buffer, "Matrix m1", []string{"pool-size=16", "pool-size=4"}, []*driver.Result{fast, slow})
+	require.NoError(t, err)
+
+	// Assert: Every cell is labelled, and the cell with the lowest p99 of an
+	// operation is highlighted.
+	html := buffer.String()
+	require.NotContains(t, html, "ZgotmplZ")
+	require.Contains(t, html, "<th>pool-size=16 fast</th>")
+	require.Contains(t, html, "<h2>Matrix</h2>")
+	require.Contains(t, html, `<tr class="improvement"><td>GetPost</td><td>pool-size=16</td>`)
+	require.Contains(t, html, "<tr><td>GetPost</td><td>pool-size=4</td>")
+	require.Contains(t, html, "<h3>GetPost</h3>")
+}
+
+func TestWriteMatrixLabels(t *testing.T) {
+	// Act:
+	err := report.WriteMatrix(&bytes.Buffer{}, "Matrix", []string{"a"}, []*driver.Result{})
+
+	// Assert:
+	require.ErrorContains(t, err, "needs a label per run")
+}
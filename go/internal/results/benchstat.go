@@ -0,0 +1,118 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+// benchstatUnits describes the units WriteBenchstat adds to ns/op, so that
+// benchstat knows which direction is better.
+var benchstatUnits = []string{
+	"Unit ops/s better=higher",
+	"Unit p50-ns better=lower",
+	"Unit p90-ns better=lower",
+	"Unit p99-ns better=lower",
+	"Unit max-ns better=lower",
+	"Unit errors/op better=lower",
+}
+
+// WriteBenchstat writes all in the text format of go test -bench, so that
+// it can be fed to benchstat. Every run becomes a benchmark per operation,
+// named after it, plus BenchmarkAll for the whole workload. The iterations
+// are the calls, ns/op is their mean latency and ops/s their throughput.
+// Runs of the same configuration in one file count as repeated samples, so
+// benchstat reports their variance.
+//
+// Run metadata, such as driver.workers and pg.setting.work_mem, is written as
+// configuration lines before each run. Keys that the format does not allow
+// are left out.
+func WriteBenchstat(w io.Writer, all []*driver.Result) error {
+	fmt.Fprintf(w, "goos: %s\n", runtime.GOOS)
+	fmt.Fprintf(w, "goarch: %s\n", runtime.GOARCH)
+	for _, line := range benchstatUnits {
+		fmt.Fprintln(w, line)
+	}
+
+	for _, result := range all {
+		fmt.Fprintln(w)
+		for _, key := range sortedKeys(result.Metadata) {
+			if !isBenchstatKey(key) {
+				continue
+			}
+			fmt.Fprintf(w, "%s: %s\n", key, strings.ReplaceAll(result.Metadata[key], "\n", " "))
+		}
+
+		total := &driver.OperationResult{Latency: stats.NewHistogram()}
+		for _, op := range s.Operations {
+			opResult, ok := result.Operations[op]
+			if !ok || opResult.Count == 0 {
+				continue
+			}
+			writeBenchmark(w, string(op), opResult)
+
+			total.Count += opResult.Count
+			total.Errors += opResult.Errors
+			total.Throughput += opResult.Throughput
+			if opResult.Latency != nil {
+				total.Latency.Merge(opResult.Latency)
+			}
+		}
+		if total.Count > 0 {
+			total.Mean = total.Latency.Mean()
+			total.P50 = total.Latency.Quantile(0.5)
+			total.P90 = total.Latency.Quantile(0.9)
+			total.P99 = total.Latency.Quantile(0.99)
+			total.Max = total.Latency.Max
+			writeBenchmark(w, "All", total)
+		}
+	}
+
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+func writeBenchmark(w io.Writer, name string, opResult *driver.OperationResult) {
+	fmt.Fprintf(w, "Benchmark%s\t%d\t%d ns/op\t%.2f ops/s\t%d p50-ns\t%d p90-ns\t%d p99-ns\t%d max-ns\t%.4g errors/op\n",
+		name,
+		opResult.Count,
+		opResult.Mean.Nanoseconds(),
+		opResult.Throughput,
+		opResult.P50.Nanoseconds(),
+		opResult.P90.Nanoseconds(),
+		opResult.P99.Nanoseconds(),
+		opResult.Max.Nanoseconds(),
+		float64(opResult.Errors)/float64(opResult.Count),
+	)
+}
+
+// isBenchstatKey reports whether key can be a configuration key: it must
+// start with a lower case letter and hold neither spaces nor upper case
+// letters.
+func isBenchstatKey(key string) bool {
+	if key == "" || !unicode.IsLower(rune(key[0])) {
+		return false
+	}
+	for _, r := range key {
+		if unicode.IsSpace(r) || unicode.IsUpper(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,267 @@
+package results
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+var DefaultCompareOptions = &CompareOptions{
+	Threshold:     0.05,
+	Alpha:         0.01,
+	TailThreshold: 0.25,
+	MinTailCount:  1000,
+}
+
+type CompareOptions struct {
+	// Threshold is the smallest change of the median latency or of the
+	// throughput that counts, e.g. 0.05 for 5%. Runs with many calls make
+	// even tiny changes significant, so significance alone is not enough.
+	Threshold float64
+	// Alpha is the significance level of the Mann-Whitney tests.
+	Alpha float64
+	// TailThreshold is the smallest change of the p99 latency that counts.
+	// The test compares whole distributions, so it misses changes of the
+	// slowest calls alone, and tails are noisier than medians.
+	TailThreshold float64
+	// MinTailCount is the fewest calls each run needs for its p99 to be
+	// judged, so that the p99 rests on more than a handful of slow calls.
+	MinTailCount uint64
+}
+
+// Verdict is the outcome of comparing one operation between two runs.
+type Verdict string
+
+const (
+	VerdictNone        Verdict = ""
+	VerdictRegression  Verdict = "regression"
+	VerdictImprovement Verdict = "improvement"
+)
+
+// OperationComparison compares one operation between runs A and B. Changes
+// are B relative to A, so 0.1 means B is 10% higher.
+type OperationComparison struct {
+	Operation        s.Operation
+	CountA           uint64
+	CountB           uint64
+	ThroughputA      float64
+	ThroughputB      float64
+	ThroughputChange float64
+	P50A             time.Duration
+	P50B             time.Duration
+	P50Change        float64
+	P99A             time.Duration
+	P99B             time.Duration
+	P99Change        float64
+	// Test compares the latency distributions. It is nil when either run
+	// has no calls of the operation.
+	Test           *stats.MannWhitneyResult
+	LatencyVerdict Verdict
+	// TailVerdict judges the p99 latency. It is none when either run has
+	// fewer than MinTailCount calls of the operation.
+	TailVerdict Verdict
+	// ThroughputTest compares the throughput of the measured intervals of
+	// the runs. It is nil when either run has none.
+	ThroughputTest    *stats.MannWhitneyResult
+	ThroughputVerdict Verdict
+	// Verdict is a regression when latency, tail latency or throughput
+	// regressed, and otherwise an improvement when any improved.
+	Verdict Verdict
+}
+
+type Comparison struct {
+	RunA       string
+	RunB       string
+	Options    *CompareOptions
+	Operations []*OperationComparison
+}
+
+// Compare compares the latency and throughput of every operation of a and
+// b. An operation regressed when its latency distribution shifted up
+// significantly and its median grew by more than options.Threshold, when
+// its p99 grew by more than options.TailThreshold, or when the throughput
+// of its measured intervals shifted down significantly and its throughput
+// fell by more than options.Threshold.
+func Compare(a *driver.Result, b *driver.Result, options *CompareOptions) *Comparison {
+	if options == nil {
+		options = DefaultCompareOptions
+	}
+
+	comparison := &Comparison{
+		RunA:    a.RunID,
+		RunB:    b.RunID,
+		Options: options,
+	}
+	for _, op := range s.Operations {
+		opA, okA := a.Operations[op]
+		opB, okB := b.Operations[op]
+		if !okA && !okB {
+			continue
+		}
+
+		opComparison := &OperationComparison{Operation: op}
+		if okA {
+			opComparison.CountA = opA.Count
+			opComparison.ThroughputA = opA.Throughput
+			opComparison.P50A = opA.P50
+			opComparison.P99A = opA.P99
+		}
+		if okB {
+			opComparison.CountB = opB.Count
+			opComparison.ThroughputB = opB.Throughput
+			opComparison.P50B = opB.P50
+			opComparison.P99B = opB.P99
+		}
+		opComparison.ThroughputChange = change(opComparison.ThroughputA, opComparison.ThroughputB)
+		opComparison.P50Change = change(float64(opComparison.P50A), float64(opComparison.P50B))
+		opComparison.P99Change = change(float64(opComparison.P99A), float64(opComparison.P99B))
+
+		if okA && okB && opA.Latency != nil && opB.Latency != nil && opA.Count > 0 && opB.Count > 0 {
+			opComparison.Test = stats.MannWhitney(opA.Latency, opB.Latency)
+			// Lower latency is better.
+			opComparison.LatencyVerdict = verdict(opComparison.Test.P, -opComparison.Test.Z, -opComparison.P50Change, options)
+		}
+		if okA && okB && opA.Count >= options.MinTailCount && opB.Count >= options.MinTailCount {
+			opComparison.TailVerdict = tailVerdict(opComparison.P99Change, options)
+		}
+		throughputA, throughputB := measuredThroughput(a, op), measuredThroughput(b, op)
+		if len(throughputA) > 0 && len(throughputB) > 0 {
+			opComparison.ThroughputTest = stats.MannWhitneyValues(throughputA, throughputB)
+			opComparison.ThroughputVerdict = verdict(opComparison.ThroughputTest.P, opComparison.ThroughputTest.Z, opComparison.ThroughputChange, options)
+		}
+		switch {
+		case opComparison.LatencyVerdict == VerdictRegression ||
+			opComparison.TailVerdict == VerdictRegression ||
+			opComparison.ThroughputVerdict == VerdictRegression:
+			opComparison.Verdict = VerdictRegression
+		case opComparison.LatencyVerdict == VerdictImprovement ||
+			opComparison.TailVerdict == VerdictImprovement ||
+			opComparison.ThroughputVerdict == VerdictImprovement:
+			opComparison.Verdict = VerdictImprovement
+		}
+
+		comparison.Operations = append(comparison.Operations, opComparison)
+	}
+	return comparison
+}
+
+// Regressions returns the operations that regressed.
+func (c *Comparison) Regressions() []s.Operation {
+	regressions := []s.Operation{}
+	for _, opComparison := range c.Operations {
+		if opComparison.Verdict == VerdictRegression {
+			regressions = append(regressions, opComparison.Operation)
+		}
+	}
+	return regressions
+}
+
+// WriteSummary writes a table of the comparison.
+func (c *Comparison) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintf(tw, "A\t%s\n", c.RunA)
+	fmt.Fprintf(tw, "B\t%s\n", c.RunB)
+	fmt.Fprintf(tw, "threshold\t%.1f%%\n", 100*c.Options.Threshold)
+	fmt.Fprintf(tw, "alpha\t%g\n", c.Options.Alpha)
+	fmt.Fprintf(tw, "tail threshold\t%.1f%%\n", 100*c.Options.TailThreshold)
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "operation\tcount A\tcount B\tops/s A\tops/s B\tΔ ops/s\tp ops/s\tp50 A\tp50 B\tΔ p50\tp99 A\tp99 B\tΔ p99\tp latency\tverdict")
+	for _, op := range c.Operations {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%.1f\t%.1f\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			op.Operation,
+			op.CountA,
+			op.CountB,
+			op.ThroughputA,
+			op.ThroughputB,
+			formatChange(op.ThroughputChange),
+			formatP(op.ThroughputTest),
+			formatLatency(op.P50A),
+			formatLatency(op.P50B),
+			formatChange(op.P50Change),
+			formatLatency(op.P99A),
+			formatLatency(op.P99B),
+			formatChange(op.P99Change),
+			formatP(op.Test),
+			op.Verdict,
+		)
+	}
+
+	return tw.Flush()
+}
+
+// verdict judges a change that a test with p-value p found, where z and
+// change are positive when B is better than A.
+func verdict(p float64, z float64, change float64, options *CompareOptions) Verdict {
+	if p >= options.Alpha {
+		return VerdictNone
+	}
+	if z < 0 && change < -options.Threshold {
+		return VerdictRegression
+	}
+	if z > 0 && change > options.Threshold {
+		return VerdictImprovement
+	}
+	return VerdictNone
+}
+
+// tailVerdict judges change, the change of the p99 latency.
+func tailVerdict(change float64, options *CompareOptions) Verdict {
+	switch {
+	case change > options.TailThreshold:
+		return VerdictRegression
+	case change < -options.TailThreshold:
+		return VerdictImprovement
+	}
+	return VerdictNone
+}
+
+// measuredThroughput returns the throughput of op in each interval of the
+// measured phases of result. Results without phases are measured whole.
+func measuredThroughput(result *driver.Result, op s.Operation) []float64 {
+	measured := map[string]bool{}
+	for _, phase := range result.Phases {
+		measured[phase.Name] = phase.Kind.Measured()
+	}
+
+	throughput := []float64{}
+	for _, interval := range result.Intervals {
+		if len(result.Phases) > 0 && !measured[interval.Phase] {
+			continue
+		}
+		if interval.Elapsed <= 0 {
+			continue
+		}
+		throughput = append(throughput, interval.Throughput(op))
+	}
+	return throughput
+}
+
+// change returns b relative to a, or 0 when a is 0.
+func change(a float64, b float64) float64 {
+	if a == 0 {
+		return 0
+	}
+	return b/a - 1
+}
+
+func formatChange(value float64) string {
+	return fmt.Sprintf("%+.1f%%", 100*value)
+}
+
+func formatP(test *stats.MannWhitneyResult) string {
+	if test == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.2g", test.P)
+}
+
+func formatLatency(latency time.Duration) string {
+	return latency.Round(time.Microsecond).String()
+}
@@ -0,0 +1,244 @@
+package results
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Measurements of Point.
+const (
+	// MeasurementOperation summarizes an operation over the whole run.
+	MeasurementOperation = "operation"
+	// MeasurementInterval summarizes an operation over one
+	// driver.Result.Intervals entry.
+	MeasurementInterval = "interval"
+	// MeasurementSample holds the values of one driver.Sample.
+	MeasurementSample = "sample"
+)
+
+// Point is one measurement of a run at a point in time. It is the shape the
+// CSV, JSON and InfluxDB exporters share. The exporters label every point
+// with the run ID and the run's metadata.
+type Point struct {
+	Measurement string             `json:"measurement"`
+	Time        time.Time          `json:"time"`
+	Tags        map[string]string  `json:"tags"`
+	Fields      map[string]float64 `json:"fields"`
+}
+
+// Points flattens the summary and the time series of result: a point per
+// operation, a point per operation and interval, and a point per sample.
+// Failed samples are left out.
+func Points(result *driver.Result) []*Point {
+	points := []*Point{}
+	for _, op := range s.Operations {
+		opResult, ok := result.Operations[op]
+		if !ok {
+			continue
+		}
+		points = append(points, &Point{
+			Measurement: MeasurementOperation,
+			Time:        result.EndedAt,
+			Tags:        map[string]string{"operation": string(op)},
+			Fields: map[string]float64{
+				"count":      float64(opResult.Count),
+				"errors":     float64(opResult.Errors),
+				"throughput": opResult.Throughput,
+				"mean_ns":    float64(opResult.Mean),
+				"p50_ns":     float64(opResult.P50),
+				"p90_ns":     float64(opResult.P90),
+				"p99_ns":     float64(opResult.P99),
+				"max_ns":     float64(opResult.Max),
+			},
+		})
+	}
+
+	for _, interval := range result.Intervals {
+		for _, op := range s.Operations {
+			intervalOp, ok := interval.Operations[op]
+			if !ok {
+				continue
+			}
+			points = append(points, &Point{
+				Measurement: MeasurementInterval,
+				Time:        interval.End,
+				Tags:        map[string]string{"operation": string(op)},
+				Fields: map[string]float64{
+					"count":      float64(intervalOp.Count),
+					"errors":     float64(intervalOp.Errors),
+					"throughput": interval.Throughput(op),
+					"error_rate": interval.ErrorRate(op),
+					"p50_ns":     float64(intervalOp.P50),
+					"p90_ns":     float64(intervalOp.P90),
+					"p99_ns":     float64(intervalOp.P99),
+				},
+			})
+		}
+	}
+
+	for _, sampler := range sortedKeys(result.Samples) {
+		for _, sample := range result.Samples[sampler] {
+			if sample.Error != "" || len(sample.Values) == 0 {
+				continue
+			}
+			points = append(points, &Point{
+				Measurement: MeasurementSample,
+				Time:        sample.Time,
+				Tags:        map[string]string{"sampler": sampler},
+				Fields:      sample.Values,
+			})
+		}
+	}
+
+	return points
+}
+
+// WriteCSV writes the points of all as CSV in long form, one row per field,
+// which spreadsheets can pivot and notebooks can load as they are. Each row
+// has the run ID and a column per metadata key of any of the runs.
+func WriteCSV(w io.Writer, all []*driver.Result) error {
+	labelKeys := map[string]bool{}
+	for _, result := range all {
+		for key := range result.Metadata {
+			labelKeys[key] = true
+		}
+	}
+	labels := sortedKeys(labelKeys)
+
+	cw := csv.NewWriter(w)
+	header := []string{"run_id", "measurement", "time", "elapsed_seconds", "operation", "sampler", "field", "value"}
+	cw.Write(append(header, labels...))
+
+	for _, result := range all {
+		labelValues := make([]string, len(labels))
+		for i, key := range labels {
+			labelValues[i] = result.Metadata[key]
+		}
+
+		for _, point := range Points(result) {
+			for _, field := range sortedKeys(point.Fields) {
+				row := []string{
+					result.RunID,
+					point.Measurement,
+					point.Time.Format(time.RFC3339Nano),
+					strconv.FormatFloat(point.Time.Sub(result.StartedAt).Seconds(), 'f', 3, 64),
+					point.Tags["operation"],
+					point.Tags["sampler"],
+					field,
+					strconv.FormatFloat(point.Fields[field], 'g', -1, 64),
+				}
+				cw.Write(append(row, labelValues...))
+			}
+		}
+	}
+
+	cw.Flush()
+	return errors.Wrap(cw.Error(), "writing CSV failed")
+}
+
+type jsonRun struct {
+	RunID     string            `json:"run_id"`
+	StartedAt time.Time         `json:"started_at"`
+	EndedAt   time.Time         `json:"ended_at"`
+	Labels    map[string]string `json:"labels"`
+	Points    []*Point          `json:"points"`
+}
+
+// WriteJSON writes the points of all as a JSON array with an object per
+// run, which holds the run ID, the metadata as labels and the points.
+func WriteJSON(w io.Writer, all []*driver.Result) error {
+	runs := []*jsonRun{}
+	for _, result := range all {
+		runs = append(runs, &jsonRun{
+			RunID:     result.RunID,
+			StartedAt: result.StartedAt,
+			EndedAt:   result.EndedAt,
+			Labels:    result.Metadata,
+			Points:    Points(result),
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return errors.Wrap(encoder.Encode(runs), "encoding JSON failed")
+}
+
+// influxPrefix prefixes measurements, as metrics.Metrics prefixes its
+// metrics, so that they stand out in a shared database.
+const influxPrefix = "dbbenchmark_"
+
+// WriteInflux writes the points of all in InfluxDB line protocol with
+// nanosecond timestamps. The run ID and the metadata are tags, next to the
+// operation or sampler. Fields that are not finite are left out, since the
+// protocol has no way to write them.
+func WriteInflux(w io.Writer, all []*driver.Result) error {
+	for _, result := range all {
+		runTags := map[string]string{"run_id": result.RunID}
+		for key, value := range result.Metadata {
+			runTags[key] = value
+		}
+
+		for _, point := range Points(result) {
+			tags := map[string]string{}
+			for key, value := range runTags {
+				tags[key] = value
+			}
+			for key, value := range point.Tags {
+				tags[key] = value
+			}
+
+			var line strings.Builder
+			line.WriteString(escapeInflux(influxPrefix+point.Measurement, ", "))
+			for _, key := range sortedKeys(tags) {
+				// Empty tag values are not allowed.
+				if tags[key] == "" {
+					continue
+				}
+				fmt.Fprintf(&line, ",%s=%s", escapeInflux(key, ",= "), escapeInflux(tags[key], ",= "))
+			}
+
+			separator := " "
+			for _, field := range sortedKeys(point.Fields) {
+				value := point.Fields[field]
+				if math.IsNaN(value) || math.IsInf(value, 0) {
+					continue
+				}
+				fmt.Fprintf(&line, "%s%s=%s", separator, escapeInflux(field, ",= "), strconv.FormatFloat(value, 'g', -1, 64))
+				separator = ","
+			}
+			if separator == " " {
+				continue
+			}
+
+			_, err := fmt.Fprintf(w, "%s %d\n", line.String(), point.Time.UnixNano())
+			if err != nil {
+				return errors.Wrap(err, "writing line protocol failed")
+			}
+		}
+	}
+	return nil
+}
+
+// escapeInflux escapes special, the characters that are special where s is
+// written. Line breaks cannot be escaped, so they become spaces.
+func escapeInflux(s string, special string) string {
+	var escaped strings.Builder
+	for _, r := range strings.ReplaceAll(s, "\n", " ") {
+		if strings.ContainsRune(special, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
@@ -0,0 +1,355 @@
+package results_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/results"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func newResult(runID string, latencies ...time.Duration) *driver.Result {
+	histogram := stats.NewHistogram()
+	for _, latency := range latencies {
+		histogram.Record(latency)
+	}
+
+	startedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	return &driver.Result{
+		RunID:     runID,
+		StartedAt: startedAt,
+		EndedAt:   startedAt.Add(10 * time.Second),
+		Config:    driver.DefaultConfig,
+		Metadata: map[string]string{
+			"driver.backend": "postgres",
+			"driver.workers": "8",
+			"git.sha":        "0123456789abcdef0123456789abcdef01234567-dirty",
+		},
+		Operations: map[s.Operation]*driver.OperationResult{
+			s.OpGetPost: {
+				Count:      histogram.Total,
+				Errors:     1,
+				Throughput: float64(histogram.Total) / 10,
+				P50:        histogram.Quantile(0.5),
+				P99:        histogram.Quantile(0.99),
+				Latency:    histogram,
+			},
+		},
+	}
+}
+
+func TestStore(t *testing.T) {
+	store := results.NewStore(filepath.Join(t.TempDir(), "results", "runs.jsonl"))
+
+	all, err := store.List()
+	require.NoError(t, err)
+	require.Empty(t, all)
+
+	// Act:
+	require.NoError(t, store.Append(newResult("20240601-120000-aaaa", time.Millisecond, 3*time.Millisecond)))
+	require.NoError(t, store.Append(newResult("20240601-120000-aaab", time.Millisecond)))
+	require.NoError(t, store.Append(newResult("20240602-120000-bbbb", time.Millisecond)))
+
+	// Assert: Results come back whole, oldest first.
+	all, err = store.List()
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+	require.Equal(t, "20240601-120000-aaaa", all[0].RunID)
+	latency := all[0].Operations[s.OpGetPost].Latency
+	require.Equal(t, uint64(2), latency.Total)
+	require.Equal(t, 2*time.Millisecond, latency.Mean())
+	require.Equal(t, driver.DefaultConfig.Workers, all[0].Config.Workers)
+
+	result, err := store.Get("20240602")
+	require.NoError(t, err)
+	require.Equal(t, "20240602-120000-bbbb", result.RunID)
+	result, err = store.Get("20240601-120000-aaaa")
+	require.NoError(t, err)
+	require.Equal(t, "20240601-120000-aaaa", result.RunID)
+
+	_, err = store.Get("20240601")
+	require.ErrorContains(t, err, "2 runs start with")
+	_, err = store.Get("2023")
+	require.True(t, errors.Is(err, results.ErrRunNotFound))
+}
+
+func TestWriteList(t *testing.T) {
+	result := newResult("20240601-120000-aaaa", time.Millisecond)
+	// The throughput of the measured phases, not the calls over the run.
+	result.Operations[s.OpGetPost].Throughput = 42
+
+	var buf bytes.Buffer
+	err := results.WriteList(&buf, []*driver.Result{result})
+	require.NoError(t, err)
+
+	require.Contains(t, buf.String(), "20240601-120000-aaaa")
+	require.Contains(t, buf.String(), "postgres")
+	require.Contains(t, buf.String(), "0123456789ab-dirty")
+	require.Contains(t, buf.String(), "42.0")
+}
+
+func newLatencies(scale float64) []time.Duration {
+	latencies := []time.Duration{}
+	for i := 0; i < 2000; i++ {
+		latencies = append(latencies, time.Duration(float64(1000+i%500)*scale)*time.Microsecond)
+	}
+	return latencies
+}
+
+func TestCompare(t *testing.T) {
+	base := newResult("a", newLatencies(1)...)
+	cases := []struct {
+		name    string
+		scale   float64
+		verdict results.Verdict
+	}{
+		{"same", 1, results.VerdictNone},
+		{"slower within threshold", 1.03, results.VerdictNone},
+		{"slower", 1.2, results.VerdictRegression},
+		{"faster", 0.8, results.VerdictImprovement},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Act:
+			comparison := results.Compare(base, newResult("b", newLatencies(c.scale)...), nil)
+
+			// Assert:
+			require.Len(t, comparison.Operations, 1)
+			opComparison := comparison.Operations[0]
+			require.Equal(t, s.OpGetPost, opComparison.Operation)
+			require.Equal(t, c.verdict, opComparison.Verdict)
+			require.InDelta(t, c.scale-1, opComparison.P50Change, 0.02)
+			if c.verdict == results.VerdictRegression {
+				require.Equal(t, []s.Operation{s.OpGetPost}, comparison.Regressions())
+			} else {
+				require.Empty(t, comparison.Regressions())
+			}
+
+			var buf bytes.Buffer
+			require.NoError(t, comparison.WriteSummary(&buf))
+			require.Contains(t, buf.String(), "GetPost")
+		})
+	}
+}
+
+func TestCompareTail(t *testing.T) {
+	a := newResult("a", newLatencies(1)...)
+	// The slowest 2% of the calls of B take ten times longer.
+	latencies := newLatencies(1)
+	for i := range latencies[:40] {
+		latencies[i] *= 10
+	}
+	b := newResult("b", latencies...)
+
+	// Act:
+	comparison := results.Compare(a, b, nil)
+
+	// Assert: The median is the same but the tail regressed.
+	opComparison := comparison.Operations[0]
+	require.Equal(t, results.VerdictNone, opComparison.LatencyVerdict)
+	require.Greater(t, opComparison.P99Change, 1.0)
+	require.Equal(t, results.VerdictRegression, opComparison.TailVerdict)
+	require.Equal(t, results.VerdictRegression, opComparison.Verdict)
+
+	// Assert: Runs with too few calls leave the tail unjudged.
+	options := *results.DefaultCompareOptions
+	options.MinTailCount = 5000
+	comparison = results.Compare(a, b, &options)
+	require.Equal(t, results.VerdictNone, comparison.Operations[0].TailVerdict)
+}
+
+func newIntervals(result *driver.Result, counts ...uint64) []*driver.Interval {
+	intervals := []*driver.Interval{}
+	for i, count := range counts {
+		intervals = append(intervals, &driver.Interval{
+			End:     result.StartedAt.Add(time.Duration(i+1) * time.Second),
+			Elapsed: time.Second,
+			Phase:   "steady",
+			Operations: map[s.Operation]*driver.IntervalOperation{
+				s.OpGetPost: {Count: count},
+			},
+		})
+	}
+	return intervals
+}
+
+func TestCompareThroughput(t *testing.T) {
+	a := newResult("a", newLatencies(1)...)
+	b := newResult("b", newLatencies(1)...)
+	for _, result := range []*driver.Result{a, b} {
+		result.Phases = []*driver.PhaseResult{
+			{Name: "warmup", Kind: driver.PhaseWarmup},
+			{Name: "steady", Kind: driver.PhaseSteady},
+		}
+	}
+	a.Operations[s.OpGetPost].Throughput = 200
+	b.Operations[s.OpGetPost].Throughput = 160
+	a.Intervals = newIntervals(a, 195, 205, 198, 202, 200, 199, 201, 197, 203, 200)
+	b.Intervals = newIntervals(b, 155, 165, 158, 162, 160, 159, 161, 157, 163, 160)
+	// The warmup of B is faster, but left out.
+	b.Intervals = append(b.Intervals, &driver.Interval{
+		Elapsed:    time.Second,
+		Phase:      "warmup",
+		Operations: map[s.Operation]*driver.IntervalOperation{s.OpGetPost: {Count: 1000}},
+	})
+
+	// Act:
+	comparison := results.Compare(a, b, nil)
+
+	// Assert: Latency is the same but throughput fell.
+	opComparison := comparison.Operations[0]
+	require.Equal(t, results.VerdictNone, opComparison.LatencyVerdict)
+	require.Less(t, opComparison.ThroughputTest.P, 0.001)
+	require.Less(t, opComparison.ThroughputTest.Z, 0.0)
+	require.Equal(t, results.VerdictRegression, opComparison.ThroughputVerdict)
+	require.Equal(t, results.VerdictRegression, opComparison.Verdict)
+	require.Equal(t, []s.Operation{s.OpGetPost}, comparison.Regressions())
+
+	// Assert: Swapping the runs makes it an improvement.
+	comparison = results.Compare(b, a, nil)
+	require.Equal(t, results.VerdictImprovement, comparison.Operations[0].Verdict)
+}
+
+func TestWriteBenchstat(t *testing.T) {
+	result := newResult("20240601-120000-aaaa", time.Millisecond, 3*time.Millisecond)
+	result.Metadata["pg.setting.shared_buffers"] = "128MB"
+	result.Metadata["Bad Key"] = "left out"
+
+	// Act:
+	var buf bytes.Buffer
+	err := results.WriteBenchstat(&buf, []*driver.Result{result, result})
+	require.NoError(t, err)
+
+	// Assert: Every run has its configuration and a line per operation with
+	// the calls as iterations.
+	output := buf.String()
+	require.Contains(t, output, "Unit ops/s better=higher\n")
+	require.Equal(t, 2, strings.Count(output, "pg.setting.shared_buffers: 128MB\n"))
+	require.NotContains(t, output, "Bad Key")
+
+	var lines []string
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, "BenchmarkGetPost\t") {
+			lines = append(lines, line)
+		}
+	}
+	require.Len(t, lines, 2)
+	fields := strings.Split(lines[0], "\t")
+	require.Equal(t, "2", fields[1])
+	require.Regexp(t, `^\d+ ns/op$`, fields[2])
+	require.Equal(t, "0.20 ops/s", fields[3])
+	require.Equal(t, "0.5 errors/op", fields[len(fields)-1])
+	require.Equal(t, 2, strings.Count(output, "BenchmarkAll\t2\t"))
+}
+
+func newTimeSeriesResult() *driver.Result {
+	result := newResult("20240601-120000-aaaa", time.Millisecond, 3*time.Millisecond)
+	result.Metadata["pg.setting.work_mem"] = "4MB"
+	result.Intervals = []*driver.Interval{{
+		End:     result.StartedAt.Add(time.Second),
+		Elapsed: time.Second,
+		Operations: map[s.Operation]*driver.IntervalOperation{
+			s.OpGetPost: {Count: 2, Errors: 1, P50: time.Millisecond, P99: 3 * time.Millisecond},
+		},
+	}}
+	result.Samples = map[string][]*driver.Sample{
+		"pool": {
+			{Time: result.StartedAt.Add(time.Second), Values: map[string]float64{"pool.conns.idle": 3}},
+			{Time: result.StartedAt.Add(2 * time.Second), Error: "failed"},
+		},
+	}
+	return result
+}
+
+func TestPoints(t *testing.T) {
+	// Act:
+	points := results.Points(newTimeSeriesResult())
+
+	// Assert: The failed sample is left out.
+	require.Len(t, points, 3)
+	require.Equal(t, results.MeasurementOperation, points[0].Measurement)
+	require.Equal(t, 2.0, points[0].Fields["count"])
+	require.Equal(t, results.MeasurementInterval, points[1].Measurement)
+	require.Equal(t, "GetPost", points[1].Tags["operation"])
+	require.Equal(t, 1.0, points[1].Fields["error_rate"])
+	require.Equal(t, results.MeasurementSample, points[2].Measurement)
+	require.Equal(t, "pool", points[2].Tags["sampler"])
+	require.Equal(t, 3.0, points[2].Fields["pool.conns.idle"])
+}
+
+func TestWriteCSV(t *testing.T) {
+	// Act:
+	var buf bytes.Buffer
+	err := results.WriteCSV(&buf, []*driver.Result{newTimeSeriesResult()})
+	require.NoError(t, err)
+
+	// Assert: Every field is a row labelled with the run ID and metadata.
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	header := rows[0]
+	require.Equal(t, []string{"run_id", "measurement", "time", "elapsed_seconds", "operation", "sampler", "field", "value"}, header[:8])
+	require.Contains(t, header, "pg.setting.work_mem")
+	require.Len(t, rows, 1+8+7+1)
+
+	found := false
+	for _, row := range rows[1:] {
+		require.Equal(t, "20240601-120000-aaaa", row[0])
+		require.Contains(t, row, "4MB")
+		if row[1] == results.MeasurementSample {
+			require.Equal(t, []string{"1.000", "", "pool", "pool.conns.idle", "3"}, row[3:8])
+			found = true
+		}
+	}
+	require.True(t, found)
+}
+
+func TestWriteJSON(t *testing.T) {
+	// Act:
+	var buf bytes.Buffer
+	err := results.WriteJSON(&buf, []*driver.Result{newTimeSeriesResult()})
+	require.NoError(t, err)
+
+	// Assert:
+	var runs []struct {
+		RunID  string            `json:"run_id"`
+		Labels map[string]string `json:"labels"`
+		Points []*results.Point  `json:"points"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &runs))
+	require.Len(t, runs, 1)
+	require.Equal(t, "20240601-120000-aaaa", runs[0].RunID)
+	require.Equal(t, "4MB", runs[0].Labels["pg.setting.work_mem"])
+	require.Len(t, runs[0].Points, 3)
+}
+
+func TestWriteInflux(t *testing.T) {
+	result := newTimeSeriesResult()
+	result.Metadata["pg.setting.search_path"] = `"$user", public`
+
+	// Act:
+	var buf bytes.Buffer
+	err := results.WriteInflux(&buf, []*driver.Result{result})
+	require.NoError(t, err)
+
+	// Assert: Special characters in tags are escaped.
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t,
+		`dbbenchmark_sample,driver.backend=postgres,driver.workers=8,git.sha=0123456789abcdef0123456789abcdef01234567-dirty,`+
+			`pg.setting.search_path="$user"\,\ public,pg.setting.work_mem=4MB,run_id=20240601-120000-aaaa,sampler=pool `+
+			`pool.conns.idle=3 1717243201000000000`,
+		lines[2])
+	require.True(t, strings.HasPrefix(lines[0], "dbbenchmark_operation,"))
+	require.Contains(t, lines[0], ",operation=GetPost,")
+	require.Contains(t, lines[0], " count=2,errors=1,")
+}
@@ -0,0 +1,150 @@
+// Package results keeps the results of benchmark runs, so that runs can be
+// listed, shown and compared after the fact.
+package results
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+)
+
+// ErrRunNotFound is returned by Store.Get when no run matches.
+var ErrRunNotFound = errors.New("run not found")
+
+// Store keeps results in a JSON-lines file, one run per line, oldest first.
+// Appending a line is atomic enough for one benchmark at a time; the store
+// is not meant to be written by concurrent runs.
+type Store struct {
+	Path string
+}
+
+func NewStore(path string) *Store {
+	return &Store{
+		Path: path,
+	}
+}
+
+// Append adds result to the end of the store, creating it if needed.
+func (st *Store) Append(result *driver.Result) error {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return errors.Wrap(err, "encoding result failed")
+	}
+
+	err = os.MkdirAll(filepath.Dir(st.Path), 0o755)
+	if err != nil {
+		return errors.Wrapf(err, "creating results directory failed, path=\"%s\"", st.Path)
+	}
+	file, err := os.OpenFile(st.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrapf(err, "opening results failed, path=\"%s\"", st.Path)
+	}
+	defer file.Close()
+
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		return errors.Wrapf(err, "appending result failed, path=\"%s\"", st.Path)
+	}
+	return errors.WithStack(file.Close())
+}
+
+// List returns every result in the store, oldest first. An empty list is
+// returned when the store does not exist yet.
+func (st *Store) List() ([]*driver.Result, error) {
+	file, err := os.Open(st.Path)
+	if os.IsNotExist(err) {
+		return []*driver.Result{}, nil
+	} else if err != nil {
+		return nil, errors.Wrapf(err, "opening results failed, path=\"%s\"", st.Path)
+	}
+	defer file.Close()
+
+	// Lines hold whole histograms and samples, so they are decoded as a
+	// stream rather than scanned line by line.
+	results := []*driver.Result{}
+	decoder := json.NewDecoder(file)
+	for {
+		var result driver.Result
+		err = decoder.Decode(&result)
+		if err == io.EOF {
+			return results, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "decoding result %d failed, path=\"%s\"", len(results)+1, st.Path)
+		}
+		results = append(results, &result)
+	}
+}
+
+// Get returns the run whose ID is runID, or starts with it when only one
+// does.
+func (st *Store) Get(runID string) (*driver.Result, error) {
+	all, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*driver.Result{}
+	for _, result := range all {
+		if result.RunID == runID {
+			return result, nil
+		}
+		if strings.HasPrefix(result.RunID, runID) {
+			matches = append(matches, result)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, errors.Wrapf(ErrRunNotFound, "runID=\"%s\"", runID)
+	} else if len(matches) > 1 {
+		return nil, errors.Errorf("%d runs start with \"%s\"", len(matches), runID)
+	}
+	return matches[0], nil
+}
+
+// WriteList writes one line per result with what tells runs apart.
+func WriteList(w io.Writer, results []*driver.Result) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "run\tstarted\telapsed\tbackend\tworkers\tops/s\terrors\tgit")
+	for _, result := range results {
+		throughput := 0.0
+		errorCount := uint64(0)
+		for _, opResult := range result.Operations {
+			throughput += opResult.Throughput
+			errorCount += opResult.Errors
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.1f\t%d\t%s\n",
+			result.RunID,
+			result.StartedAt.Local().Format(time.DateTime),
+			result.Elapsed().Round(time.Second),
+			result.Metadata["driver.backend"],
+			result.Metadata["driver.workers"],
+			throughput,
+			errorCount,
+			shortSHA(result.Metadata["git.sha"]),
+		)
+	}
+	return tw.Flush()
+}
+
+// shortSHA shortens a commit SHA, keeping a "-dirty" suffix.
+func shortSHA(sha string) string {
+	dirty := strings.HasSuffix(sha, "-dirty")
+	sha = strings.TrimSuffix(sha, "-dirty")
+	if len(sha) > 12 {
+		sha = sha[:12]
+	}
+	if dirty {
+		sha += "-dirty"
+	}
+	return sha
+}
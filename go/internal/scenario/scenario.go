@@ -0,0 +1,296 @@
+// Package scenario reads workloads from YAML files: who the virtual users
+// are and what they do, the phases of the run, the dataset it needs and the
+// SLOs it is held to. A library of built-in scenarios is embedded.
+package scenario
+
+import (
+	"bytes"
+	"embed"
+	"io/fs"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+//go:embed builtin/*.yaml
+var builtin embed.FS
+
+// Scenario is a workload as written in a scenario file. Fields left out keep
+// the values of the driver.Config it is applied to.
+type Scenario struct {
+	Name        string `yaml:"name"`
+	Description string `yaml:"description"`
+	Seed        int64  `yaml:"seed"`
+	// SampleInterval is how often samplers are sampled and intervals cut.
+	SampleInterval time.Duration        `yaml:"sample_interval"`
+	Dataset        *Dataset             `yaml:"dataset"`
+	Groups         []*Group             `yaml:"groups"`
+	Phases         []*Phase             `yaml:"phases"`
+	SLOs           map[s.Operation]*SLO `yaml:"slos"`
+	Targets        *Targets             `yaml:"targets"`
+}
+
+// Dataset is the dataset the scenario needs. See driver.DatasetConfig.
+type Dataset struct {
+	LargeCreators    int `yaml:"large_creators"`
+	SmallCreators    int `yaml:"small_creators"`
+	Viewers          int `yaml:"viewers"`
+	PostsPerCreator  int `yaml:"posts_per_creator"`
+	FollowsPerViewer int `yaml:"follows_per_viewer"`
+}
+
+// Group is a group of virtual users. See driver.UserGroup.
+type Group struct {
+	Name      string              `yaml:"name"`
+	Role      s.Role              `yaml:"role"`
+	Users     int                 `yaml:"users"`
+	Mix       map[s.Operation]int `yaml:"mix"`
+	ThinkTime ThinkTime           `yaml:"think_time"`
+	// Session makes the users act in sessions rather than by Mix.
+	Session *Session `yaml:"session"`
+}
+
+// Session is a Markov chain of operations. See driver.Session.
+type Session struct {
+	Start       map[s.Operation]int                 `yaml:"start"`
+	Transitions map[s.Operation]map[s.Operation]int `yaml:"transitions"`
+	Length      SessionLength                       `yaml:"length"`
+	Pause       ThinkTime                           `yaml:"pause"`
+}
+
+type SessionLength struct {
+	Min int `yaml:"min"`
+	Max int `yaml:"max"`
+}
+
+type ThinkTime struct {
+	Min time.Duration `yaml:"min"`
+	Max time.Duration `yaml:"max"`
+}
+
+// Phase is a part of the run. See driver.Phase.
+type Phase struct {
+	Name     string           `yaml:"name"`
+	Kind     driver.PhaseKind `yaml:"kind"`
+	Duration time.Duration    `yaml:"duration"`
+	Users    int              `yaml:"users"`
+	// Rate is in calls per second and makes the phase open-loop.
+	Rate  float64 `yaml:"rate"`
+	Steps int     `yaml:"steps"`
+	// UntilStable ends a warmup once its load is stable.
+	UntilStable *Stability `yaml:"until_stable"`
+}
+
+// Stability tells when a load is stable. See driver.Stability.
+type Stability struct {
+	Window int     `yaml:"window"`
+	MaxCV  float64 `yaml:"max_cv"`
+}
+
+// Targets skews which users and posts calls target. See
+// driver.Config.UserTargets and driver.Config.PostTargets.
+type Targets struct {
+	Users *Distribution `yaml:"users"`
+	Posts *Distribution `yaml:"posts"`
+}
+
+// Distribution is a skewed choice of targets. See driver.Distribution.
+type Distribution struct {
+	Kind        driver.DistributionKind `yaml:"kind"`
+	Exponent    float64                 `yaml:"exponent"`
+	HotFraction float64                 `yaml:"hot_fraction"`
+	HotShare    float64                 `yaml:"hot_share"`
+}
+
+var distributions = []driver.DistributionKind{
+	driver.DistributionUniform,
+	driver.DistributionZipfian,
+	driver.DistributionHotspot,
+	driver.DistributionLatest,
+}
+
+func (d *Distribution) driverDistribution() *driver.Distribution {
+	if d == nil {
+		return nil
+	}
+	return &driver.Distribution{Kind: d.Kind, Exponent: d.Exponent, HotFraction: d.HotFraction, HotShare: d.HotShare}
+}
+
+type SLO struct {
+	P50       time.Duration `yaml:"p50"`
+	P90       time.Duration `yaml:"p90"`
+	P99       time.Duration `yaml:"p99"`
+	ErrorRate *float64      `yaml:"error_rate"`
+}
+
+var roles = []s.Role{s.RoleLargeCreator, s.RoleSmallCreator, s.RoleViewer}
+
+// Parse reads a scenario from YAML. Unknown fields, roles and operations are
+// errors, so that typos do not silently change the workload.
+func Parse(data []byte) (*Scenario, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+
+	scenario := &Scenario{}
+	err := decoder.Decode(scenario)
+	if err != nil {
+		return nil, errors.Wrap(err, "parsing scenario failed")
+	}
+	err = scenario.validate()
+	if err != nil {
+		return nil, err
+	}
+	return scenario, nil
+}
+
+func (sc *Scenario) validate() error {
+	if len(sc.Groups) == 0 {
+		return errors.Errorf("scenario \"%s\" has no groups", sc.Name)
+	}
+	for i, group := range sc.Groups {
+		if group.Role != "" && !slices.Contains(roles, group.Role) {
+			return errors.Errorf("group %d (%s) has unsupported role \"%s\"", i, group.Name, group.Role)
+		}
+		ops := []s.Operation{}
+		for op := range group.Mix {
+			ops = append(ops, op)
+		}
+		if session := group.Session; session != nil {
+			if group.Role == "" || len(group.Mix) > 0 {
+				return errors.Errorf("group %d (%s) acts in sessions, so it needs a role and no mix", i, group.Name)
+			}
+			for op := range session.Start {
+				ops = append(ops, op)
+			}
+			for from, transitions := range session.Transitions {
+				ops = append(ops, from)
+				for to := range transitions {
+					ops = append(ops, to)
+				}
+			}
+		}
+		for _, op := range ops {
+			if !slices.Contains(s.Operations, op) {
+				return errors.Errorf("group %d (%s) has unsupported operation \"%s\"", i, group.Name, op)
+			}
+		}
+	}
+	for op := range sc.SLOs {
+		if !slices.Contains(s.Operations, op) {
+			return errors.Errorf("SLO has unsupported operation \"%s\"", op)
+		}
+	}
+	if sc.Targets != nil {
+		for _, d := range []*Distribution{sc.Targets.Users, sc.Targets.Posts} {
+			if d != nil && !slices.Contains(distributions, d.Kind) {
+				return errors.Errorf("targets have unsupported distribution \"%s\"", d.Kind)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply sets the workload of config to the scenario's.
+func (sc *Scenario) Apply(config *driver.Config) {
+	if sc.Seed != 0 {
+		config.Seed = sc.Seed
+	}
+	if sc.SampleInterval > 0 {
+		config.SampleInterval = sc.SampleInterval
+	}
+	if sc.Dataset != nil {
+		config.Dataset = driver.DatasetConfig{
+			LargeCreators:    sc.Dataset.LargeCreators,
+			SmallCreators:    sc.Dataset.SmallCreators,
+			Viewers:          sc.Dataset.Viewers,
+			PostsPerCreator:  sc.Dataset.PostsPerCreator,
+			FollowsPerViewer: sc.Dataset.FollowsPerViewer,
+		}
+	}
+
+	config.Groups = nil
+	for _, group := range sc.Groups {
+		driverGroup := driver.UserGroup{
+			Name:      group.Name,
+			Role:      group.Role,
+			Users:     group.Users,
+			Mix:       group.Mix,
+			ThinkTime: driver.ThinkTime{Min: group.ThinkTime.Min, Max: group.ThinkTime.Max},
+		}
+		if session := group.Session; session != nil {
+			driverGroup.Session = &driver.Session{
+				Start:       session.Start,
+				Transitions: session.Transitions,
+				Length:      driver.SessionLength{Min: session.Length.Min, Max: session.Length.Max},
+				Pause:       driver.ThinkTime{Min: session.Pause.Min, Max: session.Pause.Max},
+			}
+		}
+		config.Groups = append(config.Groups, driverGroup)
+	}
+
+	config.Phases = nil
+	for _, phase := range sc.Phases {
+		driverPhase := driver.Phase{
+			Name:     phase.Name,
+			Kind:     phase.Kind,
+			Duration: phase.Duration,
+			Users:    phase.Users,
+			Rate:     phase.Rate,
+			Steps:    phase.Steps,
+		}
+		if phase.UntilStable != nil {
+			driverPhase.UntilStable = &driver.Stability{Window: phase.UntilStable.Window, MaxCV: phase.UntilStable.MaxCV}
+		}
+		config.Phases = append(config.Phases, driverPhase)
+	}
+
+	config.UserTargets, config.PostTargets = nil, nil
+	if sc.Targets != nil {
+		config.UserTargets = sc.Targets.Users.driverDistribution()
+		config.PostTargets = sc.Targets.Posts.driverDistribution()
+	}
+
+	config.SLOs = nil
+	if len(sc.SLOs) > 0 {
+		config.SLOs = map[s.Operation]*driver.SLO{}
+		for op, slo := range sc.SLOs {
+			config.SLOs[op] = &driver.SLO{P50: slo.P50, P90: slo.P90, P99: slo.P99, ErrorRate: slo.ErrorRate}
+		}
+	}
+}
+
+// Load reads the built-in scenario called name, or else the scenario file at
+// name.
+func Load(name string) (*Scenario, error) {
+	data, err := builtin.ReadFile(path.Join("builtin", name+".yaml"))
+	if err != nil {
+		data, err = os.ReadFile(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "reading scenario failed, neither a built-in scenario nor a file, name=\"%s\"", name)
+		}
+	}
+
+	scenario, err := Parse(data)
+	if err != nil {
+		return nil, errors.Wrapf(err, "loading scenario failed, name=\"%s\"", name)
+	}
+	return scenario, nil
+}
+
+// Builtin returns the names of the built-in scenarios.
+func Builtin() []string {
+	entries, _ := fs.ReadDir(builtin, "builtin")
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".yaml"))
+	}
+	return names
+}
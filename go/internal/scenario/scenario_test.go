@@ -0,0 +1,148 @@
+package scenario_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/scenario"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+)
+
+const testScenario = `
+name: test
+seed: 7
+sample_interval: 50ms
+dataset:
+  large_creators: 1
+  small_creators: 2
+  viewers: 10
+  posts_per_creator: 2
+  follows_per_viewer: 2
+groups:
+  - name: viewers
+    role: Viewer
+    users: 3
+    think_time: {min: 1ms, max: 2ms}
+    mix: {GetFollowedFeed: 2, LikePost: 1}
+  - name: creators
+    role: SmallCreator
+    users: 1
+    mix: {CreatePost: 1}
+phases:
+  - {kind: warmup, duration: 50ms, users: 1, until_stable: {window: 2, max_cv: 0.5}}
+  - {kind: steady, duration: 150ms}
+slos:
+  LikePost: {p99: 1s}
+targets:
+  posts: {kind: hotspot, hot_fraction: 0.1, hot_share: 0.9}
+`
+
+func TestRunScenario(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sc, err := scenario.Parse([]byte(testScenario))
+	require.NoError(t, err)
+	config := *driver.DefaultConfig
+	sc.Apply(&config)
+
+	// Act:
+	result, err := driver.NewDriver(servertest.NewFakeServer(), &config).Run(ctx)
+	require.NoError(t, err)
+
+	// Assert:
+	require.Equal(t, int64(7), result.Config.Seed)
+	require.Equal(t, "13", result.Metadata["dataset.users"])
+	require.Equal(t, "4", result.Metadata["driver.workers"])
+	require.Contains(t, result.Operations, s.OpGetFollowedFeed)
+	require.Contains(t, result.Operations, s.OpCreatePost)
+	require.NotContains(t, result.Operations, s.OpGetPost)
+	require.Len(t, result.SLOs, 1)
+	require.Equal(t, 0.5, config.Phases[0].UntilStable.MaxCV)
+	require.Len(t, result.Phases, 2)
+	require.Equal(t, "uniform", result.Metadata["driver.user_targets"])
+	require.Equal(t, "hotspot(0.1, 0.9)", result.Metadata["driver.post_targets"])
+}
+
+func TestApplySession(t *testing.T) {
+	sc, err := scenario.Parse([]byte(`
+groups:
+  - name: viewers
+    role: Viewer
+    users: 2
+    session:
+      start: {GetFollowedFeed: 1}
+      transitions:
+        GetFollowedFeed: {GetPost: 3, GetFollowedFeed: 1}
+      length: {min: 2, max: 5}
+      pause: {min: 1s, max: 2s}
+`))
+	require.NoError(t, err)
+	config := *driver.DefaultConfig
+
+	// Act:
+	sc.Apply(&config)
+
+	// Assert:
+	require.Equal(t, &driver.Session{
+		Start:       map[s.Operation]int{s.OpGetFollowedFeed: 1},
+		Transitions: map[s.Operation]map[s.Operation]int{s.OpGetFollowedFeed: {s.OpGetPost: 3, s.OpGetFollowedFeed: 1}},
+		Length:      driver.SessionLength{Min: 2, Max: 5},
+		Pause:       driver.ThinkTime{Min: time.Second, Max: 2 * time.Second},
+	}, config.Groups[0].Session)
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		name string
+		yaml string
+		err  string
+	}{
+		{"unknown field", "name: x\nworkers: 3\n", "field workers not found"},
+		{"no groups", "name: x\n", "has no groups"},
+		{"unknown role", "groups: [{name: g, role: Admin, users: 1, mix: {GetPost: 1}}]", "unsupported role \"Admin\""},
+		{"unknown operation", "groups: [{name: g, users: 1, mix: {DeletePost: 1}}]", "unsupported operation \"DeletePost\""},
+		{"unknown distribution", "groups: [{name: g, users: 1, mix: {GetPost: 1}}]\ntargets: {posts: {kind: pareto}}", "unsupported distribution \"pareto\""},
+		{"bad duration", "phases: [{kind: steady, duration: soon}]", "parsing scenario failed"},
+		{"session without role", "groups: [{name: g, users: 1, session: {start: {GetPost: 1}}}]", "needs a role and no mix"},
+		{"session with mix", "groups: [{name: g, role: Viewer, users: 1, mix: {GetPost: 1}, session: {start: {GetPost: 1}}}]", "needs a role and no mix"},
+		{"unknown transition", "groups: [{name: g, role: Viewer, users: 1, session: {start: {GetPost: 1}, transitions: {GetPost: {SharePost: 1}}}}]", "unsupported operation \"SharePost\""},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Act:
+			_, err := scenario.Parse([]byte(c.yaml))
+
+			// Assert:
+			require.ErrorContains(t, err, c.err)
+		})
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	names := scenario.Builtin()
+	require.ElementsMatch(t, []string{"browsing-sessions", "follow-storm", "read-heavy-timeline", "viral-post"}, names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			// Act:
+			sc, err := scenario.Load(name)
+			require.NoError(t, err)
+			config := *driver.DefaultConfig
+			sc.Apply(&config)
+
+			// Assert:
+			require.Equal(t, name, sc.Name)
+			require.NotEmpty(t, sc.Description)
+			require.NotEmpty(t, config.Groups)
+			require.NotEmpty(t, config.Phases)
+			require.Greater(t, config.TotalDuration(), time.Duration(0))
+		})
+	}
+}
@@ -0,0 +1,249 @@
+// Package search finds the highest arrival rate a server sustains within the
+// SLOs of a workload, by running short open-loop trials of the workload at
+// different rates.
+package search
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+var DefaultConfig = &Config{
+	MinRate:       50,
+	MaxRate:       10000,
+	Warmup:        5 * time.Second,
+	TrialDuration: 20 * time.Second,
+	Precision:     0.05,
+	MaxTrials:     12,
+	MinAchieved:   0.95,
+}
+
+type Config struct {
+	// MinRate is the rate of the first trial, in calls per second. Rates
+	// double from it until a trial fails or MaxRate passes.
+	MinRate float64
+	MaxRate float64
+	// Warmup is the length of the unmeasured phase at the start of every
+	// trial.
+	Warmup time.Duration
+	// TrialDuration is the length of the measured phase of every trial.
+	TrialDuration time.Duration
+	// Precision ends the search once the highest passing rate is within
+	// this fraction of the lowest failing one.
+	Precision float64
+	MaxTrials int
+	// MinAchieved is the lowest fraction of its rate that a trial's
+	// throughput may reach and still pass. Below it, calls queued up for
+	// the virtual users, so the rate was not sustained.
+	MinAchieved float64
+}
+
+// Trial is one run of the workload at Rate.
+type Trial struct {
+	Rate   float64
+	Result *driver.Result
+	// Throughput and P99 cover the measured calls of every operation.
+	Throughput float64
+	P99        time.Duration
+	Passed     bool
+	// Reason tells why a trial failed.
+	Reason string `json:",omitempty"`
+}
+
+// Result is the outcome of a search.
+type Result struct {
+	// Trials holds the trials in the order they ran.
+	Trials []*Trial
+	// Best is the passing trial with the highest rate, or nil when none
+	// passed.
+	Best *Trial
+	// Knee is the trial at the knee of the throughput/latency curve, past
+	// which latency grows faster than throughput. It is nil when the trials
+	// do not show one.
+	Knee *Trial
+}
+
+// Runner runs a workload, as driver.Driver.Run does. Trials compare only when
+// it starts each from the same data, by emptying the backend before seeding.
+type Runner func(ctx context.Context, config *driver.Config) (*driver.Result, error)
+
+// Search runs trials of base at different rates with run. Each trial is a
+// warmup followed by a steady phase, both open-loop at its rate, and passes
+// when it meets base's SLOs, sustains its rate and drops no arrivals. The
+// virtual users of base bound how many calls are in flight, so they must be
+// enough for the highest rate. onTrial, when set, is called after every
+// trial, e.g. to store its result.
+func Search(ctx context.Context, base *driver.Config, config *Config, run Runner, onTrial func(*Trial) error) (*Result, error) {
+	if len(base.SLOs) == 0 {
+		return nil, errors.New("searching needs at least one SLO to search against")
+	}
+	if config.MinRate <= 0 || config.MaxRate < config.MinRate {
+		return nil, errors.Errorf("search rates must be positive and in order, were %g and %g", config.MinRate, config.MaxRate)
+	}
+
+	result := &Result{}
+	try := func(rate float64) (*Trial, error) {
+		trial, err := runTrial(ctx, base, config, run, rate, len(result.Trials)+1)
+		if err != nil {
+			return nil, err
+		}
+		result.Trials = append(result.Trials, trial)
+		if trial.Passed {
+			result.Best = trial
+		}
+		slog.InfoContext(ctx, "search trial finished",
+			slog.Float64("rate", rate),
+			slog.Float64("throughput", trial.Throughput),
+			slog.Duration("p99", trial.P99),
+			slog.Bool("passed", trial.Passed))
+		if onTrial != nil {
+			err = onTrial(trial)
+		}
+		return trial, err
+	}
+
+	// Probe upwards until a trial fails, so that the bisection has a
+	// bracket.
+	passed, failed := 0.0, 0.0
+	for rate := config.MinRate; len(result.Trials) < config.MaxTrials; rate = min(2*rate, config.MaxRate) {
+		trial, err := try(rate)
+		if err != nil {
+			return nil, err
+		}
+		if !trial.Passed {
+			failed = rate
+			break
+		}
+		passed = rate
+		if rate >= config.MaxRate {
+			break
+		}
+	}
+
+	for failed > 0 && len(result.Trials) < config.MaxTrials && failed-passed > config.Precision*failed {
+		rate := (passed + failed) / 2
+		trial, err := try(rate)
+		if err != nil {
+			return nil, err
+		}
+		if trial.Passed {
+			passed = rate
+		} else {
+			failed = rate
+		}
+	}
+
+	result.Knee = knee(result.Trials)
+	return result, nil
+}
+
+func runTrial(ctx context.Context, base *driver.Config, config *Config, run Runner, rate float64, number int) (*Trial, error) {
+	trialConfig := *base
+	trialConfig.Phases = nil
+	if config.Warmup > 0 {
+		trialConfig.Phases = append(trialConfig.Phases, driver.Phase{Kind: driver.PhaseWarmup, Duration: config.Warmup, Rate: rate})
+	}
+	trialConfig.Phases = append(trialConfig.Phases, driver.Phase{Kind: driver.PhaseSteady, Duration: config.TrialDuration, Rate: rate})
+
+	result, err := run(ctx, &trialConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "search trial %d failed, rate=%g", number, rate)
+	}
+
+	trial := &Trial{Rate: rate, Result: result, Passed: true}
+	total := stats.NewHistogram()
+	for _, opResult := range result.Operations {
+		trial.Throughput += opResult.Throughput
+		if opResult.Latency != nil {
+			total.Merge(opResult.Latency)
+		}
+	}
+	trial.P99 = total.Quantile(0.99)
+
+	if missed := result.MissedSLOs(); len(missed) > 0 {
+		trial.Passed, trial.Reason = false, "missed "+missed[0].String()
+	} else if dropped := result.Counters["driver.dropped_arrivals"]; dropped > 0 {
+		trial.Passed, trial.Reason = false, fmt.Sprintf("dropped %d arrivals", dropped)
+	} else if trial.Throughput < config.MinAchieved*rate {
+		trial.Passed, trial.Reason = false, fmt.Sprintf("sustained %.1f of %.1f calls/s", trial.Throughput, rate)
+	}
+
+	result.Metadata["search.trial"] = strconv.Itoa(number)
+	result.Metadata["search.rate"] = strconv.FormatFloat(rate, 'f', -1, 64)
+	result.Metadata["search.passed"] = strconv.FormatBool(trial.Passed)
+	return trial, nil
+}
+
+// knee finds the knee of the throughput/latency curve of trials, in the
+// manner of Kneedle: with both axes scaled to [0, 1], it is the trial
+// furthest below the line from the lowest rate to the highest.
+func knee(trials []*Trial) *Trial {
+	if len(trials) < 3 {
+		return nil
+	}
+	sorted := append([]*Trial{}, trials...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Rate < sorted[j].Rate })
+
+	first, last := sorted[0], sorted[len(sorted)-1]
+	minP99, maxP99 := first.P99, first.P99
+	for _, trial := range sorted {
+		minP99, maxP99 = min(minP99, trial.P99), max(maxP99, trial.P99)
+	}
+	if last.Throughput <= first.Throughput || maxP99 <= minP99 {
+		return nil
+	}
+
+	var found *Trial
+	best := 0.0
+	for _, trial := range sorted[1 : len(sorted)-1] {
+		x := (trial.Throughput - first.Throughput) / (last.Throughput - first.Throughput)
+		y := float64(trial.P99-minP99) / float64(maxP99-minP99)
+		if x-y > best {
+			found, best = trial, x-y
+		}
+	}
+	return found
+}
+
+// WriteSummary writes the trials of the search and what it found.
+func (r *Result) WriteSummary(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "trial\trun\trate\tops/s\tp99\tresult")
+	for i, trial := range r.Trials {
+		outcome := "passed"
+		if !trial.Passed {
+			outcome = "FAILED: " + trial.Reason
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%.1f\t%.1f\t%s\t%s\n",
+			i+1,
+			trial.Result.RunID,
+			trial.Rate,
+			trial.Throughput,
+			trial.P99.Round(time.Microsecond),
+			outcome,
+		)
+	}
+
+	fmt.Fprintln(tw)
+	if r.Best != nil {
+		fmt.Fprintf(tw, "max rate\t%.1f calls/s (run %s)\n", r.Best.Rate, r.Best.Result.RunID)
+	} else {
+		fmt.Fprintln(tw, "max rate\tnone, every trial failed")
+	}
+	if r.Knee != nil {
+		fmt.Fprintf(tw, "knee\t%.1f calls/s at p99 %s (run %s)\n", r.Knee.Throughput, r.Knee.P99.Round(time.Microsecond), r.Knee.Result.RunID)
+	}
+	return tw.Flush()
+}
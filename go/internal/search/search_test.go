@@ -0,0 +1,130 @@
+package search_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/jlym/dbbenchmark/go/internal/driver"
+	"github.com/jlym/dbbenchmark/go/internal/search"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+)
+
+func getTestContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+// queueRunner models a server that serves up to capacity calls per second,
+// with a p99 that grows as 1ms/(1 - utilization).
+func queueRunner(capacity float64, slo time.Duration) search.Runner {
+	return func(ctx context.Context, config *driver.Config) (*driver.Result, error) {
+		rate := config.Phases[len(config.Phases)-1].Rate
+		throughput := min(rate, capacity)
+		p99 := time.Second
+		if rate < capacity {
+			p99 = time.Duration(float64(time.Millisecond) / (1 - rate/capacity))
+		}
+
+		latency := stats.NewHistogram()
+		for i := 0; i < 100; i++ {
+			latency.Record(p99)
+		}
+		return &driver.Result{
+			RunID:    "run",
+			Metadata: map[string]string{},
+			Operations: map[s.Operation]*driver.OperationResult{
+				s.OpGetPost: {Count: 100, Throughput: throughput, P99: p99, Latency: latency},
+			},
+			SLOs: []*driver.SLOResult{{
+				Operation: s.OpGetPost,
+				Objective: "p99",
+				Target:    float64(slo),
+				Actual:    float64(p99),
+				Met:       p99 <= slo,
+			}},
+		}, nil
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	base := *driver.DefaultConfig
+	base.SLOs = map[s.Operation]*driver.SLO{s.OpGetPost: {P99: 10 * time.Millisecond}}
+	var stored []*search.Trial
+
+	// Act:
+	result, err := search.Search(ctx, &base, search.DefaultConfig, queueRunner(1200, 10*time.Millisecond), func(trial *search.Trial) error {
+		stored = append(stored, trial)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Assert: The p99 reaches 10ms at 1080 calls/s, and the search narrowed
+	// down to within 5% of it.
+	require.NotNil(t, result.Best)
+	require.InDelta(t, 1080, result.Best.Rate, 0.05*1080)
+	require.LessOrEqual(t, result.Best.Rate, 1080.0)
+	require.Equal(t, result.Trials, stored)
+	require.Equal(t, "1", result.Trials[0].Result.Metadata["search.trial"])
+	require.Equal(t, "50", result.Trials[0].Result.Metadata["search.rate"])
+
+	// Assert: The knee is where latency starts to climb, below saturation.
+	require.NotNil(t, result.Knee)
+	require.Greater(t, result.Knee.Rate, 400.0)
+	require.Less(t, result.Knee.Rate, 1200.0)
+
+	var buffer bytes.Buffer
+	require.NoError(t, result.WriteSummary(&buffer))
+	require.Contains(t, buffer.String(), "FAILED: missed GetPost p99")
+	require.Contains(t, buffer.String(), "max rate")
+}
+
+func TestSearchFakeServer(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	base := *driver.DefaultConfig
+	base.Workers = 4
+	base.SampleInterval = 50 * time.Millisecond
+	base.SLOs = map[s.Operation]*driver.SLO{s.OpGetPost: {P99: time.Second}}
+	config := &search.Config{
+		MinRate:       200,
+		MaxRate:       400,
+		Warmup:        50 * time.Millisecond,
+		TrialDuration: 200 * time.Millisecond,
+		Precision:     0.05,
+		MaxTrials:     4,
+		MinAchieved:   0.5,
+	}
+	run := func(ctx context.Context, config *driver.Config) (*driver.Result, error) {
+		return driver.NewDriver(servertest.NewFakeServer(), config).Run(ctx)
+	}
+
+	// Act:
+	result, err := search.Search(ctx, &base, config, run, nil)
+	require.NoError(t, err)
+
+	// Assert: Both rates passed, so the search stopped at MaxRate.
+	require.Len(t, result.Trials, 2)
+	require.Equal(t, 400.0, result.Best.Rate)
+	require.Len(t, result.Trials[1].Result.Phases, 2)
+	require.Equal(t, 400.0, result.Trials[1].Result.Config.Phases[1].Rate)
+}
+
+func TestSearchWithoutSLOs(t *testing.T) {
+	ctx, cancel := getTestContext()
+	defer cancel()
+
+	// Act:
+	_, err := search.Search(ctx, driver.DefaultConfig, search.DefaultConfig, queueRunner(1000, time.Second), nil)
+
+	// Assert:
+	require.ErrorContains(t, err, "needs at least one SLO")
+}
@@ -0,0 +1,71 @@
+package server
+
+import "context"
+
+// Operation names a Server method.
+type Operation string
+
+const (
+	OpCreateUser      Operation = "CreateUser"
+	OpGetUser         Operation = "GetUser"
+	OpFollowUser      Operation = "FollowUser"
+	OpGetUserFeed     Operation = "GetUserFeed"
+	OpGetFollowedFeed Operation = "GetFollowedFeed"
+	OpGetFollowed     Operation = "GetFollowed"
+	OpCreatePost      Operation = "CreatePost"
+	OpGetPost         Operation = "GetPost"
+	OpLikePost        Operation = "LikePost"
+)
+
+// Operations lists every Operation in the order of the Server interface.
+var Operations = []Operation{
+	OpCreateUser,
+	OpGetUser,
+	OpFollowUser,
+	OpGetUserFeed,
+	OpGetFollowedFeed,
+	OpGetFollowed,
+	OpCreatePost,
+	OpGetPost,
+	OpLikePost,
+}
+
+type operationKey struct{}
+
+// WithOperation returns a context that records which operation it belongs
+// to, so that work done on its behalf, such as SQL statements, can be
+// attributed to it.
+func WithOperation(ctx context.Context, op Operation) context.Context {
+	return context.WithValue(ctx, operationKey{}, op)
+}
+
+// OperationFromContext returns the operation set by WithOperation, or "" if
+// there is none.
+func OperationFromContext(ctx context.Context) Operation {
+	op, _ := ctx.Value(operationKey{}).(Operation)
+	return op
+}
+
+// CallerID returns the CallerID of request, or "" for requests that do not
+// have one, such as *CreateUserRequest.
+func CallerID(request any) string {
+	switch r := request.(type) {
+	case *GetUserRequest:
+		return r.CallerID
+	case *FollowUserRequest:
+		return r.CallerID
+	case *GetUserFeedRequest:
+		return r.CallerID
+	case *GetFollowedFeedRequest:
+		return r.CallerID
+	case *GetFollowedRequest:
+		return r.CallerID
+	case *CreatePostRequest:
+		return r.CallerID
+	case *GetPostRequest:
+		return r.CallerID
+	case *LikePostRequest:
+		return r.CallerID
+	}
+	return ""
+}
@@ -0,0 +1,279 @@
+// Package servertest provides an in-memory s.Server for tests of the packages
+// that wrap or drive a server.
+package servertest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/util"
+)
+
+// FakeServer keeps users, follows, posts and likes in memory. It counts calls
+// per operation so tests can tell whether a wrapper reached it.
+type FakeServer struct {
+	Clock util.Clock
+
+	lock    sync.Mutex
+	nextID  int
+	users   map[string]*s.User
+	follows map[string]map[string]bool
+	posts   map[string]*s.Post
+	order   []string
+	likes   map[string]map[string]bool
+	calls   map[s.Operation]int
+}
+
+// Enforce that FakeServer implements s.Server interface.
+var _ s.Server = &FakeServer{}
+
+func NewFakeServer() *FakeServer {
+	return &FakeServer{
+		Clock:   util.NewStubClock(),
+		users:   map[string]*s.User{},
+		follows: map[string]map[string]bool{},
+		posts:   map[string]*s.Post{},
+		likes:   map[string]map[string]bool{},
+		calls:   map[s.Operation]int{},
+	}
+}
+
+// Calls returns how many times op was called.
+func (f *FakeServer) Calls(op s.Operation) int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return f.calls[op]
+}
+
+func (f *FakeServer) CreateUser(
+	ctx context.Context, request *s.CreateUserRequest) (*s.CreateUserResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpCreateUser]++
+
+	if request.UserName == "" {
+		return nil, errors.New("request.UserName was empty")
+	}
+
+	user := &s.User{
+		UserID:    f.newID("user"),
+		UserName:  request.UserName,
+		Role:      request.Role,
+		CreatedAt: f.Clock.NowUtc(),
+	}
+	f.users[user.UserID] = user
+
+	copied := *user
+	return &s.CreateUserResponse{User: &copied}, nil
+}
+
+func (f *FakeServer) GetUser(ctx context.Context, request *s.GetUserRequest) (*s.GetUserResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpGetUser]++
+
+	user, ok := f.users[request.UserID]
+	if !ok {
+		return &s.GetUserResponse{}, nil
+	}
+
+	copied := *user
+	copied.FollowedByCaller = f.follows[request.CallerID][request.UserID]
+	return &s.GetUserResponse{User: &copied}, nil
+}
+
+func (f *FakeServer) FollowUser(
+	ctx context.Context, request *s.FollowUserRequest) (*s.FollowUserResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpFollowUser]++
+
+	if _, ok := f.users[request.CallerID]; !ok {
+		return nil, fmt.Errorf("given user does not exist, userID=\"%s\"", request.CallerID)
+	} else if _, ok := f.users[request.TargetUserID]; !ok {
+		return nil, fmt.Errorf("given user does not exist, userID=\"%s\"", request.TargetUserID)
+	}
+
+	if f.follows[request.CallerID] == nil {
+		f.follows[request.CallerID] = map[string]bool{}
+	}
+	f.follows[request.CallerID][request.TargetUserID] = true
+
+	return &s.FollowUserResponse{}, nil
+}
+
+func (f *FakeServer) GetUserFeed(
+	ctx context.Context, request *s.GetUserFeedRequest) (*s.GetUserFeedResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpGetUserFeed]++
+
+	posts, cursor := f.page(request.CallerID, request.Limit, request.Cursor, func(post *s.Post) bool {
+		return post.OwnerID == request.OwnerID
+	})
+
+	return &s.GetUserFeedResponse{
+		CallerID: request.CallerID,
+		OwnerID:  request.OwnerID,
+		Posts:    posts,
+		Limit:    request.Limit,
+		Cursor:   cursor,
+	}, nil
+}
+
+func (f *FakeServer) GetFollowedFeed(
+	ctx context.Context, request *s.GetFollowedFeedRequest) (*s.GetFollowedFeedResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpGetFollowedFeed]++
+
+	followed := f.follows[request.CallerID]
+	posts, cursor := f.page(request.CallerID, request.Limit, request.Cursor, func(post *s.Post) bool {
+		return followed[post.OwnerID]
+	})
+
+	return &s.GetFollowedFeedResponse{
+		CallerID: request.CallerID,
+		OwnerID:  request.CallerID,
+		Posts:    posts,
+		Limit:    request.Limit,
+		Cursor:   cursor,
+	}, nil
+}
+
+func (f *FakeServer) GetFollowed(
+	ctx context.Context, request *s.GetFollowedRequest) (*s.GetFollowedResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpGetFollowed]++
+
+	userIDs := []string{}
+	for userID := range f.follows[request.CallerID] {
+		userIDs = append(userIDs, userID)
+	}
+	sort.Strings(userIDs)
+
+	users := []*s.User{}
+	for _, userID := range userIDs {
+		copied := *f.users[userID]
+		copied.FollowedByCaller = true
+		users = append(users, &copied)
+	}
+
+	return &s.GetFollowedResponse{
+		CallerID: request.CallerID,
+		OwnerID:  request.CallerID,
+		User:     users,
+		Limit:    request.Limit,
+	}, nil
+}
+
+func (f *FakeServer) CreatePost(
+	ctx context.Context, request *s.CreatePostRequest) (*s.CreatePostResponse, error) {
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpCreatePost]++
+
+	if request.Content == "" {
+		return nil, errors.New("request.Content was empty")
+	}
+
+	post := &s.Post{
+		PostID:    f.newID("post"),
+		OwnerID:   request.CallerID,
+		Content:   request.Content,
+		CreatedAt: f.Clock.NowUtc(),
+	}
+	f.posts[post.PostID] = post
+	f.order = append(f.order, post.PostID)
+
+	return &s.CreatePostResponse{
+		CallerID: request.CallerID,
+		Post:     f.view(post, request.CallerID),
+	}, nil
+}
+
+func (f *FakeServer) GetPost(ctx context.Context, request *s.GetPostRequest) (*s.GetPostResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpGetPost]++
+
+	post, ok := f.posts[request.PostID]
+	if !ok {
+		return &s.GetPostResponse{}, nil
+	}
+
+	return &s.GetPostResponse{Post: f.view(post, request.CallerID)}, nil
+}
+
+func (f *FakeServer) LikePost(ctx context.Context, request *s.LikePostRequest) (*s.LikePostResponse, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.calls[s.OpLikePost]++
+
+	post, ok := f.posts[request.PostID]
+	if !ok {
+		return nil, fmt.Errorf("given post does not exist, postID=\"%s\"", request.PostID)
+	}
+
+	if f.likes[request.PostID] == nil {
+		f.likes[request.PostID] = map[string]bool{}
+	}
+	f.likes[request.PostID][request.CallerID] = true
+
+	return &s.LikePostResponse{Post: f.view(post, request.CallerID)}, nil
+}
+
+func (f *FakeServer) newID(kind string) string {
+	f.nextID++
+	return fmt.Sprintf("%s-%d", kind, f.nextID)
+}
+
+// view returns a copy of post as seen by callerID.
+func (f *FakeServer) view(post *s.Post, callerID string) *s.Post {
+	copied := *post
+	copied.LikeCount = len(f.likes[post.PostID])
+	copied.LikedByCaller = f.likes[post.PostID][callerID]
+	return &copied
+}
+
+// page returns matching posts newest first. The cursor is the ID of the last
+// post of the previous page.
+func (f *FakeServer) page(
+	callerID string, limit int, cursor string, match func(post *s.Post) bool) ([]*s.Post, string) {
+
+	if limit <= 0 {
+		limit = 20
+	}
+
+	posts := []*s.Post{}
+	started := cursor == ""
+	for i := len(f.order) - 1; i >= 0; i-- {
+		post := f.posts[f.order[i]]
+		if !started {
+			started = post.PostID == cursor
+			continue
+		}
+		if !match(post) {
+			continue
+		}
+
+		posts = append(posts, f.view(post, callerID))
+		if len(posts) == limit {
+			return posts, post.PostID
+		}
+	}
+
+	return posts, ""
+}
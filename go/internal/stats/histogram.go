@@ -0,0 +1,150 @@
+package stats
+
+import (
+	"math"
+	"math/bits"
+	"time"
+)
+
+const (
+	// subBucketBits sets the precision: each power of two is split into
+	// 2^subBucketBits buckets, so values are kept within ~1.6% of their
+	// true value.
+	subBucketBits  = 6
+	subBucketCount = 1 << subBucketBits
+	// maxExponent covers values up to 2^40ns, about 18 minutes.
+	maxExponent = 40
+	bucketCount = (maxExponent - subBucketBits + 2) * subBucketCount
+)
+
+// Histogram is a log-linear histogram of durations. It is not safe for
+// concurrent use.
+type Histogram struct {
+	Counts []uint64
+	Total  uint64
+	Sum    time.Duration
+	Min    time.Duration
+	Max    time.Duration
+}
+
+func NewHistogram() *Histogram {
+	return &Histogram{
+		Counts: make([]uint64, bucketCount),
+	}
+}
+
+func (h *Histogram) Record(value time.Duration) {
+	if value < 0 {
+		value = 0
+	}
+
+	h.Counts[bucketIndex(value)]++
+	if h.Total == 0 || value < h.Min {
+		h.Min = value
+	}
+	if value > h.Max {
+		h.Max = value
+	}
+	h.Total++
+	h.Sum += value
+}
+
+func (h *Histogram) Merge(other *Histogram) {
+	if other.Total == 0 {
+		return
+	}
+
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	if h.Total == 0 || other.Min < h.Min {
+		h.Min = other.Min
+	}
+	if other.Max > h.Max {
+		h.Max = other.Max
+	}
+	h.Total += other.Total
+	h.Sum += other.Sum
+}
+
+func (h *Histogram) Clone() *Histogram {
+	clone := *h
+	clone.Counts = make([]uint64, len(h.Counts))
+	copy(clone.Counts, h.Counts)
+	return &clone
+}
+
+func (h *Histogram) Mean() time.Duration {
+	if h.Total == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(h.Total)
+}
+
+// Quantile returns the value at quantile q, where q is in [0, 1].
+func (h *Histogram) Quantile(q float64) time.Duration {
+	if h.Total == 0 {
+		return 0
+	} else if q <= 0 {
+		return h.Min
+	} else if q >= 1 {
+		return h.Max
+	}
+
+	rank := uint64(math.Ceil(q * float64(h.Total)))
+	var seen uint64
+	for i, count := range h.Counts {
+		seen += count
+		if seen >= rank {
+			return h.clamp(bucketValue(i))
+		}
+	}
+
+	return h.Max
+}
+
+// Buckets calls fn with the representative value and count of each non-empty
+// bucket, in increasing order of value.
+func (h *Histogram) Buckets(fn func(value time.Duration, count uint64)) {
+	for i, count := range h.Counts {
+		if count > 0 {
+			fn(h.clamp(bucketValue(i)), count)
+		}
+	}
+}
+
+func (h *Histogram) clamp(value time.Duration) time.Duration {
+	if value < h.Min {
+		return h.Min
+	} else if value > h.Max {
+		return h.Max
+	}
+	return value
+}
+
+func bucketIndex(value time.Duration) int {
+	v := uint64(value)
+	if v < subBucketCount {
+		return int(v)
+	}
+
+	exponent := bits.Len64(v) - subBucketBits
+	if exponent > maxExponent-subBucketBits+1 {
+		return bucketCount - 1
+	}
+	subBucket := int(v>>(exponent-1)) - subBucketCount
+	return exponent*subBucketCount + subBucket
+}
+
+// bucketValue returns the midpoint of the bucket at index.
+func bucketValue(index int) time.Duration {
+	if index < subBucketCount {
+		return time.Duration(index)
+	}
+
+	exponent := index / subBucketCount
+	subBucket := index % subBucketCount
+	lower := uint64(subBucketCount+subBucket) << (exponent - 1)
+	width := uint64(1) << (exponent - 1)
+	return time.Duration(lower + width/2)
+}
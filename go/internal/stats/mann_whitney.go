@@ -0,0 +1,117 @@
+package stats
+
+import (
+	"math"
+	"sort"
+)
+
+// MannWhitneyResult is the outcome of a Mann-Whitney U test between two
+// samples a and b.
+type MannWhitneyResult struct {
+	// U counts the pairs in which b's value is larger than a's, with ties
+	// counting half.
+	U float64
+	// Z is U normalized with the normal approximation. It is positive when b
+	// tends to be larger than a.
+	Z float64
+	// P is the two-sided p-value: the probability of a Z at least this far
+	// from zero if a and b came from the same distribution.
+	P float64
+}
+
+// MannWhitney runs a Mann-Whitney U test on the values recorded in a and b.
+// Values in the same bucket count as ties, so the test cannot tell apart
+// values less than about 1.6% apart. It uses the normal approximation with a
+// tie correction, which is accurate for the sample sizes of benchmark runs.
+// P is 1 when either histogram is empty or every value is tied.
+func MannWhitney(a *Histogram, b *Histogram) *MannWhitneyResult {
+	var u float64
+	var aBelow float64
+	var tieTerm float64
+	for i := 0; i < max(len(a.Counts), len(b.Counts)); i++ {
+		countA, countB := countAt(a, i), countAt(b, i)
+		u += countB * (aBelow + countA/2)
+		aBelow += countA
+
+		ties := countA + countB
+		tieTerm += ties*ties*ties - ties
+	}
+	return mannWhitney(u, float64(a.Total), float64(b.Total), tieTerm)
+}
+
+// countAt returns the count of bucket i of h, which is 0 past its last
+// bucket, as in histograms decoded from results of an older bucket layout.
+func countAt(h *Histogram, i int) float64 {
+	if i >= len(h.Counts) {
+		return 0
+	}
+	return float64(h.Counts[i])
+}
+
+// MannWhitneyValues runs a Mann-Whitney U test on the values of a and b,
+// such as the throughput of each interval of two runs. Only equal values
+// are ties. The normal approximation is rough below about 10 values a
+// sample, where P is too large rather than too small. P is 1 when either
+// sample is empty or every value is tied.
+func MannWhitneyValues(a []float64, b []float64) *MannWhitneyResult {
+	type value struct {
+		value float64
+		inB   bool
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, v := range a {
+		values = append(values, value{value: v})
+	}
+	for _, v := range b {
+		values = append(values, value{value: v, inB: true})
+	}
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].value < values[j].value
+	})
+
+	// Walk the values in runs of ties, counting for each value of b the
+	// values of a below it.
+	var u float64
+	var aBelow float64
+	var tieTerm float64
+	for start := 0; start < len(values); {
+		end := start
+		var countA, countB float64
+		for ; end < len(values) && values[end].value == values[start].value; end++ {
+			if values[end].inB {
+				countB++
+			} else {
+				countA++
+			}
+		}
+		u += countB * (aBelow + countA/2)
+		aBelow += countA
+
+		ties := countA + countB
+		tieTerm += ties*ties*ties - ties
+		start = end
+	}
+	return mannWhitney(u, float64(len(a)), float64(len(b)), tieTerm)
+}
+
+// mannWhitney normalizes u for samples of n1 and n2 values, whose runs of t
+// tied values sum t^3 - t to tieTerm.
+func mannWhitney(u float64, n1 float64, n2 float64, tieTerm float64) *MannWhitneyResult {
+	if n1 == 0 || n2 == 0 {
+		return &MannWhitneyResult{P: 1}
+	}
+
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - tieTerm/(n*(n-1)))
+	if variance <= 0 {
+		return &MannWhitneyResult{U: u, P: 1}
+	}
+
+	z := (u - mean) / math.Sqrt(variance)
+	return &MannWhitneyResult{
+		U: u,
+		Z: z,
+		P: math.Erfc(math.Abs(z) / math.Sqrt2),
+	}
+}
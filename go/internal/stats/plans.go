@@ -0,0 +1,88 @@
+package stats
+
+import (
+	"time"
+)
+
+// QueryPlan is the plan the database chose for one query, and what running
+// it cost.
+type QueryPlan struct {
+	// Method is the server method that issued the query.
+	Method        string
+	Statement     string
+	PlanningTime  time.Duration
+	ExecutionTime time.Duration
+	Root          *PlanNode `json:",omitempty"`
+	// Error is set instead of Root when the query could not be explained.
+	Error string `json:",omitempty"`
+}
+
+// PlanNode is one node of a Postgres EXPLAIN (FORMAT JSON) plan. Only the
+// fields used here are kept.
+type PlanNode struct {
+	NodeType     string  `json:"Node Type"`
+	RelationName string  `json:"Relation Name,omitempty"`
+	IndexName    string  `json:"Index Name,omitempty"`
+	PlanRows     float64 `json:"Plan Rows"`
+	ActualRows   float64 `json:"Actual Rows"`
+	ActualLoops  float64 `json:"Actual Loops"`
+	// ActualTotalTime is in milliseconds, per loop.
+	ActualTotalTime  float64     `json:"Actual Total Time"`
+	SharedHitBlocks  int64       `json:"Shared Hit Blocks"`
+	SharedReadBlocks int64       `json:"Shared Read Blocks"`
+	Plans            []*PlanNode `json:"Plans,omitempty"`
+}
+
+// Walk calls fn for every node of the plan, parents first.
+func (q *QueryPlan) Walk(fn func(node *PlanNode)) {
+	var walk func(node *PlanNode)
+	walk = func(node *PlanNode) {
+		if node == nil {
+			return
+		}
+		fn(node)
+		for _, child := range node.Plans {
+			walk(child)
+		}
+	}
+	walk(q.Root)
+}
+
+// UsesIndex reports whether any node scans index.
+func (q *QueryPlan) UsesIndex(index string) bool {
+	found := false
+	q.Walk(func(node *PlanNode) {
+		found = found || node.IndexName == index
+	})
+	return found
+}
+
+// HasSeqScan reports whether any node scans relation sequentially, or any
+// relation when relation is "".
+func (q *QueryPlan) HasSeqScan(relation string) bool {
+	found := false
+	q.Walk(func(node *PlanNode) {
+		found = found || (node.NodeType == "Seq Scan" && (relation == "" || node.RelationName == relation))
+	})
+	return found
+}
+
+// Scans describes how each relation in the plan is read, such as
+// "Index Scan on posts using posts_pkey".
+func (q *QueryPlan) Scans() []string {
+	scans := []string{}
+	q.Walk(func(node *PlanNode) {
+		if node.RelationName == "" && node.IndexName == "" {
+			return
+		}
+		scan := node.NodeType
+		if node.RelationName != "" {
+			scan += " on " + node.RelationName
+		}
+		if node.IndexName != "" {
+			scan += " using " + node.IndexName
+		}
+		scans = append(scans, scan)
+	})
+	return scans
+}
@@ -0,0 +1,66 @@
+package stats
+
+import (
+	"sort"
+	"time"
+)
+
+// QueryStats are the statistics a database keeps for one normalized query,
+// such as a row of pg_stat_statements.
+type QueryStats struct {
+	QueryID int64
+	// Method is the server method that issued the query, when its SQL is
+	// tagged with one. Queries that several methods issue word for word
+	// cannot be told apart, and list those methods, comma-separated.
+	Method    string
+	Query     string
+	Calls     int64
+	TotalTime time.Duration
+	// MaxTime is the slowest call since the statistics were last reset.
+	MaxTime time.Duration
+	// Rows is the total number of rows returned or affected.
+	Rows int64
+	// SharedBlksHit and SharedBlksRead count the shared buffer blocks found
+	// in the buffer cache and read from outside it.
+	SharedBlksHit  int64
+	SharedBlksRead int64
+}
+
+// MeanTime is the mean time of a call.
+func (q *QueryStats) MeanTime() time.Duration {
+	if q.Calls == 0 {
+		return 0
+	}
+	return q.TotalTime / time.Duration(q.Calls)
+}
+
+// DiffQueryStats returns how much the statistics of each query grew from
+// before to after, leaving out queries that were not called in between. The
+// result is sorted by TotalTime, slowest first. MaxTime is taken from after,
+// as it cannot be diffed.
+func DiffQueryStats(before []*QueryStats, after []*QueryStats) []*QueryStats {
+	beforeByID := map[int64]*QueryStats{}
+	for _, queryStats := range before {
+		beforeByID[queryStats.QueryID] = queryStats
+	}
+
+	diff := []*QueryStats{}
+	for _, queryStats := range after {
+		delta := *queryStats
+		if previous, ok := beforeByID[queryStats.QueryID]; ok {
+			delta.Calls -= previous.Calls
+			delta.TotalTime -= previous.TotalTime
+			delta.Rows -= previous.Rows
+			delta.SharedBlksHit -= previous.SharedBlksHit
+			delta.SharedBlksRead -= previous.SharedBlksRead
+		}
+		if delta.Calls > 0 {
+			diff = append(diff, &delta)
+		}
+	}
+
+	sort.SliceStable(diff, func(i, j int) bool {
+		return diff[i].TotalTime > diff[j].TotalTime
+	})
+	return diff
+}
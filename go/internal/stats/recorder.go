@@ -0,0 +1,78 @@
+package stats
+
+import (
+	"sync"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// OperationStats summarizes the calls made to one operation.
+type OperationStats struct {
+	Latency *Histogram
+	Errors  uint64
+}
+
+// Recorder collects latencies and error counts per operation. It is safe for
+// concurrent use.
+type Recorder struct {
+	lock       sync.Mutex
+	operations map[s.Operation]*OperationStats
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		operations: map[s.Operation]*OperationStats{},
+	}
+}
+
+// Record adds one call. Failed calls count as errors and are also included in
+// the latency histogram.
+func (r *Recorder) Record(op s.Operation, latency time.Duration, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	opStats, ok := r.operations[op]
+	if !ok {
+		opStats = &OperationStats{Latency: NewHistogram()}
+		r.operations[op] = opStats
+	}
+
+	opStats.Latency.Record(latency)
+	if err != nil {
+		opStats.Errors++
+	}
+}
+
+// Snapshot returns a copy of the stats collected so far.
+func (r *Recorder) Snapshot() map[s.Operation]*OperationStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	snapshot := map[s.Operation]*OperationStats{}
+	for op, opStats := range r.operations {
+		snapshot[op] = &OperationStats{
+			Latency: opStats.Latency.Clone(),
+			Errors:  opStats.Errors,
+		}
+	}
+	return snapshot
+}
+
+// Reset discards everything recorded so far.
+func (r *Recorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.operations = map[s.Operation]*OperationStats{}
+}
+
+// Drain returns the stats collected so far and discards them, without losing
+// calls recorded in between as Snapshot followed by Reset would.
+func (r *Recorder) Drain() map[s.Operation]*OperationStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	drained := r.operations
+	r.operations = map[s.Operation]*OperationStats{}
+	return drained
+}
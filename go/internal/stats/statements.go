@@ -0,0 +1,91 @@
+package stats
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// StatementStats summarizes the executions of one SQL statement on behalf of
+// one operation.
+type StatementStats struct {
+	Operation s.Operation
+	Statement string
+	Latency   *Histogram
+	Errors    uint64
+	// Rows is the total number of rows returned or affected.
+	Rows int64
+}
+
+type statementKey struct {
+	op        s.Operation
+	statement string
+}
+
+// StatementRecorder collects statement latencies per operation. It is safe
+// for concurrent use.
+type StatementRecorder struct {
+	lock       sync.Mutex
+	statements map[statementKey]*StatementStats
+}
+
+func NewStatementRecorder() *StatementRecorder {
+	return &StatementRecorder{
+		statements: map[statementKey]*StatementStats{},
+	}
+}
+
+func (r *StatementRecorder) Record(
+	op s.Operation, statement string, latency time.Duration, rows int64, err error) {
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	key := statementKey{op: op, statement: statement}
+	statementStats, ok := r.statements[key]
+	if !ok {
+		statementStats = &StatementStats{
+			Operation: op,
+			Statement: statement,
+			Latency:   NewHistogram(),
+		}
+		r.statements[key] = statementStats
+	}
+
+	statementStats.Latency.Record(latency)
+	statementStats.Rows += rows
+	if err != nil {
+		statementStats.Errors++
+	}
+}
+
+// Snapshot returns a copy of the stats collected so far, ordered by
+// operation and then by total time spent, largest first.
+func (r *StatementRecorder) Snapshot() []*StatementStats {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	snapshot := make([]*StatementStats, 0, len(r.statements))
+	for _, statementStats := range r.statements {
+		clone := *statementStats
+		clone.Latency = statementStats.Latency.Clone()
+		snapshot = append(snapshot, &clone)
+	}
+
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Operation != snapshot[j].Operation {
+			return snapshot[i].Operation < snapshot[j].Operation
+		}
+		return snapshot[i].Latency.Sum > snapshot[j].Latency.Sum
+	})
+	return snapshot
+}
+
+// Reset discards everything recorded so far.
+func (r *StatementRecorder) Reset() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.statements = map[statementKey]*StatementStats{}
+}
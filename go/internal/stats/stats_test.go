@@ -0,0 +1,196 @@
+package stats_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/stats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogramQuantiles(t *testing.T) {
+	histogram := stats.NewHistogram()
+	for i := 1; i <= 1000; i++ {
+		histogram.Record(time.Duration(i) * time.Millisecond)
+	}
+
+	require.Equal(t, uint64(1000), histogram.Total)
+	require.Equal(t, time.Millisecond, histogram.Min)
+	require.Equal(t, time.Second, histogram.Max)
+	require.Equal(t, 500500*time.Microsecond, histogram.Mean())
+	require.Equal(t, time.Millisecond, histogram.Quantile(0))
+	require.Equal(t, time.Second, histogram.Quantile(1))
+
+	for _, q := range []float64{0.5, 0.9, 0.99} {
+		expected := float64(q * 1000 * float64(time.Millisecond))
+		require.InEpsilon(t, expected, float64(histogram.Quantile(q)), 0.02, "q=%f", q)
+	}
+}
+
+func TestHistogramMerge(t *testing.T) {
+	a := stats.NewHistogram()
+	b := stats.NewHistogram()
+	a.Record(5 * time.Millisecond)
+	b.Record(time.Millisecond)
+	b.Record(9 * time.Millisecond)
+
+	a.Merge(b)
+	require.Equal(t, uint64(3), a.Total)
+	require.Equal(t, time.Millisecond, a.Min)
+	require.Equal(t, 9*time.Millisecond, a.Max)
+	require.InEpsilon(t, float64(5*time.Millisecond), float64(a.Quantile(0.5)), 0.02)
+}
+
+func TestStatementRecorder(t *testing.T) {
+	recorder := stats.NewStatementRecorder()
+	recorder.Record(s.OpGetPost, "SELECT COUNT(*) FROM likes", 2*time.Millisecond, 1, nil)
+	recorder.Record(s.OpGetPost, "SELECT COUNT(*) FROM likes", 4*time.Millisecond, 1, nil)
+	recorder.Record(s.OpGetPost, "SELECT owner_id FROM posts", time.Millisecond, 0, errors.New("failed"))
+	recorder.Record(s.OpLikePost, "INSERT INTO likes", time.Millisecond, 1, nil)
+
+	snapshot := recorder.Snapshot()
+	require.Len(t, snapshot, 3)
+	require.Equal(t, s.OpGetPost, snapshot[0].Operation)
+	require.Equal(t, "SELECT COUNT(*) FROM likes", snapshot[0].Statement)
+	require.Equal(t, uint64(2), snapshot[0].Latency.Total)
+	require.Equal(t, int64(2), snapshot[0].Rows)
+	require.Equal(t, uint64(1), snapshot[1].Errors)
+	require.Equal(t, s.OpLikePost, snapshot[2].Operation)
+
+	recorder.Reset()
+	require.Empty(t, recorder.Snapshot())
+}
+
+func TestRecorderDrain(t *testing.T) {
+	recorder := stats.NewRecorder()
+	recorder.Record(s.OpGetPost, time.Millisecond, nil)
+	recorder.Record(s.OpGetPost, 3*time.Millisecond, errors.New("failed"))
+
+	// Act:
+	drained := recorder.Drain()
+
+	// Assert:
+	require.Len(t, drained, 1)
+	require.Equal(t, uint64(2), drained[s.OpGetPost].Latency.Total)
+	require.Equal(t, uint64(1), drained[s.OpGetPost].Errors)
+	require.Empty(t, recorder.Snapshot())
+}
+
+func TestDiffQueryStats(t *testing.T) {
+	before := []*stats.QueryStats{
+		{QueryID: 1, Calls: 10, TotalTime: 10 * time.Millisecond, Rows: 10, SharedBlksHit: 5},
+		{QueryID: 2, Calls: 4, TotalTime: time.Millisecond},
+	}
+	after := []*stats.QueryStats{
+		{QueryID: 1, Method: "GetPost", Calls: 12, TotalTime: 14 * time.Millisecond, MaxTime: 3 * time.Millisecond, Rows: 12, SharedBlksHit: 9},
+		{QueryID: 2, Calls: 4, TotalTime: time.Millisecond},
+		{QueryID: 3, Calls: 1, TotalTime: 20 * time.Millisecond},
+	}
+
+	// Act:
+	diff := stats.DiffQueryStats(before, after)
+
+	// Assert: Query 2 was not called, and the slowest query comes first.
+	require.Len(t, diff, 2)
+	require.Equal(t, int64(3), diff[0].QueryID)
+	require.Equal(t, &stats.QueryStats{
+		QueryID:       1,
+		Method:        "GetPost",
+		Calls:         2,
+		TotalTime:     4 * time.Millisecond,
+		MaxTime:       3 * time.Millisecond,
+		Rows:          2,
+		SharedBlksHit: 4,
+	}, diff[1])
+	require.Equal(t, 2*time.Millisecond, diff[1].MeanTime())
+}
+
+func TestQueryPlan(t *testing.T) {
+	plan := &stats.QueryPlan{Root: &stats.PlanNode{}}
+	err := json.Unmarshal([]byte(`{
+		"Node Type": "Nested Loop",
+		"Plans": [
+			{"Node Type": "Seq Scan", "Relation Name": "follows"},
+			{
+				"Node Type": "Bitmap Heap Scan",
+				"Relation Name": "likes",
+				"Plans": [
+					{"Node Type": "Bitmap Index Scan", "Index Name": "likes_post_id_idx"}
+				]
+			}
+		]
+	}`), plan.Root)
+	require.NoError(t, err)
+
+	require.True(t, plan.UsesIndex("likes_post_id_idx"))
+	require.False(t, plan.UsesIndex("likes_pkey"))
+	require.True(t, plan.HasSeqScan("follows"))
+	require.True(t, plan.HasSeqScan(""))
+	require.False(t, plan.HasSeqScan("likes"))
+	require.Equal(t, []string{
+		"Seq Scan on follows",
+		"Bitmap Heap Scan on likes",
+		"Bitmap Index Scan using likes_post_id_idx",
+	}, plan.Scans())
+}
+
+func TestMannWhitney(t *testing.T) {
+	a := stats.NewHistogram()
+	same := stats.NewHistogram()
+	slower := stats.NewHistogram()
+	for i := 0; i < 1000; i++ {
+		latency := time.Duration(1000+i) * time.Microsecond
+		a.Record(latency)
+		same.Record(latency)
+		slower.Record(latency * 11 / 10)
+	}
+
+	result := stats.MannWhitney(a, same)
+	require.InDelta(t, 0, result.Z, 1e-9)
+	require.InDelta(t, 1, result.P, 1e-9)
+
+	result = stats.MannWhitney(a, slower)
+	require.Greater(t, result.Z, 3.0)
+	require.Less(t, result.P, 0.001)
+
+	// Assert: Swapping the samples flips the direction only.
+	swapped := stats.MannWhitney(slower, a)
+	require.InDelta(t, -result.Z, swapped.Z, 1e-9)
+	require.InDelta(t, result.P, swapped.P, 1e-9)
+
+	require.Equal(t, 1.0, stats.MannWhitney(a, stats.NewHistogram()).P)
+
+	// Assert: Histograms with fewer buckets read as empty past their last.
+	truncated := same.Clone()
+	last := len(truncated.Counts) - 1
+	for truncated.Counts[last] == 0 {
+		last--
+	}
+	truncated.Counts = truncated.Counts[:last+1]
+	require.Equal(t, stats.MannWhitney(a, same), stats.MannWhitney(a, truncated))
+	require.Equal(t, stats.MannWhitney(same, a), stats.MannWhitney(truncated, a))
+}
+
+func TestMannWhitneyValues(t *testing.T) {
+	a := []float64{}
+	higher := []float64{}
+	for i := 0; i < 30; i++ {
+		a = append(a, float64(100+i))
+		higher = append(higher, float64(110+i))
+	}
+
+	result := stats.MannWhitneyValues(a, higher)
+	require.Greater(t, result.Z, 3.0)
+	require.Less(t, result.P, 0.001)
+
+	result = stats.MannWhitneyValues(a, a)
+	require.InDelta(t, 0, result.Z, 1e-9)
+	require.InDelta(t, 1, result.P, 1e-9)
+
+	// Assert: Ties count half.
+	require.Equal(t, 3.5, stats.MannWhitneyValues([]float64{1, 2}, []float64{2, 3}).U)
+	require.Equal(t, 1.0, stats.MannWhitneyValues(a, nil).P)
+}
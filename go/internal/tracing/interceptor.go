@@ -0,0 +1,45 @@
+package tracing
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// Attribute keys set on spans.
+const (
+	AttrOperation = attribute.Key("dbbenchmark.operation")
+	AttrCallerID  = attribute.Key("dbbenchmark.caller_id")
+)
+
+// Interceptor starts a span for every call. Name tells apart the layers a
+// call goes through, e.g. "driver" and "server".
+type Interceptor struct {
+	Name string
+}
+
+// Enforce that Interceptor implements middleware.Interceptor interface.
+var _ middleware.Interceptor = &Interceptor{}
+
+func (i *Interceptor) Intercept(ctx context.Context, op s.Operation, request any, call middleware.Call) error {
+	ctx, span := otel.Tracer(instrumentationName).Start(ctx, i.Name+"/"+string(op),
+		trace.WithAttributes(
+			AttrOperation.String(string(op)),
+			AttrCallerID.String(s.CallerID(request)),
+		),
+	)
+	defer span.End()
+
+	err := call(ctx)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
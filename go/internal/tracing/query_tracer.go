@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+)
+
+// QueryTracer is a pgx.QueryTracer that starts a span for every statement,
+// carrying its SQL text and the operation that ran it.
+type QueryTracer struct{}
+
+// Enforce that QueryTracer implements pgx.QueryTracer interface.
+var _ pgx.QueryTracer = &QueryTracer{}
+
+func (t *QueryTracer) TraceQueryStart(
+	ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryStartData) context.Context {
+
+	ctx, _ = otel.Tracer(instrumentationName).Start(ctx, "pg "+statementVerb(data.SQL),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "postgresql"),
+			attribute.String("db.statement", data.SQL),
+			AttrOperation.String(string(s.OperationFromContext(ctx))),
+		),
+	)
+	return ctx
+}
+
+func (t *QueryTracer) TraceQueryEnd(ctx context.Context, conn *pgx.Conn, data pgx.TraceQueryEndData) {
+	span := trace.SpanFromContext(ctx)
+	defer span.End()
+
+	span.SetAttributes(attribute.Int64("db.rows_affected", data.CommandTag.RowsAffected()))
+	if data.Err != nil {
+		span.RecordError(data.Err)
+		span.SetStatus(codes.Error, data.Err.Error())
+	}
+}
+
+// statementVerb returns the first word of sql, such as "SELECT", so that span
+// names stay few.
+func statementVerb(sql string) string {
+	fields := strings.Fields(sql)
+	if len(fields) == 0 {
+		return "statement"
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,116 @@
+// Package tracing exports OpenTelemetry spans for server calls, HTTP
+// requests and SQL statements.
+package tracing
+
+import (
+	"context"
+	"flag"
+	"os"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const instrumentationName = "github.com/jlym/dbbenchmark/go"
+
+// Exporters accepted in Config.Exporter.
+const (
+	ExporterNone = ""
+	ExporterOTLP = "otlp"
+	ExporterFile = "file"
+)
+
+// Config configures tracing.
+type Config struct {
+	// Exporter is where spans go. Tracing is disabled when it is empty.
+	Exporter string
+	// OTLPEndpoint is the host:port of an OTLP/HTTP collector. The
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable, or localhost:4318,
+	// is used when it is empty.
+	OTLPEndpoint string
+	// FilePath is the file spans are written to as JSON, one per line.
+	FilePath    string
+	ServiceName string
+	// SampleRatio is the fraction of traces kept. Every trace is kept when it
+	// is zero.
+	SampleRatio float64
+}
+
+// RegisterFlags adds -trace-exporter, -trace-endpoint, -trace-file and
+// -trace-sample-ratio to flags, which set the fields of c.
+func (c *Config) RegisterFlags(flags *flag.FlagSet) {
+	flags.StringVar(&c.Exporter, "trace-exporter", c.Exporter, "where to export spans: otlp or file; empty disables tracing")
+	flags.StringVar(&c.OTLPEndpoint, "trace-endpoint", c.OTLPEndpoint, "host:port of the OTLP/HTTP collector")
+	flags.StringVar(&c.FilePath, "trace-file", c.FilePath, "file to write spans to with -trace-exporter=file")
+	flags.Float64Var(&c.SampleRatio, "trace-sample-ratio", c.SampleRatio, "fraction of traces to keep; 0 keeps every trace")
+}
+
+// Setup installs the global tracer provider and W3C trace context
+// propagation. The returned function flushes and stops the exporter.
+func Setup(ctx context.Context, config *Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var file *os.File
+	switch config.Exporter {
+	case ExporterNone:
+		return func(context.Context) error { return nil }, nil
+
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if config.OTLPEndpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(config.OTLPEndpoint), otlptracehttp.WithInsecure())
+		}
+		var err error
+		exporter, err = otlptracehttp.New(ctx, options...)
+		if err != nil {
+			return nil, errors.Wrap(err, "creating OTLP exporter failed")
+		}
+
+	case ExporterFile:
+		var err error
+		file, err = os.Create(config.FilePath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "creating trace file failed, path=\"%s\"", config.FilePath)
+		}
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+		if err != nil {
+			file.Close()
+			return nil, errors.Wrap(err, "creating file exporter failed")
+		}
+
+	default:
+		return nil, errors.Errorf("unknown trace exporter: \"%s\"", config.Exporter)
+	}
+
+	sampler := sdktrace.AlwaysSample()
+	if config.SampleRatio > 0 && config.SampleRatio < 1 {
+		sampler = sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sampler),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", config.ServiceName),
+		)),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			closeErr := file.Close()
+			if err == nil {
+				err = closeErr
+			}
+		}
+		return errors.Wrap(err, "shutting down tracing failed")
+	}, nil
+}
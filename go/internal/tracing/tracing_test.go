@@ -0,0 +1,63 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/jlym/dbbenchmark/go/internal/httpapi"
+	"github.com/jlym/dbbenchmark/go/internal/middleware"
+	s "github.com/jlym/dbbenchmark/go/internal/server"
+	"github.com/jlym/dbbenchmark/go/internal/servertest"
+	"github.com/jlym/dbbenchmark/go/internal/tracing"
+)
+
+func TestTraceAcrossHTTP(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer provider.Shutdown(ctx)
+
+	handler := httpapi.NewHandler(
+		middleware.Wrap(servertest.NewFakeServer(), &tracing.Interceptor{Name: "server"}))
+	httpServer := httptest.NewServer(handler)
+	defer httpServer.Close()
+	client := middleware.Wrap(httpapi.NewClient(httpServer.URL), &tracing.Interceptor{Name: "driver"})
+
+	// Act:
+	_, err := client.GetPost(ctx, &s.GetPostRequest{CallerID: "caller", PostID: "post"})
+	require.NoError(t, err)
+
+	// Assert: The driver, HTTP client, HTTP server and server spans form one
+	// trace.
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	require.Contains(t, spans, "driver/GetPost")
+	require.Contains(t, spans, "server/GetPost")
+	require.Contains(t, spans, "POST /v1/GetPost")
+
+	driverSpan := spans["driver/GetPost"]
+	serverSpan := spans["server/GetPost"]
+	require.Equal(t, driverSpan.SpanContext.TraceID(), serverSpan.SpanContext.TraceID())
+	require.Equal(t, spans["POST /v1/GetPost"].SpanContext.SpanID(), serverSpan.Parent.SpanID())
+
+	attributes := map[string]string{}
+	for _, attribute := range serverSpan.Attributes {
+		attributes[string(attribute.Key)] = attribute.Value.Emit()
+	}
+	require.Equal(t, "GetPost", attributes[string(tracing.AttrOperation)])
+	require.Equal(t, "caller", attributes[string(tracing.AttrCallerID)])
+}
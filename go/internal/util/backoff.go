@@ -0,0 +1,55 @@
+package util
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// Backoff computes exponentially growing, jittered delays between retries.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+// Delay returns how long to wait before retry number attempt, starting at 1.
+// The delay doubles with every attempt up to Max, or without bound when Max
+// is 0, and a random half of it is jitter so that clients retrying together
+// spread out.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	if attempt < 1 || b.Initial <= 0 {
+		return 0
+	}
+
+	delay := b.Initial
+	for i := 1; i < attempt && delay <= math.MaxInt64/2; i++ {
+		if b.Max > 0 && delay >= b.Max {
+			break
+		}
+		delay *= 2
+	}
+	if b.Max > 0 && delay > b.Max {
+		delay = b.Max
+	}
+
+	half := delay / 2
+	return half + rand.N(half+1)
+}
+
+// Sleep waits for d or until ctx is done, whichever comes first.
+func Sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,39 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jlym/dbbenchmark/go/internal/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	cases := []struct {
+		name    string
+		backoff util.Backoff
+		attempt int
+		delay   time.Duration
+	}{
+		{"first", util.Backoff{Initial: 10 * time.Millisecond, Max: time.Second}, 1, 10 * time.Millisecond},
+		{"doubles", util.Backoff{Initial: 10 * time.Millisecond, Max: time.Second}, 4, 80 * time.Millisecond},
+		{"capped", util.Backoff{Initial: 10 * time.Millisecond, Max: 30 * time.Millisecond}, 4, 30 * time.Millisecond},
+		{"no max", util.Backoff{Initial: 10 * time.Millisecond}, 4, 80 * time.Millisecond},
+		{"no initial", util.Backoff{Max: time.Second}, 4, 0},
+		{"no attempt", util.Backoff{Initial: 10 * time.Millisecond}, 0, 0},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Act:
+			delay := c.backoff.Delay(c.attempt)
+
+			// Assert: Up to half of the delay is jitter.
+			require.GreaterOrEqual(t, delay, c.delay/2)
+			require.LessOrEqual(t, delay, c.delay)
+		})
+	}
+
+	// Assert: Without a max, many attempts do not overflow.
+	require.Greater(t, (&util.Backoff{Initial: time.Millisecond}).Delay(100), time.Duration(0))
+}